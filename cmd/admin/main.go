@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"time"
 	"tritontube/internal/proto"
 
@@ -30,17 +32,56 @@ func main() {
 
 	switch cmd {
 	case "add":
-		if len(os.Args) != 4 {
-			fmt.Println("Usage: add <server_address> <node_address>")
+		if len(os.Args) != 4 && len(os.Args) != 5 {
+			fmt.Println("Usage: add <server_address> <node_address> [weight]")
 			os.Exit(1)
 		}
-		addNode(client, os.Args[3])
+		weight := 1
+		if len(os.Args) == 5 {
+			weight, err = strconv.Atoi(os.Args[4])
+			if err != nil || weight < 1 {
+				fmt.Println("Error: weight must be a positive integer")
+				os.Exit(1)
+			}
+		}
+		addNode(client, os.Args[3], weight)
 	case "remove":
 		if len(os.Args) != 4 {
 			fmt.Println("Usage: remove <server_address> <node_address>")
 			os.Exit(1)
 		}
 		removeNode(client, os.Args[3])
+	case "delete":
+		if len(os.Args) != 4 {
+			fmt.Println("Usage: delete <server_address> <video_id>")
+			os.Exit(1)
+		}
+		deleteVideo(client, os.Args[3])
+	case "scrub":
+		if len(os.Args) != 3 && len(os.Args) != 4 {
+			fmt.Println("Usage: scrub <server_address> [start|repair]")
+			os.Exit(1)
+		}
+		action := ""
+		if len(os.Args) == 4 {
+			action = os.Args[3]
+		}
+		if action != "" && action != "start" && action != "repair" {
+			fmt.Printf("Unknown scrub action: %s\n", action)
+			os.Exit(1)
+		}
+		scrub(client, action)
+	case "status":
+		if len(os.Args) != 4 && len(os.Args) != 5 {
+			fmt.Println("Usage: status <server_address> <operation_id> [watch]")
+			os.Exit(1)
+		}
+		watch := len(os.Args) == 5
+		if watch && os.Args[4] != "watch" {
+			fmt.Printf("Unknown status option: %s\n", os.Args[4])
+			os.Exit(1)
+		}
+		operationStatus(client, os.Args[3], watch)
 	case "list":
 		if len(os.Args) != 3 {
 			fmt.Println("Usage: list <server_address>")
@@ -55,25 +96,29 @@ func main() {
 
 func printUsageAndExit() {
 	fmt.Println("Usage:")
-	fmt.Println("  add <server_address> <node_address>     - Add a node to the cluster")
-	fmt.Println("  remove <server_address> <node_address>  - Remove a node from the cluster")
-	fmt.Println("  list <server_address>                   - List all nodes in the cluster")
+	fmt.Println("  add <server_address> <node_address> [weight] - Start adding a node to the cluster")
+	fmt.Println("  remove <server_address> <node_address>       - Start removing a node from the cluster")
+	fmt.Println("  list <server_address>                        - List all nodes in the cluster")
+	fmt.Println("  delete <server_address> <video_id>           - Delete a video and all its content")
+	fmt.Println("  scrub <server_address> [start|repair]        - Show scrub findings, start a pass, or repair")
+	fmt.Println("  status <server_address> <operation_id> [watch] - Show or follow a rebalance operation")
 	os.Exit(1)
 }
 
-func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
+func addNode(client proto.VideoContentAdminServiceClient, nodeAddr string, weight int) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
 	response, err := client.AddNode(ctx, &proto.AddNodeRequest{
 		NodeAddress: nodeAddr,
+		Weight:      int32(weight),
 	})
 	if err != nil {
 		log.Fatalf("AddNode RPC failed: %v", err)
 	}
 
-	fmt.Printf("Successfully added node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	fmt.Printf("Adding node %s in operation %s\n", nodeAddr, response.OperationId)
+	fmt.Printf("Follow it with: status <server_address> %s watch\n", response.OperationId)
 }
 
 func removeNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
@@ -87,8 +132,58 @@ func removeNode(client proto.VideoContentAdminServiceClient, nodeAddr string) {
 		log.Fatalf("RemoveNode RPC failed: %v", err)
 	}
 
-	fmt.Printf("Successfully removed node: %s\n", nodeAddr)
-	fmt.Printf("Number of files migrated: %d\n", response.MigratedFileCount)
+	fmt.Printf("Removing node %s in operation %s\n", nodeAddr, response.OperationId)
+	fmt.Printf("Follow it with: status <server_address> %s watch\n", response.OperationId)
+}
+
+// operationStatus prints a rebalance operation, or with watch follows it
+// until it finishes, exiting non-zero if it failed.
+func operationStatus(client proto.VideoContentAdminServiceClient, opId string, watch bool) {
+	if !watch {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		op, err := client.GetOperation(ctx, &proto.GetOperationRequest{OperationId: opId})
+		if err != nil {
+			log.Fatalf("GetOperation RPC failed: %v", err)
+		}
+		printOperation(op)
+		return
+	}
+
+	stream, err := client.WatchOperation(context.Background(), &proto.GetOperationRequest{OperationId: opId})
+	if err != nil {
+		log.Fatalf("WatchOperation RPC failed: %v", err)
+	}
+	var last *proto.Operation
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("WatchOperation RPC failed: %v", err)
+		}
+		printOperation(op)
+		last = op
+	}
+	if last != nil && last.Status == "failed" {
+		os.Exit(1)
+	}
+}
+
+func printOperation(op *proto.Operation) {
+	target := op.NodeAddress
+	if op.Kind == "add" {
+		target = fmt.Sprintf("%s (weight %d)", op.NodeAddress, op.Weight)
+	}
+	fmt.Printf("Operation %s: %s %s, %s\n", op.Id, op.Kind, target, op.Status)
+	fmt.Printf("  Ring version: %d\n", op.RingVersion)
+	fmt.Printf("  Files moved: %d/%d (%d bytes), failed: %d\n", op.FilesMoved, op.FilesTotal, op.BytesMoved, op.FilesFailed)
+	fmt.Printf("  Updated: %s\n", time.Unix(op.UpdatedUnix, 0).Format(time.RFC3339))
+	if op.Error != "" {
+		fmt.Printf("  Error: %s\n", op.Error)
+	}
 }
 
 func listNodes(client proto.VideoContentAdminServiceClient) {
@@ -100,12 +195,86 @@ func listNodes(client proto.VideoContentAdminServiceClient) {
 		log.Fatalf("ListNodes RPC failed: %v", err)
 	}
 
-	fmt.Println("Storage cluster nodes:")
+	fmt.Printf("Storage cluster nodes (ring version %d):\n", response.RingVersion)
+	if response.Rebalancing {
+		fmt.Printf("  Rebalance in progress (operation %s), listing members of both the old and new ring\n", response.OperationId)
+	}
 	if len(response.Nodes) == 0 {
 		fmt.Println("  No nodes in cluster")
-	} else {
+		return
+	}
+	// Servers without health checks only fill in the addresses.
+	if len(response.Statuses) == 0 {
 		for _, node := range response.Nodes {
 			fmt.Printf("  - %s\n", node)
 		}
+		return
+	}
+	for _, st := range response.Statuses {
+		lastSeen := "never"
+		if st.LastSeenUnix > 0 {
+			lastSeen = time.Since(time.Unix(st.LastSeenUnix, 0)).Round(time.Second).String() + " ago"
+		}
+		fmt.Printf("  - %s (weight %d): %s, last seen %s\n", st.Address, st.Weight, st.State, lastSeen)
+		if st.LastError != "" {
+			fmt.Printf("      %d consecutive failures, last error: %s\n", st.ConsecutiveFailures, st.LastError)
+		}
+	}
+}
+
+func deleteVideo(client proto.VideoContentAdminServiceClient, videoId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := client.DeleteVideo(ctx, &proto.DeleteVideoRequest{
+		VideoId: videoId,
+	})
+	if err != nil {
+		log.Fatalf("DeleteVideo RPC failed: %v", err)
+	}
+
+	fmt.Printf("Successfully deleted video: %s\n", videoId)
+}
+
+func scrub(client proto.VideoContentAdminServiceClient, action string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	response, err := client.Scrub(ctx, &proto.ScrubClusterRequest{
+		Start:  action == "start",
+		Repair: action == "repair",
+	})
+	if err != nil {
+		log.Fatalf("Scrub RPC failed: %v", err)
+	}
+
+	for _, node := range response.Nodes {
+		fmt.Printf("Node %s:\n", node.NodeAddress)
+		if node.Error != "" {
+			fmt.Printf("  error: %s\n", node.Error)
+			continue
+		}
+		state := "idle"
+		if node.Running {
+			state = "running"
+		}
+		fmt.Printf("  %s, scanned %d/%d files\n", state, node.FilesScanned, node.FilesTotal)
+		if node.LastCompletedUnix != 0 {
+			fmt.Printf("  last completed: %s\n", time.Unix(node.LastCompletedUnix, 0).Format(time.RFC3339))
+		}
+		for _, f := range node.Findings {
+			if f.ExpectedSha256 == "" {
+				fmt.Printf("  - unverified %s/%s (no stored checksum, got %s)", f.VideoId, f.Filename, f.ActualSha256)
+			} else {
+				fmt.Printf("  - corrupt %s/%s (expected %s, got %s)", f.VideoId, f.Filename, f.ExpectedSha256, f.ActualSha256)
+			}
+			if f.Repair != "" {
+				fmt.Printf(": %s", f.Repair)
+			}
+			fmt.Println()
+		}
+	}
+	if action == "repair" {
+		fmt.Printf("Number of files repaired: %d\n", response.RepairedCount)
 	}
 }
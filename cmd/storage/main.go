@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"tritontube/internal/proto"
 	"tritontube/internal/storage"
@@ -15,6 +18,7 @@ import (
 func main() {
 	host := flag.String("host", "localhost", "Host address for the server")
 	port := flag.Int("port", 8090, "Port number for the server")
+	scrubInterval := flag.Duration("scrub-interval", 24*time.Hour, "Interval between background checksum scrubs (0 disables)")
 	flag.Parse()
 
 	// Validate arguments
@@ -32,6 +36,16 @@ func main() {
 	addr := fmt.Sprintf("%s:%d", *host, *port)
 	log.Printf("[STARTING] Storage server on %s", addr)
 
+	// Create your server, rebuilding its index from baseDir
+	server, err := storage.NewServer(baseDir)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to initialize storage: %v", err)
+	}
+
+	if *scrubInterval > 0 {
+		server.StartScrubber(*scrubInterval)
+	}
+
 	// Create gRPC listener
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -41,10 +55,16 @@ func main() {
 	// Create gRPC server
 	grpcServer := grpc.NewServer()
 
-	// Create and register your server
-	server := &storage.Server{BaseDir: baseDir}
+	// Register your server
 	proto.RegisterStorageServer(grpcServer, server)
 
+	// Report liveness on the standard health service, both for the
+	// server as a whole and for the Storage service by name
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(proto.Storage_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
 	// Serve
 	if err := grpcServer.Serve(listener); err != nil {
 		log.Fatalf("[FATAL] Failed to serve: %v", err)
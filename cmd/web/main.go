@@ -6,18 +6,31 @@ import (
 	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"tritontube/internal/web"
 )
 
 func main() {
 	host := flag.String("host", "localhost", "host to listen on")
 	port := flag.Int("port", 8080, "port to listen on")
+	replicas := flag.Int("replicas", 1, "number of storage nodes each file is replicated to (nw content only)")
+	vnodes := flag.Int("vnodes", 1, "number of ring tokens per unit of node weight (nw content only)")
+	workers := flag.Int("transcode-workers", 2, "number of concurrent transcode jobs")
+	ladderPath := flag.String("ladder", "", "JSON file defining the transcode rendition ladder (default 240p-1080p)")
+	hls := flag.Bool("hls", false, "also emit HLS playlists over the DASH segments")
+	transcoderType := flag.String("transcoder", "ffmpeg", "transcoder to use: ffmpeg, or fake for synthetic output without ffmpeg")
+	healthInterval := flag.Duration("health-interval", 5*time.Second, "interval between storage node health probes (nw content only, 0 disables)")
+	healthTimeout := flag.Duration("health-timeout", 2*time.Second, "timeout for one storage node health probe")
+	healthDownAfter := flag.Int("health-down-after", 3, "consecutive failed probes before a storage node is marked down")
+	maxUploadSize := flag.Int64("max-upload-size", web.DefaultMaxUploadSize, "largest accepted upload in bytes")
+	spoolDir := flag.String("spool-dir", filepath.Join(os.TempDir(), "tritontube-spool"), "directory holding uploads waiting to be transcoded")
 	flag.Parse()
 
 	args := flag.Args()
 	if len(args) != 4 {
-		fmt.Println("Usage: ./main -host <host> -port <port> <METADATA_TYPE> <METADATA_OPTIONS> <CONTENT_TYPE> <CONTENT_OPTIONS>")
+		fmt.Println("Usage: ./main -host <host> -port <port> [-replicas <n>] [-vnodes <n>] <METADATA_TYPE> <METADATA_OPTIONS> <CONTENT_TYPE> <CONTENT_OPTIONS>")
 		os.Exit(1)
 	}
 
@@ -27,21 +40,27 @@ func main() {
 	contentOpt := args[3]
 
 	var metadata web.VideoMetadataService
-	var err error
+	var jobs web.JobService
 
 	switch metadataType {
 	case "sqlite":
-		metadata, err = web.NewSQLiteVideoMetadataService(metadataOpt)
+		sqliteMetadata, err := web.NewSQLiteVideoMetadataService(metadataOpt)
+		if err != nil {
+			log.Fatalf("Failed to create metadata service: %v", err)
+		}
+		metadata, jobs = sqliteMetadata, sqliteMetadata
 	case "etcd":
-		metadata, err = web.NewEtcdVideoMetadataService(metadataOpt)
+		etcdMetadata, err := web.NewEtcdVideoMetadataService(metadataOpt)
+		if err != nil {
+			log.Fatalf("Failed to create metadata service: %v", err)
+		}
+		metadata, jobs = etcdMetadata, etcdMetadata
 	default:
 		log.Fatalf("Unsupported metadata type: %s", metadataType)
 	}
-	if err != nil {
-		log.Fatalf("Failed to create metadata service: %v", err)
-	}
 
 	var content web.VideoContentService
+	var nwContent *web.NetworkVideoContentService
 	switch contentType {
 	case "fs":
 		if err := os.MkdirAll(contentOpt, os.ModePerm); err != nil {
@@ -51,22 +70,54 @@ func main() {
 	case "nw":
 		parts := strings.Split(contentOpt, ",")
 		if len(parts) < 2 {
-			log.Fatalf("Invalid CONTENT_OPTIONS for nw: must be in form adminhost:adminport,node1:port1,node2:port2,...")
+			log.Fatalf("Invalid CONTENT_OPTIONS for nw: must be in form adminhost:adminport,node1:port1[=weight],node2:port2[=weight],...")
 		}
 		adminHostPort := parts[0]
 		nodeAddrs := parts[1:]
 
-		nwContent, err := web.NewNetworkVideoContentService(nodeAddrs, adminHostPort)
+		var err error
+		// Both metadata stores also persist ring membership
+		ringStore, _ := metadata.(web.RingStore)
+		nwContent, err = web.NewNetworkVideoContentService(nodeAddrs, adminHostPort, *replicas, *vnodes, ringStore)
 		if err != nil {
 			log.Fatalf("Failed to initialize NetworkVideoContentService: %v", err)
 		}
 		content = nwContent
+		if *healthInterval > 0 {
+			nwContent.StartHealthChecks(*healthInterval, *healthTimeout, *healthDownAfter)
+		}
 
 	default:
 		log.Fatalf("Unsupported content type: %s", contentType)
 	}
 
-	srv := web.NewServer(metadata, content)
+	var transcoder web.Transcoder
+	switch *transcoderType {
+	case "ffmpeg":
+		ladder := web.DefaultLadder
+		if *ladderPath != "" {
+			var err error
+			ladder, err = web.LoadLadder(*ladderPath)
+			if err != nil {
+				log.Fatalf("Failed to load ladder: %v", err)
+			}
+		}
+		transcoder = web.NewFFmpegTranscoder(ladder, *hls)
+	case "fake":
+		transcoder = web.NewFakeTranscoder(*hls)
+	default:
+		log.Fatalf("Unsupported transcoder: %s", *transcoderType)
+	}
+
+	srv := web.NewServer(metadata, content, jobs)
+	srv.SetTranscoder(transcoder)
+	srv.SetMaxUploadSize(*maxUploadSize)
+	if err := srv.StartTranscodeWorkers(*workers, *spoolDir); err != nil {
+		log.Fatalf("Failed to start transcode workers: %v", err)
+	}
+	if nwContent != nil {
+		nwContent.SetVideoDeleter(srv.DeleteVideo)
+	}
 	addr := fmt.Sprintf("%s:%d", *host, *port)
 
 	listener, err := net.Listen("tcp", addr)
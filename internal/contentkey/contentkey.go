@@ -0,0 +1,85 @@
+// Package contentkey validates the (video ID, filename) pairs that name
+// stored files. Storage nodes and the filesystem content service only
+// build paths from a Key, so a client-supplied name can never reach a
+// file outside BaseDir/<videoId>/.
+package contentkey
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"tritontube/internal/videoid"
+)
+
+// ErrInvalid is wrapped by every validation error.
+var ErrInvalid = errors.New("invalid content key")
+
+const maxFilenameLen = 255
+
+// Key is a validated video ID and filename. The zero Key is not valid;
+// obtain one from New.
+type Key struct {
+	videoID  string
+	filename string
+}
+
+// New validates videoID and filename and returns their Key.
+func New(videoID, filename string) (Key, error) {
+	if err := CheckVideoID(videoID); err != nil {
+		return Key{}, err
+	}
+	if err := CheckFilename(filename); err != nil {
+		return Key{}, err
+	}
+	return Key{videoID: videoID, filename: filename}, nil
+}
+
+func (k Key) VideoID() string  { return k.videoID }
+func (k Key) Filename() string { return k.filename }
+
+// Path returns the file's location under baseDir.
+func (k Key) Path(baseDir string) string {
+	return filepath.Join(baseDir, k.videoID, k.filename)
+}
+
+func (k Key) String() string {
+	return k.videoID + "/" + k.filename
+}
+
+// CheckVideoID rejects anything videoid.Valid does not accept.
+func CheckVideoID(videoID string) error {
+	if !videoid.Valid(videoID) {
+		return fmt.Errorf("%w: video ID %q", ErrInvalid, videoID)
+	}
+	return nil
+}
+
+// VideoDir returns the directory holding videoID's files under baseDir.
+func VideoDir(baseDir, videoID string) (string, error) {
+	if err := CheckVideoID(videoID); err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDir, videoID), nil
+}
+
+// CheckFilename accepts a single path element that is not hidden. That
+// rules out "", ".", "..", absolute paths and anything with a separator,
+// and keeps clients away from the dot-prefixed temp and checksum files
+// storage nodes keep next to each video's content.
+func CheckFilename(filename string) error {
+	switch {
+	case filename == "":
+		return fmt.Errorf("%w: empty filename", ErrInvalid)
+	case len(filename) > maxFilenameLen:
+		return fmt.Errorf("%w: filename longer than %d bytes", ErrInvalid, maxFilenameLen)
+	case strings.HasPrefix(filename, "."):
+		return fmt.Errorf("%w: filename %q starts with a dot", ErrInvalid, filename)
+	case strings.ContainsAny(filename, "/\\\x00"):
+		return fmt.Errorf("%w: filename %q contains a separator", ErrInvalid, filename)
+	case filepath.IsAbs(filename) || filepath.VolumeName(filename) != "":
+		return fmt.Errorf("%w: filename %q is absolute", ErrInvalid, filename)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package contentkey
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const baseDir = "/srv/storage"
+
+// checkInside fails unless path is exactly one file inside one video
+// directory under baseDir.
+func checkInside(t *testing.T, path string) {
+	t.Helper()
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		t.Fatalf("path %q escapes %s", path, baseDir)
+	}
+	if parts := strings.Split(rel, string(filepath.Separator)); len(parts) != 2 {
+		t.Fatalf("path %q is %d levels below %s, want 2", path, len(parts), baseDir)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		videoID, filename string
+		ok                bool
+	}{
+		{"01JAAAAAAAAAAAAAAAAAAAAAAA", "manifest.mpd", true},
+		{"Team-Demo_v1.2", "chunk-0-00001.m4s", true},
+		{"intro", "../manifest.mpd", false},
+		{"intro", "a/b", false},
+		{"intro", `a\b`, false},
+		{"intro", "/etc/passwd", false},
+		{"intro", "seg\x00.m4s", false},
+		{"intro", ".sha256-manifest.mpd", false},
+		{"intro", "..", false},
+		{"intro", "", false},
+		{"..", "manifest.mpd", false},
+		{"../intro", "manifest.mpd", false},
+		{"/etc", "passwd", false},
+		{"in\x00tro", "manifest.mpd", false},
+		{"Team Demo v1.2", "manifest.mpd", false},
+		{"", "manifest.mpd", false},
+	}
+	for _, tt := range tests {
+		key, err := New(tt.videoID, tt.filename)
+		if (err == nil) != tt.ok {
+			t.Errorf("New(%q, %q) error = %v, want ok %v", tt.videoID, tt.filename, err, tt.ok)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalid) {
+			t.Errorf("New(%q, %q) error %v does not wrap ErrInvalid", tt.videoID, tt.filename, err)
+		}
+		if err == nil {
+			checkInside(t, key.Path(baseDir))
+		}
+	}
+}
+
+func FuzzNew(f *testing.F) {
+	f.Add("01JAAAAAAAAAAAAAAAAAAAAAAA", "manifest.mpd")
+	f.Add("Team-Demo_v1.2", "chunk-0-00001.m4s")
+	f.Add("..", "x")
+	f.Add("a", "../../etc/passwd")
+	f.Add("a/..", "b")
+	f.Add("a", "b\x00c")
+	f.Add("C:", `\windows`)
+	f.Fuzz(func(t *testing.T, videoID, filename string) {
+		key, err := New(videoID, filename)
+		if err != nil {
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("New(%q, %q) error %v does not wrap ErrInvalid", videoID, filename, err)
+			}
+			return
+		}
+		if key.VideoID() != videoID || key.Filename() != filename {
+			t.Fatalf("New(%q, %q) = %q", videoID, filename, key)
+		}
+		checkInside(t, key.Path(baseDir))
+		if dir, err := VideoDir(baseDir, videoID); err != nil || dir != filepath.Dir(key.Path(baseDir)) {
+			t.Fatalf("VideoDir(%q) = %q, %v; key path %q", videoID, dir, err, key.Path(baseDir))
+		}
+	})
+}
+
+func FuzzCheckFilename(f *testing.F) {
+	f.Add("manifest.mpd")
+	f.Add("..")
+	f.Add(".upload-manifest.mpd.123")
+	f.Add("a/b")
+	f.Add("/abs")
+	f.Add("nul\x00")
+	f.Fuzz(func(t *testing.T, filename string) {
+		err := CheckFilename(filename)
+		if err != nil {
+			if !errors.Is(err, ErrInvalid) {
+				t.Fatalf("CheckFilename(%q) error %v does not wrap ErrInvalid", filename, err)
+			}
+			return
+		}
+		if strings.HasPrefix(filename, ".") || strings.ContainsAny(filename, "/\\\x00") {
+			t.Fatalf("CheckFilename accepted %q", filename)
+		}
+		path := filepath.Join(baseDir, "video", filename)
+		if filepath.Dir(path) != filepath.Join(baseDir, "video") {
+			t.Fatalf("filename %q leaves its video directory: %q", filename, path)
+		}
+	})
+}
@@ -24,6 +24,7 @@ const (
 type AddNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	Weight        int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,9 +66,20 @@ func (x *AddNodeRequest) GetNodeAddress() string {
 	return ""
 }
 
+func (x *AddNodeRequest) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+// AddNode and RemoveNode return once the rebalance has started; follow
+// it with GetOperation or WatchOperation. migrated_file_count is no
+// longer filled in.
 type AddNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
+	OperationId       string                 `protobuf:"bytes,2,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -109,6 +121,13 @@ func (x *AddNodeResponse) GetMigratedFileCount() int32 {
 	return 0
 }
 
+func (x *AddNodeResponse) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
 type RemoveNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeAddress   string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
@@ -156,6 +175,7 @@ func (x *RemoveNodeRequest) GetNodeAddress() string {
 type RemoveNodeResponse struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	MigratedFileCount int32                  `protobuf:"varint,1,opt,name=migrated_file_count,json=migratedFileCount,proto3" json:"migrated_file_count,omitempty"`
+	OperationId       string                 `protobuf:"bytes,2,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
 	unknownFields     protoimpl.UnknownFields
 	sizeCache         protoimpl.SizeCache
 }
@@ -197,6 +217,13 @@ func (x *RemoveNodeResponse) GetMigratedFileCount() int32 {
 	return 0
 }
 
+func (x *RemoveNodeResponse) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
 type ListNodesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -234,8 +261,14 @@ func (*ListNodesRequest) Descriptor() ([]byte, []int) {
 }
 
 type ListNodesResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Nodes         []string               `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Nodes       []string               `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Statuses    []*NodeStatus          `protobuf:"bytes,2,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	RingVersion int64                  `protobuf:"varint,3,opt,name=ring_version,json=ringVersion,proto3" json:"ring_version,omitempty"`
+	// Set while files move to a new membership; nodes then lists the
+	// members of both the old and new ring.
+	Rebalancing   bool   `protobuf:"varint,4,opt,name=rebalancing,proto3" json:"rebalancing,omitempty"`
+	OperationId   string `protobuf:"bytes,5,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -277,28 +310,745 @@ func (x *ListNodesResponse) GetNodes() []string {
 	return nil
 }
 
+func (x *ListNodesResponse) GetStatuses() []*NodeStatus {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+func (x *ListNodesResponse) GetRingVersion() int64 {
+	if x != nil {
+		return x.RingVersion
+	}
+	return 0
+}
+
+func (x *ListNodesResponse) GetRebalancing() bool {
+	if x != nil {
+		return x.Rebalancing
+	}
+	return false
+}
+
+func (x *ListNodesResponse) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
+type NodeStatus struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Address string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Weight  int32                  `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	// "up", "suspect" or "down", from the web server's health probes.
+	State               string `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	ConsecutiveFailures int32  `protobuf:"varint,4,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	LastSeenUnix        int64  `protobuf:"varint,5,opt,name=last_seen_unix,json=lastSeenUnix,proto3" json:"last_seen_unix,omitempty"`
+	LastError           string `protobuf:"bytes,6,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *NodeStatus) Reset() {
+	*x = NodeStatus{}
+	mi := &file_proto_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeStatus) ProtoMessage() {}
+
+func (x *NodeStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeStatus.ProtoReflect.Descriptor instead.
+func (*NodeStatus) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NodeStatus) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *NodeStatus) GetConsecutiveFailures() int32 {
+	if x != nil {
+		return x.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetLastSeenUnix() int64 {
+	if x != nil {
+		return x.LastSeenUnix
+	}
+	return 0
+}
+
+func (x *NodeStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+type DeleteVideoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	VideoId       string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteVideoRequest) Reset() {
+	*x = DeleteVideoRequest{}
+	mi := &file_proto_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteVideoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteVideoRequest) ProtoMessage() {}
+
+func (x *DeleteVideoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteVideoRequest.ProtoReflect.Descriptor instead.
+func (*DeleteVideoRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteVideoRequest) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+type DeleteVideoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteVideoResponse) Reset() {
+	*x = DeleteVideoResponse{}
+	mi := &file_proto_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteVideoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteVideoResponse) ProtoMessage() {}
+
+func (x *DeleteVideoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteVideoResponse.ProtoReflect.Descriptor instead.
+func (*DeleteVideoResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{8}
+}
+
+type ScrubClusterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         bool                   `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	Repair        bool                   `protobuf:"varint,2,opt,name=repair,proto3" json:"repair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubClusterRequest) Reset() {
+	*x = ScrubClusterRequest{}
+	mi := &file_proto_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubClusterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubClusterRequest) ProtoMessage() {}
+
+func (x *ScrubClusterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubClusterRequest.ProtoReflect.Descriptor instead.
+func (*ScrubClusterRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ScrubClusterRequest) GetStart() bool {
+	if x != nil {
+		return x.Start
+	}
+	return false
+}
+
+func (x *ScrubClusterRequest) GetRepair() bool {
+	if x != nil {
+		return x.Repair
+	}
+	return false
+}
+
+type ScrubFinding struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	VideoId  string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	Filename string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Empty if the node has no stored checksum for the file.
+	ExpectedSha256 string `protobuf:"bytes,3,opt,name=expected_sha256,json=expectedSha256,proto3" json:"expected_sha256,omitempty"`
+	ActualSha256   string `protobuf:"bytes,4,opt,name=actual_sha256,json=actualSha256,proto3" json:"actual_sha256,omitempty"`
+	// Empty if no repair was attempted, otherwise "repaired" or the error.
+	Repair        string `protobuf:"bytes,5,opt,name=repair,proto3" json:"repair,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubFinding) Reset() {
+	*x = ScrubFinding{}
+	mi := &file_proto_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubFinding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubFinding) ProtoMessage() {}
+
+func (x *ScrubFinding) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubFinding.ProtoReflect.Descriptor instead.
+func (*ScrubFinding) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ScrubFinding) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+func (x *ScrubFinding) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ScrubFinding) GetExpectedSha256() string {
+	if x != nil {
+		return x.ExpectedSha256
+	}
+	return ""
+}
+
+func (x *ScrubFinding) GetActualSha256() string {
+	if x != nil {
+		return x.ActualSha256
+	}
+	return ""
+}
+
+func (x *ScrubFinding) GetRepair() string {
+	if x != nil {
+		return x.Repair
+	}
+	return ""
+}
+
+type NodeScrubReport struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	NodeAddress       string                 `protobuf:"bytes,1,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	Error             string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Running           bool                   `protobuf:"varint,3,opt,name=running,proto3" json:"running,omitempty"`
+	FilesScanned      int64                  `protobuf:"varint,4,opt,name=files_scanned,json=filesScanned,proto3" json:"files_scanned,omitempty"`
+	FilesTotal        int64                  `protobuf:"varint,5,opt,name=files_total,json=filesTotal,proto3" json:"files_total,omitempty"`
+	LastCompletedUnix int64                  `protobuf:"varint,6,opt,name=last_completed_unix,json=lastCompletedUnix,proto3" json:"last_completed_unix,omitempty"`
+	Findings          []*ScrubFinding        `protobuf:"bytes,7,rep,name=findings,proto3" json:"findings,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *NodeScrubReport) Reset() {
+	*x = NodeScrubReport{}
+	mi := &file_proto_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeScrubReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeScrubReport) ProtoMessage() {}
+
+func (x *NodeScrubReport) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeScrubReport.ProtoReflect.Descriptor instead.
+func (*NodeScrubReport) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *NodeScrubReport) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *NodeScrubReport) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *NodeScrubReport) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *NodeScrubReport) GetFilesScanned() int64 {
+	if x != nil {
+		return x.FilesScanned
+	}
+	return 0
+}
+
+func (x *NodeScrubReport) GetFilesTotal() int64 {
+	if x != nil {
+		return x.FilesTotal
+	}
+	return 0
+}
+
+func (x *NodeScrubReport) GetLastCompletedUnix() int64 {
+	if x != nil {
+		return x.LastCompletedUnix
+	}
+	return 0
+}
+
+func (x *NodeScrubReport) GetFindings() []*ScrubFinding {
+	if x != nil {
+		return x.Findings
+	}
+	return nil
+}
+
+type ScrubClusterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*NodeScrubReport     `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	RepairedCount int32                  `protobuf:"varint,2,opt,name=repaired_count,json=repairedCount,proto3" json:"repaired_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubClusterResponse) Reset() {
+	*x = ScrubClusterResponse{}
+	mi := &file_proto_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubClusterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubClusterResponse) ProtoMessage() {}
+
+func (x *ScrubClusterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubClusterResponse.ProtoReflect.Descriptor instead.
+func (*ScrubClusterResponse) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ScrubClusterResponse) GetNodes() []*NodeScrubReport {
+	if x != nil {
+		return x.Nodes
+	}
+	return nil
+}
+
+func (x *ScrubClusterResponse) GetRepairedCount() int32 {
+	if x != nil {
+		return x.RepairedCount
+	}
+	return 0
+}
+
+type GetOperationRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OperationId   string                 `protobuf:"bytes,1,opt,name=operation_id,json=operationId,proto3" json:"operation_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOperationRequest) Reset() {
+	*x = GetOperationRequest{}
+	mi := &file_proto_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOperationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOperationRequest) ProtoMessage() {}
+
+func (x *GetOperationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOperationRequest.ProtoReflect.Descriptor instead.
+func (*GetOperationRequest) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetOperationRequest) GetOperationId() string {
+	if x != nil {
+		return x.OperationId
+	}
+	return ""
+}
+
+type Operation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// "add", "remove", or "resume" for a rebalance found unfinished.
+	Kind        string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	NodeAddress string `protobuf:"bytes,3,opt,name=node_address,json=nodeAddress,proto3" json:"node_address,omitempty"`
+	Weight      int32  `protobuf:"varint,4,opt,name=weight,proto3" json:"weight,omitempty"`
+	// "running", "done" or "failed".
+	Status        string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	RingVersion   int64  `protobuf:"varint,7,opt,name=ring_version,json=ringVersion,proto3" json:"ring_version,omitempty"`
+	FilesTotal    int64  `protobuf:"varint,8,opt,name=files_total,json=filesTotal,proto3" json:"files_total,omitempty"`
+	FilesMoved    int64  `protobuf:"varint,9,opt,name=files_moved,json=filesMoved,proto3" json:"files_moved,omitempty"`
+	BytesMoved    int64  `protobuf:"varint,10,opt,name=bytes_moved,json=bytesMoved,proto3" json:"bytes_moved,omitempty"`
+	FilesFailed   int64  `protobuf:"varint,11,opt,name=files_failed,json=filesFailed,proto3" json:"files_failed,omitempty"`
+	CreatedUnix   int64  `protobuf:"varint,12,opt,name=created_unix,json=createdUnix,proto3" json:"created_unix,omitempty"`
+	UpdatedUnix   int64  `protobuf:"varint,13,opt,name=updated_unix,json=updatedUnix,proto3" json:"updated_unix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+	mi := &file_proto_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Operation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
+func (*Operation) Descriptor() ([]byte, []int) {
+	return file_proto_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Operation) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Operation) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Operation) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *Operation) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *Operation) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Operation) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Operation) GetRingVersion() int64 {
+	if x != nil {
+		return x.RingVersion
+	}
+	return 0
+}
+
+func (x *Operation) GetFilesTotal() int64 {
+	if x != nil {
+		return x.FilesTotal
+	}
+	return 0
+}
+
+func (x *Operation) GetFilesMoved() int64 {
+	if x != nil {
+		return x.FilesMoved
+	}
+	return 0
+}
+
+func (x *Operation) GetBytesMoved() int64 {
+	if x != nil {
+		return x.BytesMoved
+	}
+	return 0
+}
+
+func (x *Operation) GetFilesFailed() int64 {
+	if x != nil {
+		return x.FilesFailed
+	}
+	return 0
+}
+
+func (x *Operation) GetCreatedUnix() int64 {
+	if x != nil {
+		return x.CreatedUnix
+	}
+	return 0
+}
+
+func (x *Operation) GetUpdatedUnix() int64 {
+	if x != nil {
+		return x.UpdatedUnix
+	}
+	return 0
+}
+
 var File_proto_admin_proto protoreflect.FileDescriptor
 
 const file_proto_admin_proto_rawDesc = "" +
 	"\n" +
 	"\x11proto/admin.proto\x12\n" +
-	"tritontube\"3\n" +
+	"tritontube\"K\n" +
 	"\x0eAddNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"A\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\"d\n" +
 	"\x0fAddNodeResponse\x12.\n" +
-	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"6\n" +
+	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\x12!\n" +
+	"\foperation_id\x18\x02 \x01(\tR\voperationId\"6\n" +
 	"\x11RemoveNodeRequest\x12!\n" +
-	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"D\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\"g\n" +
 	"\x12RemoveNodeResponse\x12.\n" +
-	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\"\x12\n" +
-	"\x10ListNodesRequest\")\n" +
+	"\x13migrated_file_count\x18\x01 \x01(\x05R\x11migratedFileCount\x12!\n" +
+	"\foperation_id\x18\x02 \x01(\tR\voperationId\"\x12\n" +
+	"\x10ListNodesRequest\"\xc5\x01\n" +
 	"\x11ListNodesResponse\x12\x14\n" +
-	"\x05nodes\x18\x01 \x03(\tR\x05nodes2\xf5\x01\n" +
+	"\x05nodes\x18\x01 \x03(\tR\x05nodes\x122\n" +
+	"\bstatuses\x18\x02 \x03(\v2\x16.tritontube.NodeStatusR\bstatuses\x12!\n" +
+	"\fring_version\x18\x03 \x01(\x03R\vringVersion\x12 \n" +
+	"\vrebalancing\x18\x04 \x01(\bR\vrebalancing\x12!\n" +
+	"\foperation_id\x18\x05 \x01(\tR\voperationId\"\xcc\x01\n" +
+	"\n" +
+	"NodeStatus\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x121\n" +
+	"\x14consecutive_failures\x18\x04 \x01(\x05R\x13consecutiveFailures\x12$\n" +
+	"\x0elast_seen_unix\x18\x05 \x01(\x03R\flastSeenUnix\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x06 \x01(\tR\tlastError\"/\n" +
+	"\x12DeleteVideoRequest\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\"\x15\n" +
+	"\x13DeleteVideoResponse\"C\n" +
+	"\x13ScrubClusterRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\bR\x05start\x12\x16\n" +
+	"\x06repair\x18\x02 \x01(\bR\x06repair\"\xab\x01\n" +
+	"\fScrubFinding\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12'\n" +
+	"\x0fexpected_sha256\x18\x03 \x01(\tR\x0eexpectedSha256\x12#\n" +
+	"\ractual_sha256\x18\x04 \x01(\tR\factualSha256\x12\x16\n" +
+	"\x06repair\x18\x05 \x01(\tR\x06repair\"\x90\x02\n" +
+	"\x0fNodeScrubReport\x12!\n" +
+	"\fnode_address\x18\x01 \x01(\tR\vnodeAddress\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x18\n" +
+	"\arunning\x18\x03 \x01(\bR\arunning\x12#\n" +
+	"\rfiles_scanned\x18\x04 \x01(\x03R\ffilesScanned\x12\x1f\n" +
+	"\vfiles_total\x18\x05 \x01(\x03R\n" +
+	"filesTotal\x12.\n" +
+	"\x13last_completed_unix\x18\x06 \x01(\x03R\x11lastCompletedUnix\x124\n" +
+	"\bfindings\x18\a \x03(\v2\x18.tritontube.ScrubFindingR\bfindings\"p\n" +
+	"\x14ScrubClusterResponse\x121\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x1b.tritontube.NodeScrubReportR\x05nodes\x12%\n" +
+	"\x0erepaired_count\x18\x02 \x01(\x05R\rrepairedCount\"8\n" +
+	"\x13GetOperationRequest\x12!\n" +
+	"\foperation_id\x18\x01 \x01(\tR\voperationId\"\x87\x03\n" +
+	"\tOperation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12!\n" +
+	"\fnode_address\x18\x03 \x01(\tR\vnodeAddress\x12\x16\n" +
+	"\x06weight\x18\x04 \x01(\x05R\x06weight\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12!\n" +
+	"\fring_version\x18\a \x01(\x03R\vringVersion\x12\x1f\n" +
+	"\vfiles_total\x18\b \x01(\x03R\n" +
+	"filesTotal\x12\x1f\n" +
+	"\vfiles_moved\x18\t \x01(\x03R\n" +
+	"filesMoved\x12\x1f\n" +
+	"\vbytes_moved\x18\n" +
+	" \x01(\x03R\n" +
+	"bytesMoved\x12!\n" +
+	"\ffiles_failed\x18\v \x01(\x03R\vfilesFailed\x12!\n" +
+	"\fcreated_unix\x18\f \x01(\x03R\vcreatedUnix\x12!\n" +
+	"\fupdated_unix\x18\r \x01(\x03R\vupdatedUnix2\xa5\x04\n" +
 	"\x18VideoContentAdminService\x12B\n" +
 	"\aAddNode\x12\x1a.tritontube.AddNodeRequest\x1a\x1b.tritontube.AddNodeResponse\x12K\n" +
 	"\n" +
 	"RemoveNode\x12\x1d.tritontube.RemoveNodeRequest\x1a\x1e.tritontube.RemoveNodeResponse\x12H\n" +
-	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponseB\x16Z\x14internal/proto;protob\x06proto3"
+	"\tListNodes\x12\x1c.tritontube.ListNodesRequest\x1a\x1d.tritontube.ListNodesResponse\x12N\n" +
+	"\vDeleteVideo\x12\x1e.tritontube.DeleteVideoRequest\x1a\x1f.tritontube.DeleteVideoResponse\x12J\n" +
+	"\x05Scrub\x12\x1f.tritontube.ScrubClusterRequest\x1a .tritontube.ScrubClusterResponse\x12F\n" +
+	"\fGetOperation\x12\x1f.tritontube.GetOperationRequest\x1a\x15.tritontube.Operation\x12J\n" +
+	"\x0eWatchOperation\x12\x1f.tritontube.GetOperationRequest\x1a\x15.tritontube.Operation0\x01B\x16Z\x14internal/proto;protob\x06proto3"
 
 var (
 	file_proto_admin_proto_rawDescOnce sync.Once
@@ -312,27 +1062,47 @@ func file_proto_admin_proto_rawDescGZIP() []byte {
 	return file_proto_admin_proto_rawDescData
 }
 
-var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_proto_admin_proto_goTypes = []any{
-	(*AddNodeRequest)(nil),     // 0: tritontube.AddNodeRequest
-	(*AddNodeResponse)(nil),    // 1: tritontube.AddNodeResponse
-	(*RemoveNodeRequest)(nil),  // 2: tritontube.RemoveNodeRequest
-	(*RemoveNodeResponse)(nil), // 3: tritontube.RemoveNodeResponse
-	(*ListNodesRequest)(nil),   // 4: tritontube.ListNodesRequest
-	(*ListNodesResponse)(nil),  // 5: tritontube.ListNodesResponse
+	(*AddNodeRequest)(nil),       // 0: tritontube.AddNodeRequest
+	(*AddNodeResponse)(nil),      // 1: tritontube.AddNodeResponse
+	(*RemoveNodeRequest)(nil),    // 2: tritontube.RemoveNodeRequest
+	(*RemoveNodeResponse)(nil),   // 3: tritontube.RemoveNodeResponse
+	(*ListNodesRequest)(nil),     // 4: tritontube.ListNodesRequest
+	(*ListNodesResponse)(nil),    // 5: tritontube.ListNodesResponse
+	(*NodeStatus)(nil),           // 6: tritontube.NodeStatus
+	(*DeleteVideoRequest)(nil),   // 7: tritontube.DeleteVideoRequest
+	(*DeleteVideoResponse)(nil),  // 8: tritontube.DeleteVideoResponse
+	(*ScrubClusterRequest)(nil),  // 9: tritontube.ScrubClusterRequest
+	(*ScrubFinding)(nil),         // 10: tritontube.ScrubFinding
+	(*NodeScrubReport)(nil),      // 11: tritontube.NodeScrubReport
+	(*ScrubClusterResponse)(nil), // 12: tritontube.ScrubClusterResponse
+	(*GetOperationRequest)(nil),  // 13: tritontube.GetOperationRequest
+	(*Operation)(nil),            // 14: tritontube.Operation
 }
 var file_proto_admin_proto_depIdxs = []int32{
-	0, // 0: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
-	2, // 1: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
-	4, // 2: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
-	1, // 3: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
-	3, // 4: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
-	5, // 5: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	6,  // 0: tritontube.ListNodesResponse.statuses:type_name -> tritontube.NodeStatus
+	10, // 1: tritontube.NodeScrubReport.findings:type_name -> tritontube.ScrubFinding
+	11, // 2: tritontube.ScrubClusterResponse.nodes:type_name -> tritontube.NodeScrubReport
+	0,  // 3: tritontube.VideoContentAdminService.AddNode:input_type -> tritontube.AddNodeRequest
+	2,  // 4: tritontube.VideoContentAdminService.RemoveNode:input_type -> tritontube.RemoveNodeRequest
+	4,  // 5: tritontube.VideoContentAdminService.ListNodes:input_type -> tritontube.ListNodesRequest
+	7,  // 6: tritontube.VideoContentAdminService.DeleteVideo:input_type -> tritontube.DeleteVideoRequest
+	9,  // 7: tritontube.VideoContentAdminService.Scrub:input_type -> tritontube.ScrubClusterRequest
+	13, // 8: tritontube.VideoContentAdminService.GetOperation:input_type -> tritontube.GetOperationRequest
+	13, // 9: tritontube.VideoContentAdminService.WatchOperation:input_type -> tritontube.GetOperationRequest
+	1,  // 10: tritontube.VideoContentAdminService.AddNode:output_type -> tritontube.AddNodeResponse
+	3,  // 11: tritontube.VideoContentAdminService.RemoveNode:output_type -> tritontube.RemoveNodeResponse
+	5,  // 12: tritontube.VideoContentAdminService.ListNodes:output_type -> tritontube.ListNodesResponse
+	8,  // 13: tritontube.VideoContentAdminService.DeleteVideo:output_type -> tritontube.DeleteVideoResponse
+	12, // 14: tritontube.VideoContentAdminService.Scrub:output_type -> tritontube.ScrubClusterResponse
+	14, // 15: tritontube.VideoContentAdminService.GetOperation:output_type -> tritontube.Operation
+	14, // 16: tritontube.VideoContentAdminService.WatchOperation:output_type -> tritontube.Operation
+	10, // [10:17] is the sub-list for method output_type
+	3,  // [3:10] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_admin_proto_init() }
@@ -346,7 +1116,7 @@ func file_proto_admin_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_admin_proto_rawDesc), len(file_proto_admin_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,9 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	VideoContentAdminService_AddNode_FullMethodName    = "/tritontube.VideoContentAdminService/AddNode"
-	VideoContentAdminService_RemoveNode_FullMethodName = "/tritontube.VideoContentAdminService/RemoveNode"
-	VideoContentAdminService_ListNodes_FullMethodName  = "/tritontube.VideoContentAdminService/ListNodes"
+	VideoContentAdminService_AddNode_FullMethodName        = "/tritontube.VideoContentAdminService/AddNode"
+	VideoContentAdminService_RemoveNode_FullMethodName     = "/tritontube.VideoContentAdminService/RemoveNode"
+	VideoContentAdminService_ListNodes_FullMethodName      = "/tritontube.VideoContentAdminService/ListNodes"
+	VideoContentAdminService_DeleteVideo_FullMethodName    = "/tritontube.VideoContentAdminService/DeleteVideo"
+	VideoContentAdminService_Scrub_FullMethodName          = "/tritontube.VideoContentAdminService/Scrub"
+	VideoContentAdminService_GetOperation_FullMethodName   = "/tritontube.VideoContentAdminService/GetOperation"
+	VideoContentAdminService_WatchOperation_FullMethodName = "/tritontube.VideoContentAdminService/WatchOperation"
 )
 
 // VideoContentAdminServiceClient is the client API for VideoContentAdminService service.
@@ -31,6 +35,12 @@ type VideoContentAdminServiceClient interface {
 	AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error)
 	RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error)
 	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesResponse, error)
+	DeleteVideo(ctx context.Context, in *DeleteVideoRequest, opts ...grpc.CallOption) (*DeleteVideoResponse, error)
+	Scrub(ctx context.Context, in *ScrubClusterRequest, opts ...grpc.CallOption) (*ScrubClusterResponse, error)
+	GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error)
+	// WatchOperation sends the operation whenever it changes, until it
+	// is done or failed.
+	WatchOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Operation], error)
 }
 
 type videoContentAdminServiceClient struct {
@@ -71,6 +81,55 @@ func (c *videoContentAdminServiceClient) ListNodes(ctx context.Context, in *List
 	return out, nil
 }
 
+func (c *videoContentAdminServiceClient) DeleteVideo(ctx context.Context, in *DeleteVideoRequest, opts ...grpc.CallOption) (*DeleteVideoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteVideoResponse)
+	err := c.cc.Invoke(ctx, VideoContentAdminService_DeleteVideo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoContentAdminServiceClient) Scrub(ctx context.Context, in *ScrubClusterRequest, opts ...grpc.CallOption) (*ScrubClusterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScrubClusterResponse)
+	err := c.cc.Invoke(ctx, VideoContentAdminService_Scrub_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoContentAdminServiceClient) GetOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (*Operation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Operation)
+	err := c.cc.Invoke(ctx, VideoContentAdminService_GetOperation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoContentAdminServiceClient) WatchOperation(ctx context.Context, in *GetOperationRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Operation], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VideoContentAdminService_ServiceDesc.Streams[0], VideoContentAdminService_WatchOperation_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetOperationRequest, Operation]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentAdminService_WatchOperationClient = grpc.ServerStreamingClient[Operation]
+
 // VideoContentAdminServiceServer is the server API for VideoContentAdminService service.
 // All implementations must embed UnimplementedVideoContentAdminServiceServer
 // for forward compatibility.
@@ -78,6 +137,12 @@ type VideoContentAdminServiceServer interface {
 	AddNode(context.Context, *AddNodeRequest) (*AddNodeResponse, error)
 	RemoveNode(context.Context, *RemoveNodeRequest) (*RemoveNodeResponse, error)
 	ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error)
+	DeleteVideo(context.Context, *DeleteVideoRequest) (*DeleteVideoResponse, error)
+	Scrub(context.Context, *ScrubClusterRequest) (*ScrubClusterResponse, error)
+	GetOperation(context.Context, *GetOperationRequest) (*Operation, error)
+	// WatchOperation sends the operation whenever it changes, until it
+	// is done or failed.
+	WatchOperation(*GetOperationRequest, grpc.ServerStreamingServer[Operation]) error
 	mustEmbedUnimplementedVideoContentAdminServiceServer()
 }
 
@@ -97,6 +162,18 @@ func (UnimplementedVideoContentAdminServiceServer) RemoveNode(context.Context, *
 func (UnimplementedVideoContentAdminServiceServer) ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListNodes not implemented")
 }
+func (UnimplementedVideoContentAdminServiceServer) DeleteVideo(context.Context, *DeleteVideoRequest) (*DeleteVideoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteVideo not implemented")
+}
+func (UnimplementedVideoContentAdminServiceServer) Scrub(context.Context, *ScrubClusterRequest) (*ScrubClusterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scrub not implemented")
+}
+func (UnimplementedVideoContentAdminServiceServer) GetOperation(context.Context, *GetOperationRequest) (*Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOperation not implemented")
+}
+func (UnimplementedVideoContentAdminServiceServer) WatchOperation(*GetOperationRequest, grpc.ServerStreamingServer[Operation]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOperation not implemented")
+}
 func (UnimplementedVideoContentAdminServiceServer) mustEmbedUnimplementedVideoContentAdminServiceServer() {
 }
 func (UnimplementedVideoContentAdminServiceServer) testEmbeddedByValue() {}
@@ -173,6 +250,71 @@ func _VideoContentAdminService_ListNodes_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _VideoContentAdminService_DeleteVideo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteVideoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentAdminServiceServer).DeleteVideo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentAdminService_DeleteVideo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentAdminServiceServer).DeleteVideo(ctx, req.(*DeleteVideoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoContentAdminService_Scrub_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScrubClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentAdminServiceServer).Scrub(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentAdminService_Scrub_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentAdminServiceServer).Scrub(ctx, req.(*ScrubClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoContentAdminService_GetOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoContentAdminServiceServer).GetOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoContentAdminService_GetOperation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoContentAdminServiceServer).GetOperation(ctx, req.(*GetOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoContentAdminService_WatchOperation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetOperationRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoContentAdminServiceServer).WatchOperation(m, &grpc.GenericServerStream[GetOperationRequest, Operation]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VideoContentAdminService_WatchOperationServer = grpc.ServerStreamingServer[Operation]
+
 // VideoContentAdminService_ServiceDesc is the grpc.ServiceDesc for VideoContentAdminService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -192,7 +334,25 @@ var VideoContentAdminService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListNodes",
 			Handler:    _VideoContentAdminService_ListNodes_Handler,
 		},
+		{
+			MethodName: "DeleteVideo",
+			Handler:    _VideoContentAdminService_DeleteVideo_Handler,
+		},
+		{
+			MethodName: "Scrub",
+			Handler:    _VideoContentAdminService_Scrub_Handler,
+		},
+		{
+			MethodName: "GetOperation",
+			Handler:    _VideoContentAdminService_GetOperation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOperation",
+			Handler:       _VideoContentAdminService_WatchOperation_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/admin.proto",
 }
@@ -22,10 +22,20 @@ const (
 )
 
 type FileChunk struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VideoId       string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
-	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
-	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	VideoId  string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	Filename string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Data     []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	// Total file size and hex SHA-256 of the whole file, set on the first
+	// chunk of a Download; sha256 is empty if the node has no checksum for
+	// it. On the first chunk of an Upload, sha256 is the digest the node
+	// must receive, or it discards the file.
+	Size   int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256 string `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Set on the first chunk of an Upload copying a file that has no
+	// checksum: the node stores it without one, so it stays unverified
+	// rather than being vouched for by a digest of unchecked bytes.
+	Unverified    bool `protobuf:"varint,6,opt,name=unverified,proto3" json:"unverified,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -81,10 +91,34 @@ func (x *FileChunk) GetData() []byte {
 	return nil
 }
 
+func (x *FileChunk) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileChunk) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileChunk) GetUnverified() bool {
+	if x != nil {
+		return x.Unverified
+	}
+	return false
+}
+
 type FileRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	VideoId       string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
-	Filename      string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	VideoId  string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	Filename string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Byte range to download; length 0 means through the end of the file.
+	Offset        int64 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Length        int64 `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -133,16 +167,93 @@ func (x *FileRequest) GetFilename() string {
 	return ""
 }
 
+func (x *FileRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileRequest) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+type FileInfo struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Size            int64                  `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	ModTimeUnixNano int64                  `protobuf:"varint,2,opt,name=mod_time_unix_nano,json=modTimeUnixNano,proto3" json:"mod_time_unix_nano,omitempty"`
+	// Empty if the node has no checksum for the file.
+	Sha256        string `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_proto_storage_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileInfo) GetModTimeUnixNano() int64 {
+	if x != nil {
+		return x.ModTimeUnixNano
+	}
+	return 0
+}
+
+func (x *FileInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
 type UploadAck struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	// Hex SHA-256 of the bytes the node stored.
+	Sha256        string `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UploadAck) Reset() {
 	*x = UploadAck{}
-	mi := &file_proto_storage_proto_msgTypes[2]
+	mi := &file_proto_storage_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -154,7 +265,7 @@ func (x *UploadAck) String() string {
 func (*UploadAck) ProtoMessage() {}
 
 func (x *UploadAck) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[2]
+	mi := &file_proto_storage_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -167,7 +278,7 @@ func (x *UploadAck) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UploadAck.ProtoReflect.Descriptor instead.
 func (*UploadAck) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{2}
+	return file_proto_storage_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *UploadAck) GetSuccess() bool {
@@ -177,6 +288,13 @@ func (x *UploadAck) GetSuccess() bool {
 	return false
 }
 
+func (x *UploadAck) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
 type ListVideosRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -185,7 +303,7 @@ type ListVideosRequest struct {
 
 func (x *ListVideosRequest) Reset() {
 	*x = ListVideosRequest{}
-	mi := &file_proto_storage_proto_msgTypes[3]
+	mi := &file_proto_storage_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -197,7 +315,7 @@ func (x *ListVideosRequest) String() string {
 func (*ListVideosRequest) ProtoMessage() {}
 
 func (x *ListVideosRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[3]
+	mi := &file_proto_storage_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -210,7 +328,7 @@ func (x *ListVideosRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListVideosRequest.ProtoReflect.Descriptor instead.
 func (*ListVideosRequest) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{3}
+	return file_proto_storage_proto_rawDescGZIP(), []int{4}
 }
 
 type ListVideosResponse struct {
@@ -222,7 +340,7 @@ type ListVideosResponse struct {
 
 func (x *ListVideosResponse) Reset() {
 	*x = ListVideosResponse{}
-	mi := &file_proto_storage_proto_msgTypes[4]
+	mi := &file_proto_storage_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -234,7 +352,7 @@ func (x *ListVideosResponse) String() string {
 func (*ListVideosResponse) ProtoMessage() {}
 
 func (x *ListVideosResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[4]
+	mi := &file_proto_storage_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -247,7 +365,7 @@ func (x *ListVideosResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListVideosResponse.ProtoReflect.Descriptor instead.
 func (*ListVideosResponse) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{4}
+	return file_proto_storage_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *ListVideosResponse) GetVideoIds() []string {
@@ -266,7 +384,7 @@ type ListVideoFilesRequest struct {
 
 func (x *ListVideoFilesRequest) Reset() {
 	*x = ListVideoFilesRequest{}
-	mi := &file_proto_storage_proto_msgTypes[5]
+	mi := &file_proto_storage_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -278,7 +396,7 @@ func (x *ListVideoFilesRequest) String() string {
 func (*ListVideoFilesRequest) ProtoMessage() {}
 
 func (x *ListVideoFilesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[5]
+	mi := &file_proto_storage_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -291,7 +409,7 @@ func (x *ListVideoFilesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListVideoFilesRequest.ProtoReflect.Descriptor instead.
 func (*ListVideoFilesRequest) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{5}
+	return file_proto_storage_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *ListVideoFilesRequest) GetVideoId() string {
@@ -310,7 +428,7 @@ type ListVideoFilesResponse struct {
 
 func (x *ListVideoFilesResponse) Reset() {
 	*x = ListVideoFilesResponse{}
-	mi := &file_proto_storage_proto_msgTypes[6]
+	mi := &file_proto_storage_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -322,7 +440,7 @@ func (x *ListVideoFilesResponse) String() string {
 func (*ListVideoFilesResponse) ProtoMessage() {}
 
 func (x *ListVideoFilesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[6]
+	mi := &file_proto_storage_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -335,7 +453,7 @@ func (x *ListVideoFilesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListVideoFilesResponse.ProtoReflect.Descriptor instead.
 func (*ListVideoFilesResponse) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{6}
+	return file_proto_storage_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ListVideoFilesResponse) GetFilenames() []string {
@@ -355,7 +473,7 @@ type BatchDeleteRequest struct {
 
 func (x *BatchDeleteRequest) Reset() {
 	*x = BatchDeleteRequest{}
-	mi := &file_proto_storage_proto_msgTypes[7]
+	mi := &file_proto_storage_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -367,7 +485,7 @@ func (x *BatchDeleteRequest) String() string {
 func (*BatchDeleteRequest) ProtoMessage() {}
 
 func (x *BatchDeleteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[7]
+	mi := &file_proto_storage_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -380,7 +498,7 @@ func (x *BatchDeleteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchDeleteRequest.ProtoReflect.Descriptor instead.
 func (*BatchDeleteRequest) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{7}
+	return file_proto_storage_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *BatchDeleteRequest) GetVideoId() string {
@@ -406,7 +524,7 @@ type DeleteFileResponse struct {
 
 func (x *DeleteFileResponse) Reset() {
 	*x = DeleteFileResponse{}
-	mi := &file_proto_storage_proto_msgTypes[8]
+	mi := &file_proto_storage_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -418,7 +536,7 @@ func (x *DeleteFileResponse) String() string {
 func (*DeleteFileResponse) ProtoMessage() {}
 
 func (x *DeleteFileResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_storage_proto_msgTypes[8]
+	mi := &file_proto_storage_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -431,7 +549,7 @@ func (x *DeleteFileResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteFileResponse.ProtoReflect.Descriptor instead.
 func (*DeleteFileResponse) Descriptor() ([]byte, []int) {
-	return file_proto_storage_proto_rawDescGZIP(), []int{8}
+	return file_proto_storage_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DeleteFileResponse) GetSuccess() bool {
@@ -441,21 +559,232 @@ func (x *DeleteFileResponse) GetSuccess() bool {
 	return false
 }
 
+type ScrubRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Start a scrub pass now unless one is already running.
+	Start         bool `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScrubRequest) Reset() {
+	*x = ScrubRequest{}
+	mi := &file_proto_storage_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubRequest) ProtoMessage() {}
+
+func (x *ScrubRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubRequest.ProtoReflect.Descriptor instead.
+func (*ScrubRequest) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ScrubRequest) GetStart() bool {
+	if x != nil {
+		return x.Start
+	}
+	return false
+}
+
+// A file whose bytes do not match its stored digest, or that has no stored
+// digest, in which case expected_sha256 is empty.
+type CorruptFile struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	VideoId        string                 `protobuf:"bytes,1,opt,name=video_id,json=videoId,proto3" json:"video_id,omitempty"`
+	Filename       string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	ExpectedSha256 string                 `protobuf:"bytes,3,opt,name=expected_sha256,json=expectedSha256,proto3" json:"expected_sha256,omitempty"`
+	ActualSha256   string                 `protobuf:"bytes,4,opt,name=actual_sha256,json=actualSha256,proto3" json:"actual_sha256,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CorruptFile) Reset() {
+	*x = CorruptFile{}
+	mi := &file_proto_storage_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CorruptFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CorruptFile) ProtoMessage() {}
+
+func (x *CorruptFile) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CorruptFile.ProtoReflect.Descriptor instead.
+func (*CorruptFile) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *CorruptFile) GetVideoId() string {
+	if x != nil {
+		return x.VideoId
+	}
+	return ""
+}
+
+func (x *CorruptFile) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *CorruptFile) GetExpectedSha256() string {
+	if x != nil {
+		return x.ExpectedSha256
+	}
+	return ""
+}
+
+func (x *CorruptFile) GetActualSha256() string {
+	if x != nil {
+		return x.ActualSha256
+	}
+	return ""
+}
+
+type ScrubStatus struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Running           bool                   `protobuf:"varint,1,opt,name=running,proto3" json:"running,omitempty"`
+	FilesScanned      int64                  `protobuf:"varint,2,opt,name=files_scanned,json=filesScanned,proto3" json:"files_scanned,omitempty"`
+	FilesTotal        int64                  `protobuf:"varint,3,opt,name=files_total,json=filesTotal,proto3" json:"files_total,omitempty"`
+	LastStartedUnix   int64                  `protobuf:"varint,4,opt,name=last_started_unix,json=lastStartedUnix,proto3" json:"last_started_unix,omitempty"`
+	LastCompletedUnix int64                  `protobuf:"varint,5,opt,name=last_completed_unix,json=lastCompletedUnix,proto3" json:"last_completed_unix,omitempty"`
+	Corrupt           []*CorruptFile         `protobuf:"bytes,6,rep,name=corrupt,proto3" json:"corrupt,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ScrubStatus) Reset() {
+	*x = ScrubStatus{}
+	mi := &file_proto_storage_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScrubStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScrubStatus) ProtoMessage() {}
+
+func (x *ScrubStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_storage_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScrubStatus.ProtoReflect.Descriptor instead.
+func (*ScrubStatus) Descriptor() ([]byte, []int) {
+	return file_proto_storage_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ScrubStatus) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *ScrubStatus) GetFilesScanned() int64 {
+	if x != nil {
+		return x.FilesScanned
+	}
+	return 0
+}
+
+func (x *ScrubStatus) GetFilesTotal() int64 {
+	if x != nil {
+		return x.FilesTotal
+	}
+	return 0
+}
+
+func (x *ScrubStatus) GetLastStartedUnix() int64 {
+	if x != nil {
+		return x.LastStartedUnix
+	}
+	return 0
+}
+
+func (x *ScrubStatus) GetLastCompletedUnix() int64 {
+	if x != nil {
+		return x.LastCompletedUnix
+	}
+	return 0
+}
+
+func (x *ScrubStatus) GetCorrupt() []*CorruptFile {
+	if x != nil {
+		return x.Corrupt
+	}
+	return nil
+}
+
 var File_proto_storage_proto protoreflect.FileDescriptor
 
 const file_proto_storage_proto_rawDesc = "" +
 	"\n" +
 	"\x13proto/storage.proto\x12\n" +
-	"tritontube\"V\n" +
+	"tritontube\"\xa2\x01\n" +
 	"\tFileChunk\x12\x19\n" +
 	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1a\n" +
 	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x12\n" +
-	"\x04data\x18\x03 \x01(\fR\x04data\"D\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x1e\n" +
+	"\n" +
+	"unverified\x18\x06 \x01(\bR\n" +
+	"unverified\"t\n" +
 	"\vFileRequest\x12\x19\n" +
 	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1a\n" +
-	"\bfilename\x18\x02 \x01(\tR\bfilename\"%\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06length\x18\x04 \x01(\x03R\x06length\"c\n" +
+	"\bFileInfo\x12\x12\n" +
+	"\x04size\x18\x01 \x01(\x03R\x04size\x12+\n" +
+	"\x12mod_time_unix_nano\x18\x02 \x01(\x03R\x0fmodTimeUnixNano\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\"=\n" +
 	"\tUploadAck\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x13\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\tR\x06sha256\"\x13\n" +
 	"\x11ListVideosRequest\"1\n" +
 	"\x12ListVideosResponse\x12\x1b\n" +
 	"\tvideo_ids\x18\x01 \x03(\tR\bvideoIds\"2\n" +
@@ -467,14 +796,31 @@ const file_proto_storage_proto_rawDesc = "" +
 	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1c\n" +
 	"\tfilenames\x18\x02 \x03(\tR\tfilenames\".\n" +
 	"\x12DeleteFileResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2\xf6\x02\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"$\n" +
+	"\fScrubRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\bR\x05start\"\x92\x01\n" +
+	"\vCorruptFile\x12\x19\n" +
+	"\bvideo_id\x18\x01 \x01(\tR\avideoId\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12'\n" +
+	"\x0fexpected_sha256\x18\x03 \x01(\tR\x0eexpectedSha256\x12#\n" +
+	"\ractual_sha256\x18\x04 \x01(\tR\factualSha256\"\xfc\x01\n" +
+	"\vScrubStatus\x12\x18\n" +
+	"\arunning\x18\x01 \x01(\bR\arunning\x12#\n" +
+	"\rfiles_scanned\x18\x02 \x01(\x03R\ffilesScanned\x12\x1f\n" +
+	"\vfiles_total\x18\x03 \x01(\x03R\n" +
+	"filesTotal\x12*\n" +
+	"\x11last_started_unix\x18\x04 \x01(\x03R\x0flastStartedUnix\x12.\n" +
+	"\x13last_completed_unix\x18\x05 \x01(\x03R\x11lastCompletedUnix\x121\n" +
+	"\acorrupt\x18\x06 \x03(\v2\x17.tritontube.CorruptFileR\acorrupt2\xe9\x03\n" +
 	"\aStorage\x128\n" +
 	"\x06Upload\x12\x15.tritontube.FileChunk\x1a\x15.tritontube.UploadAck(\x01\x12<\n" +
-	"\bDownload\x12\x17.tritontube.FileRequest\x1a\x15.tritontube.FileChunk0\x01\x12K\n" +
+	"\bDownload\x12\x17.tritontube.FileRequest\x1a\x15.tritontube.FileChunk0\x01\x125\n" +
+	"\x04Stat\x12\x17.tritontube.FileRequest\x1a\x14.tritontube.FileInfo\x12K\n" +
 	"\n" +
 	"ListVideos\x12\x1d.tritontube.ListVideosRequest\x1a\x1e.tritontube.ListVideosResponse\x12W\n" +
 	"\x0eListVideoFiles\x12!.tritontube.ListVideoFilesRequest\x1a\".tritontube.ListVideoFilesResponse\x12M\n" +
-	"\vDeleteFiles\x12\x1e.tritontube.BatchDeleteRequest\x1a\x1e.tritontube.DeleteFileResponseB\x16Z\x14internal/proto;protob\x06proto3"
+	"\vDeleteFiles\x12\x1e.tritontube.BatchDeleteRequest\x1a\x1e.tritontube.DeleteFileResponse\x12:\n" +
+	"\x05Scrub\x12\x18.tritontube.ScrubRequest\x1a\x17.tritontube.ScrubStatusB\x16Z\x14internal/proto;protob\x06proto3"
 
 var (
 	file_proto_storage_proto_rawDescOnce sync.Once
@@ -488,34 +834,43 @@ func file_proto_storage_proto_rawDescGZIP() []byte {
 	return file_proto_storage_proto_rawDescData
 }
 
-var file_proto_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proto_storage_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_proto_storage_proto_goTypes = []any{
 	(*FileChunk)(nil),              // 0: tritontube.FileChunk
 	(*FileRequest)(nil),            // 1: tritontube.FileRequest
-	(*UploadAck)(nil),              // 2: tritontube.UploadAck
-	(*ListVideosRequest)(nil),      // 3: tritontube.ListVideosRequest
-	(*ListVideosResponse)(nil),     // 4: tritontube.ListVideosResponse
-	(*ListVideoFilesRequest)(nil),  // 5: tritontube.ListVideoFilesRequest
-	(*ListVideoFilesResponse)(nil), // 6: tritontube.ListVideoFilesResponse
-	(*BatchDeleteRequest)(nil),     // 7: tritontube.BatchDeleteRequest
-	(*DeleteFileResponse)(nil),     // 8: tritontube.DeleteFileResponse
+	(*FileInfo)(nil),               // 2: tritontube.FileInfo
+	(*UploadAck)(nil),              // 3: tritontube.UploadAck
+	(*ListVideosRequest)(nil),      // 4: tritontube.ListVideosRequest
+	(*ListVideosResponse)(nil),     // 5: tritontube.ListVideosResponse
+	(*ListVideoFilesRequest)(nil),  // 6: tritontube.ListVideoFilesRequest
+	(*ListVideoFilesResponse)(nil), // 7: tritontube.ListVideoFilesResponse
+	(*BatchDeleteRequest)(nil),     // 8: tritontube.BatchDeleteRequest
+	(*DeleteFileResponse)(nil),     // 9: tritontube.DeleteFileResponse
+	(*ScrubRequest)(nil),           // 10: tritontube.ScrubRequest
+	(*CorruptFile)(nil),            // 11: tritontube.CorruptFile
+	(*ScrubStatus)(nil),            // 12: tritontube.ScrubStatus
 }
 var file_proto_storage_proto_depIdxs = []int32{
-	0, // 0: tritontube.Storage.Upload:input_type -> tritontube.FileChunk
-	1, // 1: tritontube.Storage.Download:input_type -> tritontube.FileRequest
-	3, // 2: tritontube.Storage.ListVideos:input_type -> tritontube.ListVideosRequest
-	5, // 3: tritontube.Storage.ListVideoFiles:input_type -> tritontube.ListVideoFilesRequest
-	7, // 4: tritontube.Storage.DeleteFiles:input_type -> tritontube.BatchDeleteRequest
-	2, // 5: tritontube.Storage.Upload:output_type -> tritontube.UploadAck
-	0, // 6: tritontube.Storage.Download:output_type -> tritontube.FileChunk
-	4, // 7: tritontube.Storage.ListVideos:output_type -> tritontube.ListVideosResponse
-	6, // 8: tritontube.Storage.ListVideoFiles:output_type -> tritontube.ListVideoFilesResponse
-	8, // 9: tritontube.Storage.DeleteFiles:output_type -> tritontube.DeleteFileResponse
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	11, // 0: tritontube.ScrubStatus.corrupt:type_name -> tritontube.CorruptFile
+	0,  // 1: tritontube.Storage.Upload:input_type -> tritontube.FileChunk
+	1,  // 2: tritontube.Storage.Download:input_type -> tritontube.FileRequest
+	1,  // 3: tritontube.Storage.Stat:input_type -> tritontube.FileRequest
+	4,  // 4: tritontube.Storage.ListVideos:input_type -> tritontube.ListVideosRequest
+	6,  // 5: tritontube.Storage.ListVideoFiles:input_type -> tritontube.ListVideoFilesRequest
+	8,  // 6: tritontube.Storage.DeleteFiles:input_type -> tritontube.BatchDeleteRequest
+	10, // 7: tritontube.Storage.Scrub:input_type -> tritontube.ScrubRequest
+	3,  // 8: tritontube.Storage.Upload:output_type -> tritontube.UploadAck
+	0,  // 9: tritontube.Storage.Download:output_type -> tritontube.FileChunk
+	2,  // 10: tritontube.Storage.Stat:output_type -> tritontube.FileInfo
+	5,  // 11: tritontube.Storage.ListVideos:output_type -> tritontube.ListVideosResponse
+	7,  // 12: tritontube.Storage.ListVideoFiles:output_type -> tritontube.ListVideoFilesResponse
+	9,  // 13: tritontube.Storage.DeleteFiles:output_type -> tritontube.DeleteFileResponse
+	12, // 14: tritontube.Storage.Scrub:output_type -> tritontube.ScrubStatus
+	8,  // [8:15] is the sub-list for method output_type
+	1,  // [1:8] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_proto_storage_proto_init() }
@@ -529,7 +884,7 @@ func file_proto_storage_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_storage_proto_rawDesc), len(file_proto_storage_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,9 +21,11 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	Storage_Upload_FullMethodName         = "/tritontube.Storage/Upload"
 	Storage_Download_FullMethodName       = "/tritontube.Storage/Download"
+	Storage_Stat_FullMethodName           = "/tritontube.Storage/Stat"
 	Storage_ListVideos_FullMethodName     = "/tritontube.Storage/ListVideos"
 	Storage_ListVideoFiles_FullMethodName = "/tritontube.Storage/ListVideoFiles"
 	Storage_DeleteFiles_FullMethodName    = "/tritontube.Storage/DeleteFiles"
+	Storage_Scrub_FullMethodName          = "/tritontube.Storage/Scrub"
 )
 
 // StorageClient is the client API for Storage service.
@@ -32,9 +34,11 @@ const (
 type StorageClient interface {
 	Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, UploadAck], error)
 	Download(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error)
+	Stat(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (*FileInfo, error)
 	ListVideos(ctx context.Context, in *ListVideosRequest, opts ...grpc.CallOption) (*ListVideosResponse, error)
 	ListVideoFiles(ctx context.Context, in *ListVideoFilesRequest, opts ...grpc.CallOption) (*ListVideoFilesResponse, error)
 	DeleteFiles(ctx context.Context, in *BatchDeleteRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error)
+	Scrub(ctx context.Context, in *ScrubRequest, opts ...grpc.CallOption) (*ScrubStatus, error)
 }
 
 type storageClient struct {
@@ -77,6 +81,16 @@ func (c *storageClient) Download(ctx context.Context, in *FileRequest, opts ...g
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Storage_DownloadClient = grpc.ServerStreamingClient[FileChunk]
 
+func (c *storageClient) Stat(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, Storage_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *storageClient) ListVideos(ctx context.Context, in *ListVideosRequest, opts ...grpc.CallOption) (*ListVideosResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ListVideosResponse)
@@ -107,15 +121,27 @@ func (c *storageClient) DeleteFiles(ctx context.Context, in *BatchDeleteRequest,
 	return out, nil
 }
 
+func (c *storageClient) Scrub(ctx context.Context, in *ScrubRequest, opts ...grpc.CallOption) (*ScrubStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScrubStatus)
+	err := c.cc.Invoke(ctx, Storage_Scrub_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorageServer is the server API for Storage service.
 // All implementations must embed UnimplementedStorageServer
 // for forward compatibility.
 type StorageServer interface {
 	Upload(grpc.ClientStreamingServer[FileChunk, UploadAck]) error
 	Download(*FileRequest, grpc.ServerStreamingServer[FileChunk]) error
+	Stat(context.Context, *FileRequest) (*FileInfo, error)
 	ListVideos(context.Context, *ListVideosRequest) (*ListVideosResponse, error)
 	ListVideoFiles(context.Context, *ListVideoFilesRequest) (*ListVideoFilesResponse, error)
 	DeleteFiles(context.Context, *BatchDeleteRequest) (*DeleteFileResponse, error)
+	Scrub(context.Context, *ScrubRequest) (*ScrubStatus, error)
 	mustEmbedUnimplementedStorageServer()
 }
 
@@ -132,6 +158,9 @@ func (UnimplementedStorageServer) Upload(grpc.ClientStreamingServer[FileChunk, U
 func (UnimplementedStorageServer) Download(*FileRequest, grpc.ServerStreamingServer[FileChunk]) error {
 	return status.Errorf(codes.Unimplemented, "method Download not implemented")
 }
+func (UnimplementedStorageServer) Stat(context.Context, *FileRequest) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
 func (UnimplementedStorageServer) ListVideos(context.Context, *ListVideosRequest) (*ListVideosResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListVideos not implemented")
 }
@@ -141,6 +170,9 @@ func (UnimplementedStorageServer) ListVideoFiles(context.Context, *ListVideoFile
 func (UnimplementedStorageServer) DeleteFiles(context.Context, *BatchDeleteRequest) (*DeleteFileResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteFiles not implemented")
 }
+func (UnimplementedStorageServer) Scrub(context.Context, *ScrubRequest) (*ScrubStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scrub not implemented")
+}
 func (UnimplementedStorageServer) mustEmbedUnimplementedStorageServer() {}
 func (UnimplementedStorageServer) testEmbeddedByValue()                 {}
 
@@ -180,6 +212,24 @@ func _Storage_Download_Handler(srv interface{}, stream grpc.ServerStream) error
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Storage_DownloadServer = grpc.ServerStreamingServer[FileChunk]
 
+func _Storage_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Stat(ctx, req.(*FileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Storage_ListVideos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListVideosRequest)
 	if err := dec(in); err != nil {
@@ -234,6 +284,24 @@ func _Storage_DeleteFiles_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Storage_Scrub_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScrubRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageServer).Scrub(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Storage_Scrub_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageServer).Scrub(ctx, req.(*ScrubRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Storage_ServiceDesc is the grpc.ServiceDesc for Storage service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -241,6 +309,10 @@ var Storage_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "tritontube.Storage",
 	HandlerType: (*StorageServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Stat",
+			Handler:    _Storage_Stat_Handler,
+		},
 		{
 			MethodName: "ListVideos",
 			Handler:    _Storage_ListVideos_Handler,
@@ -253,6 +325,10 @@ var Storage_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteFiles",
 			Handler:    _Storage_DeleteFiles_Handler,
 		},
+		{
+			MethodName: "Scrub",
+			Handler:    _Storage_Scrub_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
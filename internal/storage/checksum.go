@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tritontube/internal/contentkey"
+)
+
+// checksumPrefix names the sidecar file holding the hex SHA-256 of a stored
+// file: BaseDir/<videoId>/.sha256-<filename>.
+const checksumPrefix = ".sha256-"
+
+func checksumPath(path string) string {
+	return filepath.Join(filepath.Dir(path), checksumPrefix+filepath.Base(path))
+}
+
+// fileDigest returns the stored digest for path, or "" for a file without
+// a sidecar, e.g. one written before checksums existed or by an upload
+// that crashed between rename and sidecar write. Such a file is
+// unverified: its current bytes may already be corrupt, so no digest is
+// made up for them. The scrubber reports it until a replica repairs it.
+func fileDigest(path string) (string, error) {
+	data, err := os.ReadFile(checksumPath(path))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read checksum failed: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// verifiedFile is the size, modification time and digest a file had when
+// it was last hashed and found to match its digest.
+type verifiedFile struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// isVerified reports whether key was verified against digest and has not
+// changed since.
+func (s *Server) isVerified(key contentkey.Key, info os.FileInfo, digest string) bool {
+	s.verifiedMu.Lock()
+	defer s.verifiedMu.Unlock()
+	v, ok := s.verified[scrubKey(key.VideoID(), key.Filename())]
+	return ok && v.size == info.Size() && v.modTime.Equal(info.ModTime()) && v.digest == digest
+}
+
+func (s *Server) markVerified(key contentkey.Key, info os.FileInfo, digest string) {
+	s.verifiedMu.Lock()
+	defer s.verifiedMu.Unlock()
+	if s.verified == nil {
+		s.verified = make(map[string]verifiedFile)
+	}
+	s.verified[scrubKey(key.VideoID(), key.Filename())] = verifiedFile{
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		digest:  digest,
+	}
+}
+
+// forgetVerified drops the cached verification of a file that was
+// rewritten, deleted or found corrupt.
+func (s *Server) forgetVerified(videoId, filename string) {
+	s.verifiedMu.Lock()
+	defer s.verifiedMu.Unlock()
+	delete(s.verified, scrubKey(videoId, filename))
+}
+
+// computeDigest hashes the current contents of path.
+func computeDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open error: %v", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("read error: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeTempFile writes data to a synced temp file beside path, to be
+// renamed over it, and returns the temp file's name.
+func writeTempFile(path string, data []byte) (string, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), tempPrefix+filepath.Base(path)+".*")
+	if err != nil {
+		return "", fmt.Errorf("file create failed: %v", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("write failed: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("sync failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("close failed: %v", err)
+	}
+	return file.Name(), nil
+}
@@ -2,14 +2,21 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
+	"tritontube/internal/contentkey"
 	"tritontube/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Server struct {
@@ -17,26 +24,129 @@ type Server struct {
 	BaseDir    string
 	videoIndex map[string][]string
 	mu         sync.RWMutex
+
+	scrub   scrubState
+	scrubMu sync.Mutex
+
+	// verified caches which files were last hashed and found to match
+	// their digest, keyed like scrub findings.
+	verified   map[string]verifiedFile
+	verifiedMu sync.Mutex
 }
 
-func NewServer(baseDir string) *Server {
-	return &Server{
+func NewServer(baseDir string) (*Server, error) {
+	s := &Server{
 		BaseDir:    baseDir,
 		videoIndex: make(map[string][]string),
+		scrub:      scrubState{corrupt: make(map[string]*proto.CorruptFile)},
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("mkdir failed: %v", err)
 	}
+	if err := s.rebuildIndex(); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
+// rebuildIndex walks BaseDir and repopulates videoIndex from the files
+// already on disk, so a restarted node serves and migrates what it stored
+// before going down. The layout is BaseDir/<videoId>/<filename>. Temp
+// files left by uploads interrupted by a crash are removed.
+func (s *Server) rebuildIndex() error {
+	entries, err := os.ReadDir(s.BaseDir)
+	if err != nil {
+		return fmt.Errorf("read base dir failed: %v", err)
+	}
+
+	index := make(map[string][]string)
+	fileCount := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		videoId := entry.Name()
+		files, err := os.ReadDir(filepath.Join(s.BaseDir, videoId))
+		if err != nil {
+			return fmt.Errorf("read video dir %s failed: %v", videoId, err)
+		}
+		for _, f := range files {
+			if !f.Type().IsRegular() {
+				continue
+			}
+			if strings.HasPrefix(f.Name(), tempPrefix) {
+				path := filepath.Join(s.BaseDir, videoId, f.Name())
+				if err := os.Remove(path); err != nil {
+					log.Printf("[INDEX] Failed to remove stale temp file %s: %v", path, err)
+				} else {
+					log.Printf("[INDEX] Removed stale temp file %s", path)
+				}
+				continue
+			}
+			if strings.HasPrefix(f.Name(), checksumPrefix) {
+				continue
+			}
+			index[videoId] = append(index[videoId], f.Name())
+			fileCount++
+		}
+	}
+
+	s.mu.Lock()
+	s.videoIndex = index
+	s.mu.Unlock()
+
+	log.Printf("[INDEX] Rebuilt index from %s: %d videos, %d files", s.BaseDir, len(index), fileCount)
+	return nil
+}
+
+// tempPrefix marks in-progress uploads. Files are written under a hidden
+// temp name next to their final path and renamed into place only once the
+// whole stream has been received and synced, so a reader never sees a
+// truncated file.
+const tempPrefix = ".upload-"
+
 func (s *Server) Upload(stream proto.Storage_UploadServer) error {
 	var file *os.File
 	var path string
-	var videoId, filename string
+	var videoId, filename, expected string
+	var unverified bool
+	var written int64
+	hasher := sha256.New()
+
+	committed := false
+	defer func() {
+		if file != nil && !committed {
+			file.Close()
+			os.Remove(file.Name())
+			log.Printf("[UPLOAD] Aborted: %s", path)
+		}
+	}()
 
 	for {
 		chunk, err := stream.Recv()
 		if err == io.EOF {
-			if file != nil {
-				file.Close()
+			if file == nil {
+				return fmt.Errorf("upload stream closed before first chunk")
+			}
+			digest := hex.EncodeToString(hasher.Sum(nil))
+			if expected != "" && digest != expected {
+				log.Printf("[UPLOAD] Checksum mismatch for %s: expected %s, received %s", path, expected, digest)
+				return status.Errorf(codes.DataLoss, "checksum mismatch for %s", path)
+			}
+			stored := digest
+			if unverified {
+				stored = ""
+			}
+			if err := s.commitUpload(file, path, written, stored); err != nil {
+				// A failed commit can remove an older copy as well.
+				if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+					s.unindex(videoId, filename)
+				}
+				return err
 			}
+			committed = true
+			s.clearCorrupt(videoId, filename)
+
 			s.mu.Lock()
 			if s.videoIndex == nil {
 				s.videoIndex = make(map[string][]string)
@@ -53,61 +163,272 @@ func (s *Server) Upload(stream proto.Storage_UploadServer) error {
 			}
 			s.mu.Unlock()
 
-			log.Printf("[UPLOAD] Completed: %s", path)
-			return stream.SendAndClose(&proto.UploadAck{Success: true})
+			if unverified {
+				log.Printf("[UPLOAD] Completed: %s (unverified, sha256 %s)", path, digest)
+			} else {
+				log.Printf("[UPLOAD] Completed: %s (sha256 %s)", path, digest)
+			}
+			return stream.SendAndClose(&proto.UploadAck{Success: true, Sha256: digest})
 		}
 		if err != nil {
 			return fmt.Errorf("error receiving chunk: %v", err)
 		}
 
 		if file == nil {
-			videoId = chunk.VideoId
-			filename = chunk.Filename
-			path = filepath.Join(s.BaseDir, videoId, filename)
+			key, err := contentkey.New(chunk.VideoId, chunk.Filename)
+			if err != nil {
+				return invalidArgument(err)
+			}
+			videoId = key.VideoID()
+			filename = key.Filename()
+			expected = chunk.Sha256
+			unverified = chunk.Unverified && expected == ""
+			path = key.Path(s.BaseDir)
 			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 				return fmt.Errorf("mkdir failed: %v", err)
 			}
-			file, err = os.Create(path)
+			file, err = os.CreateTemp(filepath.Dir(path), tempPrefix+filename+".*")
 			if err != nil {
 				return fmt.Errorf("file create failed: %v", err)
 			}
 			log.Printf("[UPLOAD] Started: %s", path)
 		}
 
-		if _, err := file.Write(chunk.Data); err != nil {
+		n, err := file.Write(chunk.Data)
+		if err != nil {
 			return fmt.Errorf("write failed: %v", err)
 		}
+		hasher.Write(chunk.Data[:n])
+		written += int64(n)
+	}
+}
+
+// commitUpload syncs the temp file, checks that everything received made
+// it to disk, renames it over path and records its digest, unless digest
+// is empty for an unverified copy. Any old digest is dropped before the
+// rename so it can never describe the new contents, and the new one is
+// written to a temp file first so only a failed rename can lose it. If
+// that happens the new file is removed again rather than kept without
+// its digest.
+func (s *Server) commitUpload(file *os.File, path string, written int64, digest string) error {
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync failed: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat failed: %v", err)
+	}
+	if info.Size() != written {
+		return fmt.Errorf("size mismatch: wrote %d bytes, file has %d", written, info.Size())
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close failed: %v", err)
+	}
+
+	var sidecar string
+	if digest != "" {
+		sidecar, err = writeTempFile(checksumPath(path), []byte(digest+"\n"))
+		if err != nil {
+			return fmt.Errorf("checksum %v", err)
+		}
+	}
+	if err := os.Remove(checksumPath(path)); err != nil && !os.IsNotExist(err) {
+		removeIfSet(sidecar)
+		return fmt.Errorf("remove old checksum failed: %v", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		removeIfSet(sidecar)
+		return fmt.Errorf("rename failed: %v", err)
+	}
+	if sidecar != "" {
+		if err := os.Rename(sidecar, checksumPath(path)); err != nil {
+			os.Remove(sidecar)
+			os.Remove(path)
+			syncDir(filepath.Dir(path))
+			return fmt.Errorf("checksum rename failed: %v", err)
+		}
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+func removeIfSet(path string) {
+	if path != "" {
+		os.Remove(path)
+	}
+}
+
+// unindex drops filename from videoId's entry in videoIndex.
+func (s *Server) unindex(videoId, filename string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var remaining []string
+	for _, f := range s.videoIndex[videoId] {
+		if f != filename {
+			remaining = append(remaining, f)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(s.videoIndex, videoId)
+	} else {
+		s.videoIndex[videoId] = remaining
 	}
 }
 
+// verifyFile hashes file and fails with DataLoss, recording the finding,
+// if it does not match digest. Unless full is set, a file found corrupt
+// since is refused and one verified since it last changed is accepted
+// without hashing it again.
+func (s *Server) verifyFile(key contentkey.Key, file *os.File, info os.FileInfo, digest string, full bool) error {
+	if !full {
+		if s.knownCorrupt(key.VideoID(), key.Filename()) {
+			return status.Errorf(codes.DataLoss, "%s does not match its checksum", key)
+		}
+		if s.isVerified(key, info, digest) {
+			return nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("read error: %v", err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		log.Printf("[DOWNLOAD] Refusing corrupt %s: expected %s, got %s", key, digest, actual)
+		s.recordCorrupt(&proto.CorruptFile{
+			VideoId:        key.VideoID(),
+			Filename:       key.Filename(),
+			ExpectedSha256: digest,
+			ActualSha256:   actual,
+		})
+		return status.Errorf(codes.DataLoss, "%s does not match its checksum", key)
+	}
+	s.markVerified(key, info, digest)
+	return nil
+}
+
+// syncDir flushes a directory entry change, such as a rename, to disk.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
+
+// invalidArgument reports a rejected video ID or filename with the gRPC
+// InvalidArgument code, so callers can tell bad input from I/O failures.
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
 func (s *Server) Download(req *proto.FileRequest, stream proto.Storage_DownloadServer) error {
-	path := filepath.Join(s.BaseDir, req.VideoId, req.Filename)
+	key, err := contentkey.New(req.VideoId, req.Filename)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	path := key.Path(s.BaseDir)
 	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return status.Errorf(codes.NotFound, "%s not found", key)
+	}
 	if err != nil {
 		return fmt.Errorf("open error: %v", err)
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat error: %v", err)
+	}
+	if req.Offset < 0 || req.Length < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid range: offset %d length %d", req.Offset, req.Length)
+	}
+	if req.Offset > info.Size() {
+		return status.Errorf(codes.OutOfRange, "offset %d past end of %s (%d bytes)", req.Offset, key, info.Size())
+	}
+	digest, err := fileDigest(path)
+	if err != nil {
+		return err
+	}
+
+	// A download checks the file against its stored digest first, so a
+	// corrupt copy is refused rather than served and the caller can read
+	// another replica. A ranged read skips the hashing while the file's
+	// size and modification time are those it was last verified with.
+	if digest != "" {
+		full := req.Offset == 0 && (req.Length == 0 || req.Length >= info.Size())
+		if err := s.verifyFile(key, file, info, digest, full); err != nil {
+			return err
+		}
+	}
+
+	if _, err := file.Seek(req.Offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek error: %v", err)
+	}
+	var src io.Reader = file
+	if req.Length > 0 {
+		src = io.LimitReader(file, req.Length)
+	}
+
+	// The first chunk always goes out, even for an empty file, so the
+	// client learns the size before any data.
 	buf := make([]byte, 1024*1024)
+	first := true
 	for {
-		n, err := file.Read(buf)
+		n, err := src.Read(buf)
 		if err != nil && err != io.EOF {
 			return fmt.Errorf("read error: %v", err)
 		}
-		if n == 0 {
+		if n == 0 && !first {
 			break
 		}
-		if err := stream.Send(&proto.FileChunk{
+		chunk := &proto.FileChunk{
 			VideoId:  req.VideoId,
 			Filename: req.Filename,
 			Data:     buf[:n],
-		}); err != nil {
+		}
+		if first {
+			chunk.Size = info.Size()
+			chunk.Sha256 = digest
+			first = false
+		}
+		if err := stream.Send(chunk); err != nil {
 			return fmt.Errorf("send error: %v", err)
 		}
+		if n == 0 {
+			break
+		}
 	}
 	return nil
 }
 
+func (s *Server) Stat(ctx context.Context, req *proto.FileRequest) (*proto.FileInfo, error) {
+	key, err := contentkey.New(req.VideoId, req.Filename)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	path := key.Path(s.BaseDir)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, status.Errorf(codes.NotFound, "%s not found", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat error: %v", err)
+	}
+	digest, err := fileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.FileInfo{
+		Size:            info.Size(),
+		ModTimeUnixNano: info.ModTime().UnixNano(),
+		Sha256:          digest,
+	}, nil
+}
+
 func (s *Server) ListVideos(ctx context.Context, req *proto.ListVideosRequest) (*proto.ListVideosResponse, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -120,6 +441,9 @@ func (s *Server) ListVideos(ctx context.Context, req *proto.ListVideosRequest) (
 }
 
 func (s *Server) ListVideoFiles(ctx context.Context, req *proto.ListVideoFilesRequest) (*proto.ListVideoFilesResponse, error) {
+	if err := contentkey.CheckVideoID(req.VideoId); err != nil {
+		return nil, invalidArgument(err)
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -129,7 +453,15 @@ func (s *Server) ListVideoFiles(ctx context.Context, req *proto.ListVideoFilesRe
 }
 
 func (s *Server) DeleteFiles(ctx context.Context, req *proto.BatchDeleteRequest) (*proto.DeleteFileResponse, error) {
-	basePath := filepath.Join(s.BaseDir, req.VideoId)
+	basePath, err := contentkey.VideoDir(s.BaseDir, req.VideoId)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	for _, fname := range req.Filenames {
+		if err := contentkey.CheckFilename(fname); err != nil {
+			return nil, invalidArgument(err)
+		}
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -152,6 +484,8 @@ func (s *Server) DeleteFiles(ctx context.Context, req *proto.BatchDeleteRequest)
 			log.Printf("[DELETE] Failed to remove %s: %v", path, err)
 		} else {
 			log.Printf("[DELETE] Removed %s", path)
+			os.Remove(checksumPath(path))
+			s.clearCorrupt(req.VideoId, fname)
 			successfullyDeleted[fname] = true
 		}
 	}
@@ -165,6 +499,7 @@ func (s *Server) DeleteFiles(ctx context.Context, req *proto.BatchDeleteRequest)
 
 	if len(remaining) == 0 {
 		delete(s.videoIndex, req.VideoId)
+		os.Remove(basePath)
 	} else {
 		s.videoIndex[req.VideoId] = remaining
 	}
@@ -0,0 +1,501 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"tritontube/internal/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uploadStream feeds chunks to Upload and then fails with err, or ends
+// the stream cleanly if err is nil.
+type uploadStream struct {
+	grpc.ServerStream
+	chunks []*proto.FileChunk
+	err    error
+	ack    *proto.UploadAck
+}
+
+func (u *uploadStream) Recv() (*proto.FileChunk, error) {
+	if len(u.chunks) == 0 {
+		if u.err != nil {
+			return nil, u.err
+		}
+		return nil, io.EOF
+	}
+	chunk := u.chunks[0]
+	u.chunks = u.chunks[1:]
+	return chunk, nil
+}
+
+func (u *uploadStream) SendAndClose(ack *proto.UploadAck) error {
+	u.ack = ack
+	return nil
+}
+
+func (u *uploadStream) Context() context.Context { return context.Background() }
+
+// downloadStream collects what Download sends.
+type downloadStream struct {
+	grpc.ServerStream
+	data []byte
+}
+
+func (d *downloadStream) Send(chunk *proto.FileChunk) error {
+	d.data = append(d.data, chunk.Data...)
+	return nil
+}
+
+func (d *downloadStream) Context() context.Context { return context.Background() }
+
+const testVideoID = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s
+}
+
+func upload(s *Server, videoId, filename string, data ...string) error {
+	stream := &uploadStream{}
+	for _, d := range data {
+		stream.chunks = append(stream.chunks, &proto.FileChunk{VideoId: videoId, Filename: filename, Data: []byte(d)})
+	}
+	return s.Upload(stream)
+}
+
+// videoDirFiles lists every name in a video's directory, hidden ones
+// included.
+func videoDirFiles(t *testing.T, s *Server, videoId string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(s.BaseDir, videoId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestInterruptedUploadLeavesNoFile(t *testing.T) {
+	s := newTestServer(t)
+
+	stream := &uploadStream{
+		chunks: []*proto.FileChunk{
+			{VideoId: testVideoID, Filename: "chunk-0-00001.m4s", Data: []byte("first half ")},
+		},
+		err: errors.New("client went away"),
+	}
+	if err := s.Upload(stream); err == nil {
+		t.Fatal("Upload succeeded on a broken stream")
+	}
+	if names := videoDirFiles(t, s, testVideoID); len(names) != 0 {
+		t.Fatalf("interrupted upload left %v", names)
+	}
+
+	resp, _ := s.ListVideoFiles(context.Background(), &proto.ListVideoFilesRequest{VideoId: testVideoID})
+	if len(resp.Filenames) != 0 {
+		t.Fatalf("interrupted upload indexed %v", resp.Filenames)
+	}
+}
+
+func TestInterruptedUploadKeepsOldFile(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "manifest.mpd", "old ", "contents"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	stream := &uploadStream{
+		chunks: []*proto.FileChunk{{VideoId: testVideoID, Filename: "manifest.mpd", Data: []byte("new")}},
+		err:    errors.New("client went away"),
+	}
+	if err := s.Upload(stream); err == nil {
+		t.Fatal("Upload succeeded on a broken stream")
+	}
+
+	got, err := os.ReadFile(filepath.Join(s.BaseDir, testVideoID, "manifest.mpd"))
+	if err != nil || string(got) != "old contents" {
+		t.Fatalf("manifest.mpd = %q, %v; want the old contents", got, err)
+	}
+}
+
+func TestFailedChecksumWriteLeavesNoFile(t *testing.T) {
+	s := newTestServer(t)
+	// A directory in the way of the checksum sidecar.
+	blocker := checksumPath(filepath.Join(s.BaseDir, testVideoID, "manifest.mpd"))
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := upload(s, testVideoID, "manifest.mpd", "contents"); err == nil {
+		t.Fatal("Upload succeeded without storing its checksum")
+	}
+	if names := videoDirFiles(t, s, testVideoID); !slices.Equal(names, []string{checksumPrefix + "manifest.mpd"}) {
+		t.Fatalf("failed upload left %v", names)
+	}
+	resp, _ := s.ListVideoFiles(context.Background(), &proto.ListVideoFilesRequest{VideoId: testVideoID})
+	if len(resp.Filenames) != 0 {
+		t.Fatalf("failed upload indexed %v", resp.Filenames)
+	}
+}
+
+func TestRestartRemovesStaleTempFiles(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "manifest.mpd", "contents"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	stale := filepath.Join(s.BaseDir, testVideoID, tempPrefix+"chunk-0-00001.m4s.123")
+	if err := os.WriteFile(stale, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewServer(s.BaseDir)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("stale temp file survived restart: %v", err)
+	}
+	resp, _ := restarted.ListVideoFiles(context.Background(), &proto.ListVideoFilesRequest{VideoId: testVideoID})
+	if len(resp.Filenames) != 1 || resp.Filenames[0] != "manifest.mpd" {
+		t.Fatalf("index after restart = %v, want [manifest.mpd]", resp.Filenames)
+	}
+}
+
+func TestNewServerIndexesBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	const otherID = "01JBBBBBBBBBBBBBBBBBBBBBBB"
+	files := map[string]string{
+		testVideoID + "/manifest.mpd":                           "<MPD/>",
+		testVideoID + "/chunk-0-00001.m4s":                      "segment",
+		testVideoID + "/" + checksumPrefix + "manifest.mpd":     sha256Hex("<MPD/>"),
+		testVideoID + "/" + tempPrefix + "chunk-0-00002.m4s.42": "partial",
+		otherID + "/poster.jpg":                                 "jpeg",
+		otherID + "/" + checksumPrefix + "poster.jpg":           sha256Hex("jpeg"),
+		"stray-file": "not a video",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewServer(dir)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	videos, _ := s.ListVideos(context.Background(), &proto.ListVideosRequest{})
+	slices.Sort(videos.VideoIds)
+	if want := []string{testVideoID, otherID}; !slices.Equal(videos.VideoIds, want) {
+		t.Fatalf("ListVideos = %v, want %v", videos.VideoIds, want)
+	}
+	for videoId, want := range map[string][]string{
+		testVideoID: {"chunk-0-00001.m4s", "manifest.mpd"},
+		otherID:     {"poster.jpg"},
+	} {
+		resp, _ := s.ListVideoFiles(context.Background(), &proto.ListVideoFilesRequest{VideoId: videoId})
+		slices.Sort(resp.Filenames)
+		if !slices.Equal(resp.Filenames, want) {
+			t.Errorf("ListVideoFiles(%s) = %v, want %v", videoId, resp.Filenames, want)
+		}
+	}
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// scrubNow runs one scrub pass to completion and returns its findings.
+func scrubNow(t *testing.T, s *Server) []*proto.CorruptFile {
+	t.Helper()
+	s.scrub.running = true
+	s.runScrub()
+	st, err := s.Scrub(context.Background(), &proto.ScrubRequest{})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	return st.Corrupt
+}
+
+func TestScrubDetectsCorruption(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if found := scrubNow(t, s); len(found) != 0 {
+		t.Fatalf("clean file reported: %v", found)
+	}
+
+	path := filepath.Join(s.BaseDir, testVideoID, "chunk-0-00001.m4s")
+	if err := os.WriteFile(path, []byte("segment dbta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	found := scrubNow(t, s)
+	if len(found) != 1 {
+		t.Fatalf("findings = %v, want one", found)
+	}
+	if found[0].ExpectedSha256 != sha256Hex("segment data") || found[0].ActualSha256 != sha256Hex("segment dbta") {
+		t.Fatalf("finding = %v", found[0])
+	}
+
+	// Rewriting the file clears the finding.
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if found := scrubNow(t, s); len(found) != 0 {
+		t.Fatalf("repaired file still reported: %v", found)
+	}
+}
+
+func TestDownloadRefusesCorruptFile(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	path := filepath.Join(s.BaseDir, testVideoID, "chunk-0-00001.m4s")
+	if err := os.WriteFile(path, []byte("segment dbta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []*proto.FileRequest{
+		{VideoId: testVideoID, Filename: "chunk-0-00001.m4s", Offset: 8, Length: 2},
+		{VideoId: testVideoID, Filename: "chunk-0-00001.m4s"},
+	} {
+		stream := &downloadStream{}
+		if err := s.Download(req, stream); status.Code(err) != codes.DataLoss {
+			t.Fatalf("Download [%d+%d] of a corrupt file = %v, want DataLoss", req.Offset, req.Length, err)
+		}
+		if len(stream.data) != 0 {
+			t.Fatalf("Download sent %q of a corrupt file", stream.data)
+		}
+	}
+	st, _ := s.Scrub(context.Background(), &proto.ScrubRequest{})
+	if len(st.Corrupt) != 1 || st.Corrupt[0].ActualSha256 != sha256Hex("segment dbta") {
+		t.Fatalf("refused download not reported: %v", st.Corrupt)
+	}
+}
+
+func TestRangedDownloadCachesVerification(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	path := filepath.Join(s.BaseDir, testVideoID, "chunk-0-00001.m4s")
+	ranged := &proto.FileRequest{VideoId: testVideoID, Filename: "chunk-0-00001.m4s", Offset: 8, Length: 2}
+	if err := s.Download(ranged, &downloadStream{}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	// Bytes changed behind the node's back, size and mtime kept, are
+	// only caught by a full read or a scrub.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("segment dbta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	stream := &downloadStream{}
+	if err := s.Download(ranged, stream); err != nil || string(stream.data) != "db" {
+		t.Fatalf("ranged Download of a verified file = %q, %v", stream.data, err)
+	}
+
+	// Once the file changes it is hashed again.
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Download(ranged, &downloadStream{}); status.Code(err) != codes.DataLoss {
+		t.Fatalf("ranged Download of a changed corrupt file = %v, want DataLoss", err)
+	}
+}
+
+func TestDownloadRejectsInvalidRange(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	for _, tt := range []struct {
+		offset, length int64
+		code           codes.Code
+	}{
+		{-1, 0, codes.InvalidArgument},
+		{0, -1, codes.InvalidArgument},
+		{13, 0, codes.OutOfRange},
+	} {
+		req := &proto.FileRequest{VideoId: testVideoID, Filename: "chunk-0-00001.m4s", Offset: tt.offset, Length: tt.length}
+		if err := s.Download(req, &downloadStream{}); status.Code(err) != tt.code {
+			t.Errorf("Download [%d+%d] = %v, want %v", tt.offset, tt.length, err, tt.code)
+		}
+	}
+}
+
+func TestMissingChecksumIsUnverified(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "chunk-0-00001.m4s", "segment data"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	path := filepath.Join(s.BaseDir, testVideoID, "chunk-0-00001.m4s")
+	if err := os.Remove(checksumPath(path)); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.Stat(context.Background(), &proto.FileRequest{VideoId: testVideoID, Filename: "chunk-0-00001.m4s"})
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Sha256 != "" {
+		t.Fatalf("Stat made up digest %s for a file without a checksum", info.Sha256)
+	}
+	if _, err := os.Stat(checksumPath(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Stat wrote a checksum for unverified bytes: %v", err)
+	}
+
+	found := scrubNow(t, s)
+	if len(found) != 1 || found[0].ExpectedSha256 != "" || found[0].ActualSha256 != sha256Hex("segment data") {
+		t.Fatalf("findings = %v, want the file reported unverified", found)
+	}
+}
+
+func TestUnverifiedUploadStoresNoChecksum(t *testing.T) {
+	s := newTestServer(t)
+	stream := &uploadStream{chunks: []*proto.FileChunk{
+		{VideoId: testVideoID, Filename: "chunk-0-00001.m4s", Data: []byte("segment data"), Unverified: true},
+	}}
+	if err := s.Upload(stream); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	for _, name := range videoDirFiles(t, s, testVideoID) {
+		if name != "chunk-0-00001.m4s" {
+			t.Fatalf("unverified upload left %s", name)
+		}
+	}
+	found := scrubNow(t, s)
+	if len(found) != 1 || found[0].ExpectedSha256 != "" {
+		t.Fatalf("findings = %v, want the file reported unverified", found)
+	}
+}
+
+func TestUploadRejectsDigestMismatch(t *testing.T) {
+	s := newTestServer(t)
+	if err := upload(s, testVideoID, "manifest.mpd", "old contents"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	stream := &uploadStream{chunks: []*proto.FileChunk{{
+		VideoId:  testVideoID,
+		Filename: "manifest.mpd",
+		Data:     []byte("corrupt copy"),
+		Sha256:   sha256Hex("good copy"),
+	}}}
+	err := s.Upload(stream)
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("Upload = %v, want DataLoss", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(s.BaseDir, testVideoID, "manifest.mpd"))
+	if err != nil || string(got) != "old contents" {
+		t.Fatalf("manifest.mpd = %q, %v; want the old contents", got, err)
+	}
+}
+
+func TestLegacyVideoIDRoundTrip(t *testing.T) {
+	s := newTestServer(t)
+	const legacyID = "Team-Demo_v1.2"
+	if err := upload(s, legacyID, "manifest.mpd", "contents"); err != nil {
+		t.Fatalf("Upload with legacy ID: %v", err)
+	}
+	info, err := s.Stat(context.Background(), &proto.FileRequest{VideoId: legacyID, Filename: "manifest.mpd"})
+	if err != nil || info.Size != int64(len("contents")) {
+		t.Fatalf("Stat = %v, %v", info, err)
+	}
+	resp, err := s.DeleteFiles(context.Background(), &proto.BatchDeleteRequest{VideoId: legacyID, Filenames: []string{"manifest.mpd"}})
+	if err != nil || !resp.Success {
+		t.Fatalf("DeleteFiles = %v, %v", resp, err)
+	}
+}
+
+func TestRPCsRejectUnsafeNames(t *testing.T) {
+	s := newTestServer(t)
+	// A file just outside BaseDir that an escaping name would reach.
+	outside := filepath.Join(filepath.Dir(s.BaseDir), "outside.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name              string
+		videoId, filename string
+	}{
+		{"dot-dot filename", testVideoID, "../../outside.txt"},
+		{"dot-dot video ID", "..", "outside.txt"},
+		{"video ID with separator", "../" + filepath.Base(s.BaseDir), "x"},
+		{"absolute filename", testVideoID, outside},
+		{"absolute video ID", filepath.Dir(s.BaseDir), "outside.txt"},
+		{"NUL in filename", testVideoID, "chunk\x00.m4s"},
+		{"NUL in video ID", "vid\x00", "chunk.m4s"},
+		{"hidden filename", testVideoID, ".sha256-manifest.mpd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &proto.FileRequest{VideoId: tt.videoId, Filename: tt.filename}
+			checks := map[string]error{
+				"Upload":   upload(s, tt.videoId, tt.filename, "payload"),
+				"Download": s.Download(req, &downloadStream{}),
+				"DeleteFiles": func() error {
+					_, err := s.DeleteFiles(ctx, &proto.BatchDeleteRequest{VideoId: tt.videoId, Filenames: []string{tt.filename}})
+					return err
+				}(),
+				"Stat": func() error {
+					_, err := s.Stat(ctx, req)
+					return err
+				}(),
+			}
+			for rpc, err := range checks {
+				if status.Code(err) != codes.InvalidArgument {
+					t.Errorf("%s(%q, %q) = %v, want InvalidArgument", rpc, tt.videoId, tt.filename, err)
+				}
+			}
+		})
+	}
+
+	if got, err := os.ReadFile(outside); err != nil || string(got) != "secret" {
+		t.Fatalf("file outside BaseDir changed: %q, %v", got, err)
+	}
+	for _, videoId := range []string{"..", "a/b", "vid\x00"} {
+		_, err := s.ListVideoFiles(ctx, &proto.ListVideoFilesRequest{VideoId: videoId})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ListVideoFiles(%q) = %v, want InvalidArgument", videoId, err)
+		}
+	}
+}
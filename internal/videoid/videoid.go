@@ -0,0 +1,74 @@
+// Package videoid generates and validates video IDs. IDs are ULIDs: 26
+// Crockford base32 characters encoding a millisecond timestamp and 80
+// random bits, so they are unique, URL and filesystem safe, and sort by
+// creation time.
+package videoid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// maxLen bounds legacy IDs, which were taken from upload filenames and
+// so are capped at 255 bytes by filesystems.
+const maxLen = 255
+
+// New returns a fresh ULID.
+func New() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// 128 bits as 26 base32 digits, the first carrying only 3 bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Valid reports whether id is a video ID: either a ULID as New returns,
+// or a legacy ID taken from an upload filename before IDs were generated,
+// such as "intro" or "Team-Demo_v1.2". Legacy IDs are limited to ASCII
+// letters, digits, '.', '_' and '-', may not start with a dot and are at
+// most 255 bytes, so an ID always names one directory, needs no escaping
+// in a URL and cannot escape its parent.
+func Valid(id string) bool {
+	return isULID(id) || isLegacy(id)
+}
+
+func isULID(id string) bool {
+	if len(id) != 26 || id[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(crockford, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isLegacy(id string) bool {
+	if id == "" || len(id) > maxLen || id[0] == '.' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '.' || c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package videoid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewIsValid(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id := New()
+		if len(id) != 26 || !isULID(id) {
+			t.Fatalf("New() = %q, not a valid 26-character ID", id)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id string
+		ok bool
+	}{
+		{"01JAAAAAAAAAAAAAAAAAAAAAAA", true},
+		{"7ZZZZZZZZZZZZZZZZZZZZZZZZZ", true},
+		{"intro", true},
+		{"Team-Demo_v1.2", true},
+		{strings.Repeat("a", 255), true},
+		{"", false},
+		{"Team Demo v1.2", false},
+		{"clip?t=1", false},
+		{"clip#1", false},
+		{"clip%2F", false},
+		{"café-clip", false},
+		{".hidden", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{"../etc", false},
+		{`a\b`, false},
+		{"a\x00b", false},
+		{"line\nbreak", false},
+		{"\xff", false},
+		{strings.Repeat("a", 256), false},
+	}
+	for _, tt := range tests {
+		if got := Valid(tt.id); got != tt.ok {
+			t.Errorf("Valid(%q) = %v, want %v", tt.id, got, tt.ok)
+		}
+	}
+}
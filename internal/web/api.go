@@ -0,0 +1,305 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tritontube/internal/videoid"
+)
+
+// The /api/v1 routes expose the same operations as the HTML pages as JSON:
+//
+//	GET    /api/v1/videos            list videos (q, match, sort, cursor, limit)
+//	POST   /api/v1/videos            upload, as multipart or a raw video/mp4 body
+//	GET    /api/v1/videos/{id}       read one video
+//	DELETE /api/v1/videos/{id}       delete a video and its content
+//	GET    /api/v1/jobs/{id}         transcode job status
+//	POST   /api/v1/jobs/{id}/retry   requeue a failed job
+//	GET    /api/v1/cluster           storage backend and nodes
+//	       /api/v1/uploads/...       resumable uploads, see uploads.go
+//
+// Failures return {"error": {"code": ..., "message": ...}}.
+const apiPrefix = "/api/v1/"
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]apiError{"error": {Code: code, Message: message}})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+}
+
+type videoJSON struct {
+	Id              string    `json:"id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	Uploader        string    `json:"uploader"`
+	UploadedAt      time.Time `json:"uploadedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Width           int       `json:"width"`
+	Height          int       `json:"height"`
+	Codec           string    `json:"codec"`
+	FileSize        int64     `json:"fileSize"`
+	SegmentCount    int       `json:"segmentCount"`
+	Tags            []string  `json:"tags"`
+	Status          string    `json:"status"`
+	ManifestURL     string    `json:"manifestUrl,omitempty"`
+}
+
+func videoResponse(meta *VideoMetadata) videoJSON {
+	v := videoJSON{
+		Id:              meta.Id,
+		Title:           displayTitle(meta),
+		Description:     meta.Description,
+		Uploader:        meta.Uploader,
+		UploadedAt:      meta.UploadedAt,
+		DurationSeconds: meta.Duration.Seconds(),
+		Width:           meta.Width,
+		Height:          meta.Height,
+		Codec:           meta.Codec,
+		FileSize:        meta.FileSize,
+		SegmentCount:    meta.SegmentCount,
+		Tags:            nonNilTags(meta.Tags),
+		Status:          meta.Status,
+	}
+	if meta.Status == VideoReady {
+		v.ManifestURL = "/content/" + url.PathEscape(meta.Id) + "/manifest.mpd"
+	}
+	return v
+}
+
+func (s *server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path[len(apiPrefix):], "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "videos":
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.apiListVideos(w, r)
+		case http.MethodPost:
+			s.apiUpload(w, r)
+		default:
+			writeMethodNotAllowed(w, "GET, HEAD, POST")
+		}
+	case len(parts) == 2 && parts[0] == "videos":
+		if !videoid.Valid(parts[1]) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid video ID")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			s.apiGetVideo(w, parts[1])
+		case http.MethodDelete:
+			s.apiDeleteVideo(w, parts[1])
+		default:
+			writeMethodNotAllowed(w, "GET, HEAD, DELETE")
+		}
+	case len(parts) == 2 && parts[0] == "jobs":
+		if !isHexID(parts[1]) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid job ID")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeMethodNotAllowed(w, "GET, HEAD")
+			return
+		}
+		s.apiGetJob(w, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "retry":
+		if !isHexID(parts[1]) {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid job ID")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, "POST")
+			return
+		}
+		s.apiRetryJob(w, parts[1])
+	case parts[0] == "uploads":
+		s.handleUploads(w, r, parts[1:])
+	case len(parts) == 1 && parts[0] == "cluster":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeMethodNotAllowed(w, "GET, HEAD")
+			return
+		}
+		s.apiCluster(w)
+	default:
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such endpoint")
+	}
+}
+
+func (s *server) apiListVideos(w http.ResponseWriter, r *http.Request) {
+	opts, err := listOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+	page, err := s.metadataService.List(opts)
+	if errors.Is(err, ErrInvalidCursor) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "invalid cursor")
+		return
+	}
+	if err != nil {
+		log.Printf("[API] List failed: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "unable to list videos")
+		return
+	}
+
+	resp := struct {
+		Videos     []videoJSON `json:"videos"`
+		NextCursor string      `json:"nextCursor,omitempty"`
+	}{Videos: []videoJSON{}, NextCursor: page.NextCursor}
+	for i := range page.Videos {
+		resp.Videos = append(resp.Videos, videoResponse(&page.Videos[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// readVideo loads a video's metadata, writing the error response itself
+// and returning nil if there is none.
+func (s *server) readVideo(w http.ResponseWriter, videoId string) *VideoMetadata {
+	meta, err := s.metadataService.Read(videoId)
+	if err != nil {
+		log.Printf("[API] Read %s failed: %v", videoId, err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to fetch metadata")
+		return nil
+	}
+	if meta == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "video not found")
+		return nil
+	}
+	return meta
+}
+
+func (s *server) apiGetVideo(w http.ResponseWriter, videoId string) {
+	if meta := s.readVideo(w, videoId); meta != nil {
+		writeJSON(w, http.StatusOK, videoResponse(meta))
+	}
+}
+
+func (s *server) apiDeleteVideo(w http.ResponseWriter, videoId string) {
+	if meta := s.readVideo(w, videoId); meta == nil {
+		return
+	}
+	if err := s.DeleteVideo(videoId); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to delete video")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiUpload accepts either a multipart form like /upload or a raw MP4
+// body, with metadata in the title, description, uploader, tags and
+// filename query parameters.
+func (s *server) apiUpload(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var meta *VideoMetadata
+	var job *TranscodeJob
+	var err error
+	switch mediaType {
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
+		if err := r.ParseMultipartForm(20 << 20); err != nil {
+			if isTooLarge(err) {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, "too_large", errUploadTooLarge.Error())
+				return
+			}
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "failed to parse multi-part form")
+			return
+		}
+		file, handler, ferr := r.FormFile("file")
+		if ferr != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "file not provided")
+			return
+		}
+		defer file.Close()
+		if !strings.HasSuffix(handler.Filename, ".mp4") {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "only .mp4 allowed")
+			return
+		}
+		meta, job, err = s.queueUpload(file, uploadFormFields(handler.Filename, r.FormValue))
+	case "video/mp4", "application/octet-stream":
+		query := r.URL.Query()
+		filename := query.Get("filename")
+		if filename != "" && !strings.HasSuffix(filename, ".mp4") {
+			writeAPIError(w, http.StatusBadRequest, "invalid_argument", "only .mp4 allowed")
+			return
+		}
+		meta, job, err = s.queueUpload(r.Body, uploadFormFields(filename, query.Get))
+	default:
+		writeAPIError(w, http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"send multipart/form-data or a video/mp4 body")
+		return
+	}
+	if errors.Is(err, errUploadTooLarge) {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "too_large", err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+
+	writeAccepted(w, meta, job)
+}
+
+// writeAccepted answers a completed upload with the new video and the job
+// transcoding it.
+func writeAccepted(w http.ResponseWriter, meta *VideoMetadata, job *TranscodeJob) {
+	w.Header().Set("Location", apiPrefix+"jobs/"+job.Id)
+	writeJSON(w, http.StatusAccepted, struct {
+		Video videoJSON `json:"video"`
+		Job   jobJSON   `json:"job"`
+	}{videoResponse(meta), jobResponse(job)})
+}
+
+func (s *server) apiGetJob(w http.ResponseWriter, jobId string) {
+	job, err := s.jobService.ReadJob(jobId)
+	if err != nil {
+		log.Printf("[API] Read job %s failed: %v", jobId, err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to fetch job")
+		return
+	}
+	if job == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, jobResponse(job))
+}
+
+func (s *server) apiRetryJob(w http.ResponseWriter, jobId string) {
+	job, err := s.jobService.ReadJob(jobId)
+	if err != nil {
+		log.Printf("[API] Read job %s failed: %v", jobId, err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to fetch job")
+		return
+	}
+	if job == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "job not found")
+		return
+	}
+	if err := s.retryJob(job); err != nil {
+		writeAPIError(w, http.StatusConflict, "conflict", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, jobResponse(job))
+}
+
+func (s *server) apiCluster(w http.ResponseWriter) {
+	info := ClusterInfo{Backend: "unknown"}
+	if p, ok := s.contentService.(ClusterInfoProvider); ok {
+		info = p.ClusterInfo()
+	}
+	writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tritontube/internal/videoid"
+)
+
+// apiRequest sends a request to the /api/v1 routes.
+func apiRequest(s *server, method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	s.handleAPI(rec, req)
+	return rec
+}
+
+// decodeJSON decodes a JSON response body into v.
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body, err)
+	}
+}
+
+// wantAPIError checks that rec holds a JSON error object with the given
+// status and code.
+func wantAPIError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d (%s), want %d", rec.Code, rec.Body, status)
+	}
+	var resp struct {
+		Error *apiError `json:"error"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Error == nil || resp.Error.Code != code || resp.Error.Message == "" {
+		t.Fatalf("error = %+v, want code %q with a message", resp.Error, code)
+	}
+}
+
+type acceptedJSON struct {
+	Video videoJSON `json:"video"`
+	Job   jobJSON   `json:"job"`
+}
+
+func TestAPIVideos(t *testing.T) {
+	s, metadata := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+
+	// A raw MP4 body with its metadata in the query.
+	rec := apiRequest(s, http.MethodPost, "/api/v1/videos?title=Raw+clip&tags=a,b&filename=raw.mp4", "video/mp4", strings.NewReader("fake mp4"))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("raw upload = %d %s", rec.Code, rec.Body)
+	}
+	var raw acceptedJSON
+	decodeJSON(t, rec, &raw)
+	if raw.Video.Title != "Raw clip" || raw.Video.Status != VideoProcessing || raw.Job.VideoId != raw.Video.Id {
+		t.Fatalf("raw upload response = %+v", raw)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/api/v1/jobs/"+raw.Job.Id {
+		t.Fatalf("Location = %q", loc)
+	}
+
+	// A multipart form like the HTML upload page sends.
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "Form clip")
+	fw, _ := mw.CreateFormFile("file", "form.mp4")
+	io.WriteString(fw, "fake mp4")
+	mw.Close()
+	rec = apiRequest(s, http.MethodPost, "/api/v1/videos", mw.FormDataContentType(), &body)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("multipart upload = %d %s", rec.Code, rec.Body)
+	}
+	var form acceptedJSON
+	decodeJSON(t, rec, &form)
+
+	for _, accepted := range []acceptedJSON{raw, form} {
+		waitForJob(t, metadata, accepted.Job.Id)
+		rec = apiRequest(s, http.MethodGet, "/api/v1/jobs/"+accepted.Job.Id, "", nil)
+		var job jobJSON
+		decodeJSON(t, rec, &job)
+		if rec.Code != http.StatusOK || job.Status != JobDone || job.VideoId != accepted.Video.Id {
+			t.Fatalf("GET job = %d %+v", rec.Code, job)
+		}
+
+		rec = apiRequest(s, http.MethodGet, "/api/v1/videos/"+accepted.Video.Id, "", nil)
+		var video videoJSON
+		decodeJSON(t, rec, &video)
+		if rec.Code != http.StatusOK || video.Status != VideoReady || video.Title != accepted.Video.Title ||
+			video.ManifestURL != "/content/"+video.Id+"/manifest.mpd" {
+			t.Fatalf("GET video = %d %+v", rec.Code, video)
+		}
+	}
+
+	rec = apiRequest(s, http.MethodGet, "/api/v1/videos?sort=title", "", nil)
+	var list struct {
+		Videos     []videoJSON `json:"videos"`
+		NextCursor string      `json:"nextCursor"`
+	}
+	decodeJSON(t, rec, &list)
+	if rec.Code != http.StatusOK || len(list.Videos) != 2 || list.Videos[0].Title != "Form clip" || list.Videos[1].Title != "Raw clip" {
+		t.Fatalf("list = %d %+v", rec.Code, list)
+	}
+	rec = apiRequest(s, http.MethodGet, "/api/v1/videos?sort=title&limit=1", "", nil)
+	decodeJSON(t, rec, &list)
+	if len(list.Videos) != 1 || list.NextCursor == "" {
+		t.Fatalf("first page = %+v", list)
+	}
+	rec = apiRequest(s, http.MethodGet, "/api/v1/videos?sort=title&limit=1&cursor="+list.NextCursor, "", nil)
+	decodeJSON(t, rec, &list)
+	if len(list.Videos) != 1 || list.Videos[0].Title != "Raw clip" {
+		t.Fatalf("second page = %+v", list)
+	}
+
+	rec = apiRequest(s, http.MethodDelete, "/api/v1/videos/"+raw.Video.Id, "", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	wantAPIError(t, apiRequest(s, http.MethodGet, "/api/v1/videos/"+raw.Video.Id, "", nil), http.StatusNotFound, "not_found")
+	wantAPIError(t, apiRequest(s, http.MethodDelete, "/api/v1/videos/"+raw.Video.Id, "", nil), http.StatusNotFound, "not_found")
+
+	// An empty list is [] rather than null.
+	apiRequest(s, http.MethodDelete, "/api/v1/videos/"+form.Video.Id, "", nil)
+	rec = apiRequest(s, http.MethodGet, "/api/v1/videos", "", nil)
+	if !strings.Contains(rec.Body.String(), `"videos":[]`) {
+		t.Fatalf("empty list = %s", rec.Body)
+	}
+}
+
+func TestAPIRetryJob(t *testing.T) {
+	content := &flakyContent{
+		FSVideoContentService: NewFSVideoContentService(t.TempDir()),
+		filename:              posterFile,
+	}
+	content.failing.Store(true)
+	s, metadata := newJobServer(t, content)
+
+	rec := apiRequest(s, http.MethodPost, "/api/v1/videos", "video/mp4", strings.NewReader("fake mp4"))
+	var accepted acceptedJSON
+	decodeJSON(t, rec, &accepted)
+	if job := waitForJob(t, metadata, accepted.Job.Id); job.Status != JobFailed {
+		t.Fatalf("job = %s, want %s", job.Status, JobFailed)
+	}
+
+	content.failing.Store(false)
+	rec = apiRequest(s, http.MethodPost, "/api/v1/jobs/"+accepted.Job.Id+"/retry", "", nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("retry = %d %s", rec.Code, rec.Body)
+	}
+	if job := waitForJob(t, metadata, accepted.Job.Id); job.Status != JobDone {
+		t.Fatalf("retried job = %s (%s)", job.Status, job.Error)
+	}
+
+	// Only failed jobs can be retried.
+	wantAPIError(t, apiRequest(s, http.MethodPost, "/api/v1/jobs/"+accepted.Job.Id+"/retry", "", nil), http.StatusConflict, "conflict")
+	wantAPIError(t, apiRequest(s, http.MethodPost, "/api/v1/jobs/"+newJobID()+"/retry", "", nil), http.StatusNotFound, "not_found")
+	wantAPIError(t, apiRequest(s, http.MethodGet, "/api/v1/jobs/"+newJobID(), "", nil), http.StatusNotFound, "not_found")
+}
+
+func TestAPICluster(t *testing.T) {
+	s, _ := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	var info ClusterInfo
+	decodeJSON(t, apiRequest(s, http.MethodGet, "/api/v1/cluster", "", nil), &info)
+	if info.Backend != "fs" || len(info.Nodes) != 0 {
+		t.Fatalf("fs cluster = %+v", info)
+	}
+
+	s.contentService = newTestNetworkService(2, map[string]string{"a:1": NodeUp, "b:1": NodeDown})
+	rec := apiRequest(s, http.MethodGet, "/api/v1/cluster", "", nil)
+	decodeJSON(t, rec, &info)
+	if rec.Code != http.StatusOK || info.Backend != "nw" || info.Replicas != 2 || len(info.Nodes) != 2 {
+		t.Fatalf("nw cluster = %d %+v", rec.Code, info)
+	}
+	for _, node := range info.Nodes {
+		if want := map[string]string{"a:1": NodeUp, "b:1": NodeDown}[node.Address]; node.State != want || node.Weight != 1 {
+			t.Errorf("node %+v, want state %s", node, want)
+		}
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, _ := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	videoPath := "/api/v1/videos/" + videoid.New()
+	jobPath := "/api/v1/jobs/" + newJobID()
+
+	for _, tt := range []struct {
+		method, path, allow string
+	}{
+		{http.MethodPut, "/api/v1/videos", "GET, HEAD, POST"},
+		{http.MethodDelete, "/api/v1/videos", "GET, HEAD, POST"},
+		{http.MethodPost, videoPath, "GET, HEAD, DELETE"},
+		{http.MethodPatch, videoPath, "GET, HEAD, DELETE"},
+		{http.MethodDelete, jobPath, "GET, HEAD"},
+		{http.MethodGet, jobPath + "/retry", "POST"},
+		{http.MethodPost, "/api/v1/cluster", "GET, HEAD"},
+	} {
+		rec := apiRequest(s, tt.method, tt.path, "", nil)
+		wantAPIError(t, rec, http.StatusMethodNotAllowed, "method_not_allowed")
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+	}
+
+	for _, tt := range []struct {
+		method, path, contentType string
+		status                    int
+		code                      string
+	}{
+		{http.MethodGet, "/api/v1/nothing", "", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/v1/videos/a/b", "", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/v1/videos/..", "", http.StatusBadRequest, "invalid_argument"},
+		{http.MethodGet, videoPath, "", http.StatusNotFound, "not_found"},
+		{http.MethodGet, "/api/v1/videos?limit=0", "", http.StatusBadRequest, "invalid_argument"},
+		{http.MethodGet, "/api/v1/videos?limit=ten", "", http.StatusBadRequest, "invalid_argument"},
+		{http.MethodGet, "/api/v1/videos?cursor=garbage", "", http.StatusBadRequest, "invalid_argument"},
+		{http.MethodPost, "/api/v1/videos", "text/plain", http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{http.MethodPost, "/api/v1/videos?filename=clip.avi", "video/mp4", http.StatusBadRequest, "invalid_argument"},
+		{http.MethodPost, "/api/v1/videos", "multipart/form-data; boundary=x", http.StatusBadRequest, "invalid_argument"},
+	} {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			wantAPIError(t, apiRequest(s, tt.method, tt.path, tt.contentType, strings.NewReader("")), tt.status, tt.code)
+		})
+	}
+}
@@ -2,18 +2,58 @@ package web
 
 import (
 	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
 )
 
 type EtcdVideoMetadataService struct {
 	client *clientv3.Client
 	prefix string
+
+	// session holds the lease backing migration leadership. It is
+	// created on first use and replaced if the lease is lost. While
+	// this server leads, leaderKey and leaderRev identify its election
+	// key, which SaveRing requires to still exist.
+	sessionMu sync.Mutex
+	session   *concurrency.Session
+	leaderKey string
+	leaderRev int64
 }
 
 var _ VideoMetadataService = (*EtcdVideoMetadataService)(nil)
+var _ JobService = (*EtcdVideoMetadataService)(nil)
+
+// keyRoot returns the directory e.prefix lives in, which holds every
+// other key of the service: for the default prefix /videos/ it is /.
+// Services with different prefixes share no keys.
+func (e *EtcdVideoMetadataService) keyRoot() string {
+	parent := path.Dir(strings.TrimSuffix(e.prefix, "/"))
+	return strings.TrimSuffix(parent, "/") + "/"
+}
+
+// jobsPrefix holds transcode jobs as JSON values, keyed by job ID.
+func (e *EtcdVideoMetadataService) jobsPrefix() string {
+	return e.keyRoot() + "jobs/"
+}
+
+// Secondary indexes let List walk videos in sort order with range reads
+// instead of fetching every value. Each video has one key per index,
+// <index prefix><sort key>\x00<id>, whose value is its lowercased title
+// so searches can be filtered without reading the metadata itself. The
+// indexes live in a video-index directory under keyRoot.
+const (
+	indexTimeLayout  = "2006-01-02T15:04:05.000000000Z"
+	indexScanBatch   = 256
+	indexFetchPerTxn = 64
+)
 
 func NewEtcdVideoMetadataService(endpointsCSV string) (*EtcdVideoMetadataService, error) {
 	endpoints := strings.Split(endpointsCSV, ",")
@@ -26,21 +66,152 @@ func NewEtcdVideoMetadataService(endpointsCSV string) (*EtcdVideoMetadataService
 		return nil, err
 	}
 
-	return &EtcdVideoMetadataService{
+	e := &EtcdVideoMetadataService{
 		client: cli,
 		prefix: "/videos/",
-	}, nil
+	}
+	if err := e.ensureIndex(); err != nil {
+		cli.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func indexTitle(meta *VideoMetadata) string {
+	return strings.ToLower(displayTitle(meta))
+}
+
+func (e *EtcdVideoMetadataService) indexRoot() string {
+	return e.keyRoot() + "video-index/"
+}
+
+func (e *EtcdVideoMetadataService) timeIndexPrefix() string {
+	return e.indexRoot() + "time/"
+}
+
+func (e *EtcdVideoMetadataService) titleIndexPrefix() string {
+	return e.indexRoot() + "title/"
+}
+
+func (e *EtcdVideoMetadataService) indexVersionKey() string {
+	return e.indexRoot() + "version"
+}
+
+// indexKeys returns meta's time and title index keys.
+func (e *EtcdVideoMetadataService) indexKeys(meta *VideoMetadata) []string {
+	return []string{
+		e.timeIndexPrefix() + meta.UploadedAt.UTC().Format(indexTimeLayout) + "\x00" + meta.Id,
+		e.titleIndexPrefix() + indexTitle(meta) + "\x00" + meta.Id,
+	}
+}
+
+// ensureIndex builds the secondary indexes for videos stored before they
+// existed. Running it concurrently from several web servers is harmless.
+func (e *EtcdVideoMetadataService) ensureIndex() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.indexVersionKey())
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) > 0 {
+		return nil
+	}
+
+	resp, err = e.client.Get(ctx, e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		meta, err := decodeVideo(strings.TrimPrefix(string(kv.Key), e.prefix), kv.Value)
+		if err != nil {
+			log.Printf("[ETCD] Skipping unreadable video %s: %v", kv.Key, err)
+			continue
+		}
+		var ops []clientv3.Op
+		for _, key := range e.indexKeys(meta) {
+			ops = append(ops, clientv3.OpPut(key, indexTitle(meta)))
+		}
+		if _, err := e.client.Txn(ctx).Then(ops...).Commit(); err != nil {
+			return err
+		}
+	}
+	if _, err := e.client.Put(ctx, e.indexVersionKey(), "1"); err != nil {
+		return err
+	}
+	log.Printf("[ETCD] Indexed %d existing videos", len(resp.Kvs))
+	return nil
 }
 
-func (e *EtcdVideoMetadataService) Create(videoID string, uploadedAt time.Time) error {
+// Create stores a new video, failing with ErrVideoExists rather than
+// overwriting one with the same ID.
+func (e *EtcdVideoMetadataService) Create(meta *VideoMetadata) error {
+	return e.put(meta, true)
+}
+
+func (e *EtcdVideoMetadataService) Update(meta *VideoMetadata) error {
+	return e.put(meta, false)
+}
+
+// put writes meta and its index keys in one transaction, replacing the
+// index keys of the previous version. The transaction only applies if the
+// value is unchanged since it was read, so concurrent updates retry
+// rather than leave stale index keys behind. With create set it only
+// applies if the key does not exist at all; without it, it never creates
+// the key and fails with ErrVideoNotFound if it is gone.
+func (e *EtcdVideoMetadataService) put(meta *VideoMetadata, create bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	key := e.prefix + videoID
-	value := uploadedAt.UTC().Format(time.RFC3339)
+	key := e.prefix + meta.Id
+	value, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	newKeys := e.indexKeys(meta)
 
-	_, err := e.client.Put(ctx, key, value)
-	return err
+	for {
+		resp, err := e.client.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if create && len(resp.Kvs) > 0 {
+			return ErrVideoExists
+		}
+		if !create && len(resp.Kvs) == 0 {
+			return ErrVideoNotFound
+		}
+
+		cmp := clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		var ops []clientv3.Op
+		if len(resp.Kvs) > 0 {
+			cmp = clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)
+			if old, err := decodeVideo(meta.Id, resp.Kvs[0].Value); err == nil {
+				for _, oldKey := range e.indexKeys(old) {
+					if oldKey != newKeys[0] && oldKey != newKeys[1] {
+						ops = append(ops, clientv3.OpDelete(oldKey))
+					}
+				}
+			}
+		}
+		ops = append(ops, clientv3.OpPut(key, string(value)))
+		for _, indexKey := range newKeys {
+			ops = append(ops, clientv3.OpPut(indexKey, indexTitle(meta)))
+		}
+
+		txn, err := e.client.Txn(ctx).If(cmp).Then(ops...).Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+		if create {
+			return ErrVideoExists
+		}
+	}
 }
 
 func (e *EtcdVideoMetadataService) Read(videoID string) (*VideoMetadata, error) {
@@ -56,7 +227,143 @@ func (e *EtcdVideoMetadataService) Read(videoID string) (*VideoMetadata, error)
 		return nil, nil
 	}
 
-	uploadedAtStr := string(resp.Kvs[0].Value)
+	return decodeVideo(videoID, resp.Kvs[0].Value)
+}
+
+// List scans the index for the requested order in batches, starting just
+// past the cursor, until it has a page of matching IDs, then fetches their
+// metadata. A prefix search on titles narrows the scan to that prefix.
+func (e *EtcdVideoMetadataService) List(opts ListOptions) (*VideoPage, error) {
+	opts, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefix := e.timeIndexPrefix()
+	if opts.Sort == SortTitle {
+		prefix = e.titleIndexPrefix()
+	}
+	search := strings.ToLower(opts.Search)
+	start := prefix
+	if opts.Sort == SortTitle && opts.SearchPrefix {
+		start = prefix + search
+	}
+	end := clientv3.GetPrefixRangeEnd(start)
+	descending := opts.Sort == SortNewest
+
+	if cursor != nil {
+		if !strings.HasPrefix(cursor.Key, prefix) {
+			return nil, ErrInvalidCursor
+		}
+		if descending {
+			end = cursor.Key
+		} else {
+			start = cursor.Key + "\x00"
+		}
+	}
+
+	var ids, keys []string
+	more := false
+	for !more {
+		getOpts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(indexScanBatch)}
+		if descending {
+			getOpts = append(getOpts, clientv3.WithSort(clientv3.SortByKey, clientv3.SortDescend))
+		}
+		resp, err := e.client.Get(ctx, start, getOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, kv := range resp.Kvs {
+			title := string(kv.Value)
+			if opts.SearchPrefix && !strings.HasPrefix(title, search) ||
+				!opts.SearchPrefix && !strings.Contains(title, search) {
+				continue
+			}
+			if opts.Limit > 0 && len(ids) == opts.Limit {
+				more = true
+				break
+			}
+			key := string(kv.Key)
+			ids = append(ids, key[strings.LastIndexByte(key, 0)+1:])
+			keys = append(keys, key)
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		last := string(resp.Kvs[len(resp.Kvs)-1].Key)
+		if descending {
+			end = last
+		} else {
+			start = last + "\x00"
+		}
+	}
+
+	videos, err := e.readMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	page := &VideoPage{Videos: videos}
+	if more {
+		page.NextCursor = encodeCursor(listCursor{Sort: opts.Sort, Key: keys[len(keys)-1], Id: ids[len(ids)-1]})
+	}
+	return page, nil
+}
+
+// readMany fetches several videos in as few round trips as the
+// per-transaction operation limit allows. Videos deleted since their ID
+// was read are skipped.
+func (e *EtcdVideoMetadataService) readMany(ctx context.Context, ids []string) ([]VideoMetadata, error) {
+	var videos []VideoMetadata
+	for len(ids) > 0 {
+		n := min(len(ids), indexFetchPerTxn)
+		var ops []clientv3.Op
+		for _, id := range ids[:n] {
+			ops = append(ops, clientv3.OpGet(e.prefix+id))
+		}
+		txn, err := e.client.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range txn.Responses {
+			kvs := r.GetResponseRange().Kvs
+			if len(kvs) == 0 {
+				continue
+			}
+			meta, err := decodeVideo(ids[i], kvs[0].Value)
+			if err != nil {
+				return nil, err
+			}
+			videos = append(videos, *meta)
+		}
+		ids = ids[n:]
+	}
+	return videos, nil
+}
+
+// decodeVideo parses a JSON metadata value. Values written before metadata
+// was JSON hold just the upload timestamp.
+func decodeVideo(videoID string, value []byte) (*VideoMetadata, error) {
+	if strings.HasPrefix(string(value), "{") {
+		var meta VideoMetadata
+		if err := json.Unmarshal(value, &meta); err != nil {
+			return nil, err
+		}
+		if meta.Status == "" {
+			meta.Status = VideoReady
+		}
+		return &meta, nil
+	}
+
+	uploadedAtStr := string(value)
 	t, err := time.Parse(time.RFC3339, uploadedAtStr)
 	if err != nil {
 		t, err = time.Parse("2006-01-02 15:04:05", uploadedAtStr)
@@ -68,36 +375,101 @@ func (e *EtcdVideoMetadataService) Read(videoID string) (*VideoMetadata, error)
 	return &VideoMetadata{
 		Id:         videoID,
 		UploadedAt: t,
+		Status:     VideoReady,
 	}, nil
 }
 
-func (e *EtcdVideoMetadataService) List() ([]VideoMetadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (e *EtcdVideoMetadataService) Delete(videoID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	key := e.prefix + videoID
+	for {
+		resp, err := e.client.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return nil
+		}
+
+		ops := []clientv3.Op{clientv3.OpDelete(key)}
+		if meta, err := decodeVideo(videoID, resp.Kvs[0].Value); err == nil {
+			for _, indexKey := range e.indexKeys(meta) {
+				ops = append(ops, clientv3.OpDelete(indexKey))
+			}
+		}
+		txn, err := e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (e *EtcdVideoMetadataService) CreateJob(job *TranscodeJob) error {
+	return e.putJob(job)
+}
+
+func (e *EtcdVideoMetadataService) UpdateJob(job *TranscodeJob) error {
+	return e.putJob(job)
+}
+
+func (e *EtcdVideoMetadataService) putJob(job *TranscodeJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	resp, err := e.client.Get(ctx, e.prefix, clientv3.WithPrefix())
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.Put(ctx, e.jobsPrefix()+job.Id, string(value))
+	return err
+}
+
+func (e *EtcdVideoMetadataService) ReadJob(id string) (*TranscodeJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.jobsPrefix()+id)
 	if err != nil {
 		return nil, err
 	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
 
-	var videos []VideoMetadata
-	for _, kv := range resp.Kvs {
-		id := strings.TrimPrefix(string(kv.Key), e.prefix)
-		uploadedAtStr := string(kv.Value)
+	var job TranscodeJob
+	if err := json.Unmarshal(resp.Kvs[0].Value, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
 
-		t, err := time.Parse(time.RFC3339, uploadedAtStr)
-		if err != nil {
-			t, err = time.Parse("2006-01-02 15:04:05", uploadedAtStr)
-			if err != nil {
-				return nil, err
-			}
-		}
+func (e *EtcdVideoMetadataService) ListJobs() ([]TranscodeJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-		videos = append(videos, VideoMetadata{
-			Id:         id,
-			UploadedAt: t,
-		})
+	resp, err := e.client.Get(ctx, e.jobsPrefix(), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
 	}
 
-	return videos, nil
+	var jobs []TranscodeJob
+	for _, kv := range resp.Kvs {
+		var job TranscodeJob
+		if err := json.Unmarshal(kv.Value, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
 }
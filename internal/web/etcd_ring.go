@@ -0,0 +1,317 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+var _ RingStore = (*EtcdVideoMetadataService)(nil)
+var _ RingWatcher = (*EtcdVideoMetadataService)(nil)
+var _ MigrationLeader = (*EtcdVideoMetadataService)(nil)
+var _ OperationStore = (*EtcdVideoMetadataService)(nil)
+var _ PendingDeleteStore = (*EtcdVideoMetadataService)(nil)
+
+// ringKey holds the storage ring membership as a JSON RingState. Every
+// web server watches it, and the one holding the election under
+// ringLeaderPrefix is the only one that changes it and moves data. The
+// election is tied to a lease, so a leader that dies steps down once
+// the lease expires.
+func (e *EtcdVideoMetadataService) ringKey() string {
+	return e.keyRoot() + "ring"
+}
+
+func (e *EtcdVideoMetadataService) ringLeaderPrefix() string {
+	return e.keyRoot() + "ring-leader/"
+}
+
+const ringLeaseTTL = 10
+
+// operationsPrefix holds rebalance operations as JSON values, keyed by
+// operation ID.
+func (e *EtcdVideoMetadataService) operationsPrefix() string {
+	return e.keyRoot() + "operations/"
+}
+
+// pendingDeletesPrefix holds deletes storage nodes have not confirmed,
+// as empty values keyed by <node>/<video ID>.
+func (e *EtcdVideoMetadataService) pendingDeletesPrefix() string {
+	return e.keyRoot() + "pending-deletes/"
+}
+
+func (e *EtcdVideoMetadataService) LoadRing() (*RingState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.ringKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	return decodeRing(resp.Kvs[0].Value)
+}
+
+// SaveRing writes state if the stored ring is still the version it was
+// derived from, comparing revisions so a concurrent save cannot slip in
+// between the check and the write. While this server leads migrations,
+// the write also requires its election key, so a leader whose lease
+// expired mid-migration cannot overwrite the ring of its successor.
+func (e *EtcdVideoMetadataService) SaveRing(state *RingState) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Get(ctx, e.ringKey())
+	if err != nil {
+		return err
+	}
+	cmp := clientv3.Compare(clientv3.CreateRevision(e.ringKey()), "=", 0)
+	if len(resp.Kvs) > 0 {
+		stored, err := decodeRing(resp.Kvs[0].Value)
+		if err != nil {
+			return err
+		}
+		if stored.Version != state.Version-1 {
+			return ErrRingConflict
+		}
+		cmp = clientv3.Compare(clientv3.ModRevision(e.ringKey()), "=", resp.Kvs[0].ModRevision)
+	} else if state.Version != 1 {
+		return ErrRingConflict
+	}
+
+	cmps := []clientv3.Cmp{cmp}
+	var orElse []clientv3.Op
+	leaderKey, leaderRev := e.leadership()
+	if leaderKey != "" {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(leaderKey), "=", leaderRev))
+		orElse = append(orElse, clientv3.OpGet(leaderKey))
+	}
+
+	txn, err := e.client.Txn(ctx).If(cmps...).Then(clientv3.OpPut(e.ringKey(), string(value))).Else(orElse...).Commit()
+	if err != nil {
+		return err
+	}
+	if !txn.Succeeded {
+		if leaderKey != "" {
+			kvs := txn.Responses[0].GetResponseRange().Kvs
+			if len(kvs) == 0 || kvs[0].CreateRevision != leaderRev {
+				return ErrNotLeader
+			}
+		}
+		return ErrRingConflict
+	}
+	return nil
+}
+
+// WatchRing reads the ring, then follows changes from the next revision.
+// If the watch breaks, for instance after a compaction, it starts over
+// from a fresh read so no change is missed.
+func (e *EtcdVideoMetadataService) WatchRing(ctx context.Context, fn func(*RingState)) {
+	for ctx.Err() == nil {
+		resp, err := e.client.Get(ctx, e.ringKey())
+		if err != nil {
+			log.Printf("[RING] Failed to read ring: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(resp.Kvs) > 0 {
+			if state, err := decodeRing(resp.Kvs[0].Value); err != nil {
+				log.Printf("[RING] Ignoring unreadable ring: %v", err)
+			} else {
+				fn(state)
+			}
+		}
+
+		for wresp := range e.client.Watch(ctx, e.ringKey(), clientv3.WithRev(resp.Header.Revision+1)) {
+			if err := wresp.Err(); err != nil {
+				log.Printf("[RING] Watch failed, restarting: %v", err)
+				break
+			}
+			for _, ev := range wresp.Events {
+				if ev.Type != clientv3.EventTypePut {
+					continue
+				}
+				state, err := decodeRing(ev.Kv.Value)
+				if err != nil {
+					log.Printf("[RING] Ignoring unreadable ring: %v", err)
+					continue
+				}
+				fn(state)
+			}
+		}
+	}
+}
+
+func decodeRing(value []byte) (*RingState, error) {
+	var state RingState
+	if err := json.Unmarshal(value, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// LeadMigrations campaigns in the ring election and returns once this
+// server is leader. Leadership is lost with the session's lease, which
+// cancels leading.
+func (e *EtcdVideoMetadataService) LeadMigrations(ctx context.Context) (context.Context, func(), error) {
+	session, err := e.leaseSession()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create lease: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	candidate := fmt.Sprintf("%s/%d", hostname, os.Getpid())
+	election := concurrency.NewElection(session, e.ringLeaderPrefix())
+	if err := election.Campaign(ctx, candidate); err != nil {
+		return nil, nil, fmt.Errorf("failed to become migration leader: %v", err)
+	}
+	log.Printf("[RING] %s is migration leader", candidate)
+	e.setLeadership(election.Key(), election.Rev())
+
+	leading, stop := context.WithCancelCause(context.Background())
+	go func() {
+		select {
+		case <-session.Done():
+			log.Printf("[RING] %s lost migration leadership: lease expired", candidate)
+		case <-leading.Done():
+		}
+		stop(ErrNotLeader)
+	}()
+
+	return leading, func() {
+		stop(ErrNotLeader)
+		e.setLeadership("", 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := election.Resign(ctx); err != nil {
+			log.Printf("[RING] Failed to resign migration leadership: %v", err)
+		}
+	}, nil
+}
+
+func (e *EtcdVideoMetadataService) setLeadership(key string, rev int64) {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	e.leaderKey, e.leaderRev = key, rev
+}
+
+func (e *EtcdVideoMetadataService) leadership() (string, int64) {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+	return e.leaderKey, e.leaderRev
+}
+
+// leaseSession returns the session whose lease backs leadership, creating
+// a new one if there is none or its lease has expired.
+func (e *EtcdVideoMetadataService) leaseSession() (*concurrency.Session, error) {
+	e.sessionMu.Lock()
+	defer e.sessionMu.Unlock()
+
+	if e.session != nil {
+		select {
+		case <-e.session.Done():
+			e.session = nil
+		default:
+			return e.session, nil
+		}
+	}
+	session, err := concurrency.NewSession(e.client, concurrency.WithTTL(ringLeaseTTL))
+	if err != nil {
+		return nil, err
+	}
+	e.session = session
+	return session, nil
+}
+
+func (e *EtcdVideoMetadataService) CreateOperation(op *RebalanceOperation) error {
+	return e.putOperation(op)
+}
+
+func (e *EtcdVideoMetadataService) UpdateOperation(op *RebalanceOperation) error {
+	return e.putOperation(op)
+}
+
+func (e *EtcdVideoMetadataService) putOperation(op *RebalanceOperation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	_, err = e.client.Put(ctx, e.operationsPrefix()+op.Id, string(value))
+	return err
+}
+
+func (e *EtcdVideoMetadataService) ReadOperation(id string) (*RebalanceOperation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.operationsPrefix()+id)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	var op RebalanceOperation
+	if err := json.Unmarshal(resp.Kvs[0].Value, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (e *EtcdVideoMetadataService) AddPendingDelete(d PendingDelete) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := e.client.Put(ctx, e.pendingDeletesPrefix()+d.Node+"/"+d.VideoId, "")
+	return err
+}
+
+func (e *EtcdVideoMetadataService) RemovePendingDelete(d PendingDelete) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, e.pendingDeletesPrefix()+d.Node+"/"+d.VideoId)
+	return err
+}
+
+func (e *EtcdVideoMetadataService) ListPendingDeletes() ([]PendingDelete, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	prefix := e.pendingDeletesPrefix()
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	var deletes []PendingDelete
+	for _, kv := range resp.Kvs {
+		// Video IDs never contain a slash, so the last one ends the node.
+		rest := strings.TrimPrefix(string(kv.Key), prefix)
+		i := strings.LastIndexByte(rest, '/')
+		if i < 0 {
+			log.Printf("[ETCD] Skipping malformed pending delete %s", kv.Key)
+			continue
+		}
+		deletes = append(deletes, PendingDelete{Node: rest[:i], VideoId: rest[i+1:]})
+	}
+	return deletes, nil
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// newTestEtcd connects to the etcd cluster in ETCD_ENDPOINTS under a
+// prefix of its own, skipping the test if the variable is unset. Cleanup
+// removes the whole test root, which holds every key of the service.
+func newTestEtcd(t *testing.T) *EtcdVideoMetadataService {
+	t.Helper()
+	endpoints := os.Getenv("ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("ETCD_ENDPOINTS not set")
+	}
+	e, err := NewEtcdVideoMetadataService(endpoints)
+	if err != nil {
+		t.Fatalf("NewEtcdVideoMetadataService: %v", err)
+	}
+	root := fmt.Sprintf("/test-%d/", time.Now().UnixNano())
+	e.prefix = root + "videos/"
+	t.Cleanup(func() {
+		e.client.Delete(context.Background(), root, clientv3.WithPrefix())
+		e.client.Close()
+	})
+	return e
+}
+
+func TestEtcdCreateRejectsDuplicateID(t *testing.T) {
+	e := newTestEtcd(t)
+	meta := &VideoMetadata{Id: "01JAAAAAAAAAAAAAAAAAAAAAAA", Title: "first", UploadedAt: time.Now(), Status: VideoReady}
+	if err := e.Create(meta); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() { e.Delete(meta.Id) })
+
+	dup := *meta
+	dup.Title = "second"
+	if err := e.Create(&dup); !errors.Is(err, ErrVideoExists) {
+		t.Fatalf("duplicate Create = %v, want ErrVideoExists", err)
+	}
+	if got, _ := e.Read(meta.Id); got.Title != "first" {
+		t.Fatalf("duplicate Create overwrote title with %q", got.Title)
+	}
+}
+
+// newTestEtcdPeer connects a second web server's service to the same
+// keys as e.
+func newTestEtcdPeer(t *testing.T, e *EtcdVideoMetadataService) *EtcdVideoMetadataService {
+	t.Helper()
+	peer, err := NewEtcdVideoMetadataService(os.Getenv("ETCD_ENDPOINTS"))
+	if err != nil {
+		t.Fatalf("NewEtcdVideoMetadataService: %v", err)
+	}
+	peer.prefix = e.prefix
+	t.Cleanup(func() { peer.client.Close() })
+	return peer
+}
+
+func TestEtcdWatchRingSeesSavedRing(t *testing.T) {
+	e := newTestEtcd(t)
+	peer := newTestEtcdPeer(t, e)
+	v1 := &RingState{Version: 1, VNodes: 16, Weights: map[string]int{"a:1": 1}}
+	if err := e.SaveRing(v1); err != nil {
+		t.Fatalf("SaveRing v1: %v", err)
+	}
+
+	rings := make(chan *RingState, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		peer.WatchRing(ctx, func(state *RingState) { rings <- state })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	next := func() *RingState {
+		t.Helper()
+		select {
+		case state := <-rings:
+			return state
+		case <-time.After(5 * time.Second):
+			t.Fatal("WatchRing did not report a ring")
+			return nil
+		}
+	}
+	if got := next(); got.Version != 1 {
+		t.Fatalf("first watched ring = version %d, want 1", got.Version)
+	}
+	v2 := &RingState{Version: 2, VNodes: 16, Weights: map[string]int{"a:1": 1, "b:1": 1}}
+	if err := e.SaveRing(v2); err != nil {
+		t.Fatalf("SaveRing v2: %v", err)
+	}
+	if got := next(); got.Version != 2 || len(got.Weights) != 2 {
+		t.Fatalf("watched ring = %+v, want version 2 with two nodes", got)
+	}
+}
+
+func TestEtcdStaleLeaderCannotSaveRing(t *testing.T) {
+	e := newTestEtcd(t)
+	peer := newTestEtcdPeer(t, e)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	leading, _, err := e.LeadMigrations(ctx)
+	if err != nil {
+		t.Fatalf("LeadMigrations: %v", err)
+	}
+	v1 := &RingState{Version: 1, VNodes: 16, Weights: map[string]int{"a:1": 1}}
+	if err := e.SaveRing(v1); err != nil {
+		t.Fatalf("SaveRing as leader: %v", err)
+	}
+
+	// Losing the lease ends leadership without a release, as when a
+	// leader stalls past its TTL.
+	e.sessionMu.Lock()
+	e.session.Close()
+	e.sessionMu.Unlock()
+	select {
+	case <-leading.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("leading not done after the lease was lost")
+	}
+	if cause := context.Cause(leading); !errors.Is(cause, ErrNotLeader) {
+		t.Fatalf("leading cause = %v, want ErrNotLeader", cause)
+	}
+
+	_, release, err := peer.LeadMigrations(ctx)
+	if err != nil {
+		t.Fatalf("peer LeadMigrations: %v", err)
+	}
+	defer release()
+	v2 := &RingState{Version: 2, VNodes: 16, Weights: map[string]int{"a:1": 1, "b:1": 1}}
+	if err := peer.SaveRing(v2); err != nil {
+		t.Fatalf("SaveRing by the new leader: %v", err)
+	}
+
+	stale := &RingState{Version: 3, VNodes: 16, Weights: map[string]int{"c:1": 1}}
+	if err := e.SaveRing(stale); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("SaveRing by the stale leader = %v, want ErrNotLeader", err)
+	}
+	if got, err := peer.LoadRing(); err != nil || got.Version != 2 {
+		t.Fatalf("LoadRing = %+v, %v; want version 2", got, err)
+	}
+}
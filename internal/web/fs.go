@@ -2,8 +2,11 @@ package web
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+
+	"tritontube/internal/contentkey"
 )
 
 type FSVideoContentService struct {
@@ -18,25 +21,90 @@ func NewFSVideoContentService(base_dir string) *FSVideoContentService {
 	}
 }
 
-func (s *FSVideoContentService) Write(videoId string, filename string, data []byte) error {
-	dirPath := filepath.Join(s.base_dir, videoId)
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return fmt.Errorf("failed tp create directory: %w", err)
+func (s *FSVideoContentService) Create(videoId string, filename string) (io.WriteCloser, error) {
+	key, err := contentkey.New(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	filePath := key.Path(s.base_dir)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed tp create directory: %w", err)
 	}
 
-	filePath := filepath.Join(dirPath, filename)
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 
-	return nil
+	return file, nil
+}
+
+func (s *FSVideoContentService) Open(videoId string, filename string) (io.ReadCloser, int64, error) {
+	key, err := contentkey.New(videoId, filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := os.Open(key.Path(s.base_dir))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return file, info.Size(), nil
 }
 
-func (s *FSVideoContentService) Read(videoId string, filename string) ([]byte, error) {
-	filePath := filepath.Join(s.base_dir, videoId, filename)
-	data, err := os.ReadFile(filePath)
+func (s *FSVideoContentService) OpenRange(videoId string, filename string, offset, length int64) (io.ReadCloser, error) {
+	key, err := contentkey.New(videoId, filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
+	}
+	file, err := os.Open(key.Path(s.base_dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek file: %w", err)
 	}
-	return data, nil
+	if length == 0 {
+		return file, nil
+	}
+	return &limitedReadCloser{io.LimitReader(file, length), file}, nil
+}
+
+func (s *FSVideoContentService) Stat(videoId string, filename string) (*ContentInfo, error) {
+	key, err := contentkey.New(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(key.Path(s.base_dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	return &ContentInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *FSVideoContentService) ClusterInfo() ClusterInfo {
+	return ClusterInfo{Backend: "fs"}
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (s *FSVideoContentService) Delete(videoId string) error {
+	dirPath, err := contentkey.VideoDir(s.base_dir, videoId)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dirPath); err != nil {
+		return fmt.Errorf("failed to delete video: %w", err)
+	}
+	return nil
 }
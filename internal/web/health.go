@@ -0,0 +1,197 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"tritontube/internal/proto"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Node states from the heartbeat loop. A node is suspect after one failed
+// probe and down after downAfter consecutive failures; any successful
+// probe brings it back up.
+const (
+	NodeUp      = "up"
+	NodeSuspect = "suspect"
+	NodeDown    = "down"
+)
+
+// nodeHealth is the probe state of one storage node.
+type nodeHealth struct {
+	client   healthpb.HealthClient
+	state    string
+	failures int
+	lastSeen time.Time
+	lastErr  string
+}
+
+// healthTracker keeps probe state under its own mutex rather than svc.mu.
+// Probe goroutines record results without waiting on ring swaps or
+// contending with every request that reads the ring, while code already
+// holding svc.mu, such as node tracking and ListNodes, can still consult
+// it; the lock order is always svc.mu before h.mu.
+type healthTracker struct {
+	mu        sync.Mutex
+	nodes     map[string]*nodeHealth
+	downAfter int
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{nodes: make(map[string]*nodeHealth), downAfter: 3}
+}
+
+// track starts following addr over conn. New nodes count as up until the
+// first probe says otherwise.
+func (h *healthTracker) track(addr string, conn *grpc.ClientConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.nodes[addr] = &nodeHealth{client: healthpb.NewHealthClient(conn), state: NodeUp}
+}
+
+func (h *healthTracker) untrack(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.nodes, addr)
+}
+
+// state returns addr's current state, up if it is not tracked.
+func (h *healthTracker) state(addr string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if node, ok := h.nodes[addr]; ok {
+		return node.state
+	}
+	return NodeUp
+}
+
+// status returns a copy of addr's probe state.
+func (h *healthTracker) status(addr string) nodeHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if node, ok := h.nodes[addr]; ok {
+		return *node
+	}
+	return nodeHealth{state: NodeUp}
+}
+
+// record applies one probe result and logs state transitions.
+func (h *healthTracker) record(addr string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	node, ok := h.nodes[addr]
+	if !ok {
+		return
+	}
+
+	prev := node.state
+	if err == nil {
+		node.state = NodeUp
+		node.failures = 0
+		node.lastSeen = time.Now()
+		node.lastErr = ""
+	} else {
+		node.failures++
+		node.lastErr = err.Error()
+		node.state = NodeSuspect
+		if node.failures >= h.downAfter {
+			node.state = NodeDown
+		}
+	}
+	if node.state != prev {
+		log.Printf("[HEALTH] Node %s %s -> %s (%d consecutive failures)", addr, prev, node.state, node.failures)
+	}
+}
+
+// probeAll checks every tracked node concurrently, each with its own
+// timeout.
+func (h *healthTracker) probeAll(timeout time.Duration) {
+	h.mu.Lock()
+	clients := make(map[string]healthpb.HealthClient, len(h.nodes))
+	for addr, node := range h.nodes {
+		clients[addr] = node.client
+	}
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for addr, client := range clients {
+		wg.Add(1)
+		go func(addr string, client healthpb.HealthClient) {
+			defer wg.Done()
+			h.record(addr, probeNode(client, timeout))
+		}(addr, client)
+	}
+	wg.Wait()
+}
+
+// probeNode asks a node's grpc.health.v1 service whether Storage is
+// serving.
+func probeNode(client healthpb.HealthClient, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: proto.Storage_ServiceDesc.ServiceName})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("node reports %v", resp.Status)
+	}
+	return nil
+}
+
+// StartHealthChecks probes every storage node each interval, marking a
+// node down after downAfter consecutive failures. Reads try replicas
+// that are up before suspect and down ones.
+func (n *NetworkVideoContentService) StartHealthChecks(interval, timeout time.Duration, downAfter int) {
+	if downAfter < 1 {
+		downAfter = 1
+	}
+	n.health.mu.Lock()
+	n.health.downAfter = downAfter
+	n.health.mu.Unlock()
+
+	log.Printf("[HEALTH] Probing storage nodes every %v (timeout %v, down after %d failures)", interval, timeout, downAfter)
+	go func() {
+		n.health.probeAll(timeout)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n.health.probeAll(timeout)
+		}
+	}()
+}
+
+// preferHealthy reorders a replica set so up nodes come first, then
+// suspect, then down, keeping ring order within each state.
+func (n *NetworkVideoContentService) preferHealthy(addrs []string) []string {
+	rank := map[string]int{NodeUp: 0, NodeSuspect: 1, NodeDown: 2}
+	ordered := append([]string(nil), addrs...)
+	ranks := make(map[string]int, len(ordered))
+	for _, addr := range ordered {
+		ranks[addr] = rank[n.health.state(addr)]
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ranks[ordered[i]] < ranks[ordered[j]] })
+	return ordered
+}
+
+// nodeStatus reports addr's health for ListNodes.
+func (n *NetworkVideoContentService) nodeStatus(addr string, weight int) *proto.NodeStatus {
+	h := n.health.status(addr)
+	st := &proto.NodeStatus{
+		Address:             addr,
+		Weight:              int32(weight),
+		State:               h.state,
+		ConsecutiveFailures: int32(h.failures),
+		LastError:           h.lastErr,
+	}
+	if !h.lastSeen.IsZero() {
+		st.LastSeenUnix = h.lastSeen.Unix()
+	}
+	return st
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"errors"
+	"testing"
+
+	"tritontube/internal/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestNetworkService returns a service over addrs without dialing
+// them, each in the given health state.
+func newTestNetworkService(replicas int, states map[string]string) *NetworkVideoContentService {
+	weights := make(map[string]int)
+	n := &NetworkVideoContentService{
+		nodes:    make(map[string]proto.StorageClient),
+		conns:    make(map[string]*grpc.ClientConn),
+		health:   newHealthTracker(),
+		replicas: replicas,
+	}
+	for addr, state := range states {
+		weights[addr] = 1
+		n.health.nodes[addr] = &nodeHealth{state: state}
+	}
+	n.ring = newHashRing(8, weights)
+	return n
+}
+
+func TestHealthRecordTransitions(t *testing.T) {
+	h := newHealthTracker()
+	h.downAfter = 2
+	h.nodes["a:1"] = &nodeHealth{state: NodeUp}
+
+	steps := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("timeout"), NodeSuspect},
+		{errors.New("timeout"), NodeDown},
+		{errors.New("timeout"), NodeDown},
+		{nil, NodeUp},
+	}
+	for i, step := range steps {
+		h.record("a:1", step.err)
+		if got := h.state("a:1"); got != step.want {
+			t.Fatalf("after probe %d state = %s, want %s", i+1, got, step.want)
+		}
+	}
+}
+
+func TestPreferHealthyOrder(t *testing.T) {
+	n := newTestNetworkService(3, map[string]string{"a:1": NodeDown, "b:1": NodeUp, "c:1": NodeSuspect, "d:1": NodeUp})
+	got := n.preferHealthy([]string{"a:1", "b:1", "c:1", "d:1"})
+	want := []string{"b:1", "d:1", "c:1", "a:1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("preferHealthy = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCreateFailsFastWhenOwnerIsDown(t *testing.T) {
+	n := newTestNetworkService(2, map[string]string{"a:1": NodeUp, "b:1": NodeDown})
+
+	_, err := n.Create("01JAAAAAAAAAAAAAAAAAAAAAAA", "manifest.mpd")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Create with a down owner = %v, want Unavailable", err)
+	}
+}
@@ -1,19 +1,282 @@
 package web
 
-import "time"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
+const (
+	VideoProcessing = "processing"
+	VideoReady      = "ready"
+	VideoFailed     = "failed"
+)
+
+// VideoMetadata describes an uploaded video. Title, Description, Uploader
+// and Tags come from the upload form; the technical fields are filled in
+// from ffprobe and the transcoder output once processing finishes.
 type VideoMetadata struct {
-	Id         string
-	UploadedAt time.Time
+	Id           string        `json:"id"`
+	UploadedAt   time.Time     `json:"uploadedAt"`
+	Title        string        `json:"title"`
+	Description  string        `json:"description"`
+	Uploader     string        `json:"uploader"`
+	Duration     time.Duration `json:"duration"`
+	Width        int           `json:"width"`
+	Height       int           `json:"height"`
+	Codec        string        `json:"codec"`
+	FileSize     int64         `json:"fileSize"`
+	SegmentCount int           `json:"segmentCount"`
+	Tags         []string      `json:"tags"`
+	Status       string        `json:"status"`
 }
 
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortTitle  = "title"
+)
+
+// ErrInvalidCursor is returned by List for a cursor it did not issue for
+// the requested sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrVideoExists is returned by Create when a video with the same ID is
+// already stored.
+var ErrVideoExists = errors.New("video already exists")
+
+// ErrVideoNotFound is returned by Update when the video is not stored,
+// for instance because it was deleted after being read.
+var ErrVideoNotFound = errors.New("video not found")
+
+// ListOptions selects one page of videos. Search matches titles case
+// insensitively, anywhere in the title or, with SearchPrefix, only at its
+// start. Ties in the sort order are broken by video ID, and Cursor is the
+// NextCursor of the previous page. A zero Limit returns every match.
+type ListOptions struct {
+	Sort         string
+	Search       string
+	SearchPrefix bool
+	Limit        int
+	Cursor       string
+}
+
+// VideoPage is one page of List results. NextCursor is empty on the last
+// page.
+type VideoPage struct {
+	Videos     []VideoMetadata
+	NextCursor string
+}
+
+// VideoMetadataService stores video metadata. Records written before
+// Status existed read back as VideoReady.
 type VideoMetadataService interface {
 	Read(id string) (*VideoMetadata, error)
-	List() ([]VideoMetadata, error)
-	Create(videoId string, uploadedAt time.Time) error
+	List(opts ListOptions) (*VideoPage, error)
+	Create(meta *VideoMetadata) error
+	Update(meta *VideoMetadata) error
+	Delete(videoId string) error
+}
+
+// ClusterInfo describes the storage behind a VideoContentService.
+type ClusterInfo struct {
+	Backend     string        `json:"backend"`
+	Replicas    int           `json:"replicas,omitempty"`
+	VNodes      int           `json:"vnodes,omitempty"`
+	RingVersion int64         `json:"ringVersion,omitempty"`
+	Rebalancing bool          `json:"rebalancing,omitempty"`
+	Operation   string        `json:"operation,omitempty"`
+	Nodes       []ClusterNode `json:"nodes,omitempty"`
+}
+
+type ClusterNode struct {
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+	// State is "up", "suspect" or "down" where the backend health checks
+	// its nodes.
+	State string `json:"state,omitempty"`
+}
+
+// RingState is the persisted membership of the storage ring. Version goes
+// up by one with every membership change.
+type RingState struct {
+	Version int64
+	VNodes  int
+	Weights map[string]int
+	// Next is the membership files are being moved to, nil outside a
+	// rebalance, and Operation the ID of the rebalance moving them.
+	Next      map[string]int
+	Operation string
+}
+
+// ErrRingConflict is returned by SaveRing when the stored ring is not the
+// version the new one was derived from.
+var ErrRingConflict = errors.New("ring was changed concurrently")
+
+// ErrNotLeader is returned by SaveRing when this server led migrations
+// but another server has been elected since.
+var ErrNotLeader = errors.New("no longer the migration leader")
+
+// RingStore persists ring membership so nodes added or removed at runtime
+// survive a restart. LoadRing returns nil, nil if no ring has been saved.
+// SaveRing only replaces a stored ring of version state.Version-1.
+type RingStore interface {
+	LoadRing() (*RingState, error)
+	SaveRing(state *RingState) error
+}
+
+// RingWatcher is implemented by ring stores that several web servers can
+// share. WatchRing calls fn with the stored ring and then with every ring
+// saved after it, until ctx is done.
+type RingWatcher interface {
+	WatchRing(ctx context.Context, fn func(*RingState))
+}
+
+// MigrationLeader is implemented by ring stores that can elect one web
+// server at a time to change membership and move data. LeadMigrations
+// blocks until this server leads; release steps down. leading is done
+// once leadership is lost or released, and while this server leads,
+// SaveRing fails with ErrNotLeader if it has been lost.
+type MigrationLeader interface {
+	LeadMigrations(ctx context.Context) (leading context.Context, release func(), err error)
+}
+
+const (
+	OpRunning = "running"
+	OpDone    = "done"
+	OpFailed  = "failed"
+)
+
+// RebalanceOperation tracks one membership change and the files it
+// moves, so progress can be followed after the admin RPC returns and the
+// rebalance resumed if the web server restarts.
+type RebalanceOperation struct {
+	Id          string
+	Kind        string // "add", "remove" or "resume"
+	Node        string
+	Weight      int
+	Status      string
+	Error       string
+	RingVersion int64
+	FilesTotal  int64
+	FilesMoved  int64
+	BytesMoved  int64
+	FilesFailed int64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// OperationStore persists rebalance operations. ReadOperation returns
+// nil, nil for an unknown operation.
+type OperationStore interface {
+	CreateOperation(op *RebalanceOperation) error
+	UpdateOperation(op *RebalanceOperation) error
+	ReadOperation(id string) (*RebalanceOperation, error)
+}
+
+// PendingDelete is a video that one storage node has not yet confirmed
+// deleting.
+type PendingDelete struct {
+	Node    string
+	VideoId string
+}
+
+// PendingDeleteStore persists deletes that storage nodes have not yet
+// confirmed, so they are still retried after a web server restart.
+// Adding a delete twice or removing an unknown one is not an error.
+type PendingDeleteStore interface {
+	AddPendingDelete(d PendingDelete) error
+	RemovePendingDelete(d PendingDelete) error
+	ListPendingDeletes() ([]PendingDelete, error)
+}
+
+// ClusterInfoProvider is implemented by content services that can
+// describe their storage cluster.
+type ClusterInfoProvider interface {
+	ClusterInfo() ClusterInfo
+}
+
+type ContentInfo struct {
+	Size    int64
+	ModTime time.Time
+	// Sha256 is the hex digest of the content, if the backend tracks one.
+	Sha256 string
+}
+
+const (
+	JobQueued     = "queued"
+	JobProcessing = "processing"
+	JobDone       = "done"
+	JobFailed     = "failed"
+	// JobCancelled marks a job whose video was deleted before it ran.
+	JobCancelled = "cancelled"
+)
+
+// TranscodeJob tracks one upload from the spooled source file through
+// transcoding to stored content and metadata.
+type TranscodeJob struct {
+	Id         string
+	VideoId    string
+	Status     string
+	Error      string
+	SourcePath string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// JobService persists transcode jobs so queued work survives a restart.
+// ReadJob returns nil, nil for an unknown job.
+type JobService interface {
+	CreateJob(job *TranscodeJob) error
+	UpdateJob(job *TranscodeJob) error
+	ReadJob(id string) (*TranscodeJob, error)
+	ListJobs() ([]TranscodeJob, error)
+}
+
+// TranscodeResult lists what a Transcoder wrote into its output directory
+// along with what it learned about the source.
+type TranscodeResult struct {
+	// Manifest is the DASH manifest's filename.
+	Manifest string
+	// Files holds every output filename, including the manifest.
+	Files []string
+
+	Duration time.Duration
+	Width    int
+	Height   int
+	Codec    string
 }
 
+// Transcoder converts the source video at sourcePath into streamable files
+// written flat into outputDir.
+type Transcoder interface {
+	Transcode(sourcePath, outputDir string) (*TranscodeResult, error)
+}
+
+// VideoContentService stores the files that make up a video. Open returns
+// the file contents along with their size in bytes. OpenRange returns
+// length bytes starting at offset, or everything from offset on when
+// length is 0. Create returns a writer for a new file; the file is only
+// guaranteed to be stored once Close returns nil. Delete removes every
+// file of a video and is safe to retry after a partial failure.
 type VideoContentService interface {
-	Read(videoId string, filename string) ([]byte, error)
-	Write(videoId string, filename string, data []byte) error
+	Open(videoId string, filename string) (io.ReadCloser, int64, error)
+	OpenRange(videoId string, filename string, offset, length int64) (io.ReadCloser, error)
+	Stat(videoId string, filename string) (*ContentInfo, error)
+	Create(videoId string, filename string) (io.WriteCloser, error)
+	Delete(videoId string) error
+}
+
+// PinnedContent is one stored copy of a file: its info and ranged reads
+// of those same bytes.
+type PinnedContent interface {
+	Info() *ContentInfo
+	OpenRange(offset, length int64) (io.ReadCloser, error)
+}
+
+// ContentPinner is implemented by content services that keep several
+// copies of a file, so one response can describe and serve the same copy.
+type ContentPinner interface {
+	Pin(videoId string, filename string) (PinnedContent, error)
 }
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func newJobID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// StartTranscodeWorkers starts a pool of workers that transcode uploads
+// spooled under spoolDir, and requeues any jobs left unfinished by a
+// previous run. Resumable upload sessions are kept under spoolDir/uploads.
+func (s *server) StartTranscodeWorkers(workers int, spoolDir string) error {
+	if workers < 1 {
+		return fmt.Errorf("need at least one transcode worker")
+	}
+	if err := os.MkdirAll(spoolDir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool dir: %v", err)
+	}
+	uploads, err := newUploadStore(filepath.Join(spoolDir, "uploads"))
+	if err != nil {
+		return err
+	}
+	s.StopTranscodeWorkers()
+	s.spoolDir = spoolDir
+	s.uploads = uploads
+
+	queue := make(chan string, 1024)
+	for i := 0; i < workers; i++ {
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			s.transcodeWorker(queue)
+		}()
+	}
+	s.jobsMu.Lock()
+	s.jobQueue = queue
+	s.jobsMu.Unlock()
+
+	jobs, err := s.jobService.ListJobs()
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %v", err)
+	}
+	for _, job := range jobs {
+		if job.Status == JobQueued || job.Status == JobProcessing {
+			log.Printf("[JOBS] Requeueing unfinished job %s (%s)", job.Id, job.VideoId)
+			if err := s.enqueueJob(job.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("[JOBS] Started %d transcode workers, spooling to %s", workers, spoolDir)
+	return nil
+}
+
+// StopTranscodeWorkers stops taking jobs and waits for the workers to
+// finish the ones they are running. Jobs still queued stay queued in the
+// job store and are requeued by the next StartTranscodeWorkers.
+func (s *server) StopTranscodeWorkers() {
+	s.jobsMu.Lock()
+	if s.jobQueue != nil {
+		close(s.jobQueue)
+		s.jobQueue = nil
+	}
+	s.jobsMu.Unlock()
+	s.workers.Wait()
+
+	if s.uploads != nil {
+		s.uploads.close()
+	}
+}
+
+// errNoTranscodeWorkers is returned when a job is queued while the
+// transcode workers are not running. It is safe to show to clients.
+var errNoTranscodeWorkers = errors.New("transcoding is not available")
+
+func (s *server) workersRunning() bool {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	return s.jobQueue != nil
+}
+
+// enqueueJob hands a job to the worker pool, blocking only while the
+// queue is full.
+func (s *server) enqueueJob(id string) error {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	if s.jobQueue == nil {
+		return errNoTranscodeWorkers
+	}
+	s.jobQueue <- id
+	return nil
+}
+
+func (s *server) transcodeWorker(queue <-chan string) {
+	for id := range queue {
+		job, err := s.jobService.ReadJob(id)
+		if err != nil || job == nil {
+			log.Printf("[JOBS] Failed to load job %s: %v", id, err)
+			continue
+		}
+		if job.Status != JobQueued && job.Status != JobProcessing {
+			log.Printf("[JOBS] Skipping job %s (%s): %s", job.Id, job.VideoId, job.Status)
+			continue
+		}
+
+		s.setJobStatus(job, JobProcessing, "")
+		if err := s.runJob(job); err != nil {
+			if s.videoDeleted(job.VideoId, err) {
+				log.Printf("[JOBS] Job %s (%s) cancelled: video was deleted", job.Id, job.VideoId)
+				os.Remove(job.SourcePath)
+				s.setJobStatus(job, JobCancelled, "video was deleted")
+				continue
+			}
+			// The source stays spooled so the job can be retried.
+			log.Printf("[JOBS] Job %s (%s) failed: %v", job.Id, job.VideoId, err)
+			s.setVideoStatus(job.VideoId, VideoFailed)
+			s.setJobStatus(job, JobFailed, err.Error())
+			continue
+		}
+		log.Printf("[JOBS] Job %s (%s) done", job.Id, job.VideoId)
+		os.Remove(job.SourcePath)
+		s.setJobStatus(job, JobDone, "")
+	}
+}
+
+// videoDeleted reports whether a job failed with err because its video
+// was deleted while it ran.
+func (s *server) videoDeleted(videoId string, err error) bool {
+	if errors.Is(err, ErrVideoNotFound) {
+		return true
+	}
+	meta, readErr := s.metadataService.Read(videoId)
+	return readErr == nil && meta == nil
+}
+
+// retryJob requeues a failed job whose source is still spooled and whose
+// video still exists.
+func (s *server) retryJob(job *TranscodeJob) error {
+	if job.Status != JobFailed {
+		return fmt.Errorf("job is %s, only failed jobs can be retried", job.Status)
+	}
+	if _, err := os.Stat(job.SourcePath); err != nil {
+		return fmt.Errorf("job source is gone")
+	}
+	if !s.workersRunning() {
+		return errNoTranscodeWorkers
+	}
+	meta, err := s.metadataService.Read(job.VideoId)
+	if err != nil {
+		return fmt.Errorf("failed to load video: %v", err)
+	}
+	if meta == nil {
+		return fmt.Errorf("video was deleted")
+	}
+	s.setJobStatus(job, JobQueued, "")
+	s.setVideoStatus(job.VideoId, VideoProcessing)
+	if err := s.enqueueJob(job.Id); err != nil {
+		return err
+	}
+	log.Printf("[JOBS] Retrying job %s (%s)", job.Id, job.VideoId)
+	return nil
+}
+
+// removeJobSources deletes the spooled sources of videoId's jobs when the
+// video itself is deleted, cancelling those still queued. A job already
+// processing notices the video is gone when it finishes and cleans up
+// after itself.
+func (s *server) removeJobSources(videoId string) {
+	if s.jobService == nil {
+		return
+	}
+	jobs, err := s.jobService.ListJobs()
+	if err != nil {
+		log.Printf("[JOBS] Failed to list jobs: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.VideoId != videoId {
+			continue
+		}
+		switch job.Status {
+		case JobQueued:
+			s.setJobStatus(&job, JobCancelled, "video was deleted")
+			os.Remove(job.SourcePath)
+		case JobFailed:
+			os.Remove(job.SourcePath)
+		}
+	}
+}
+
+func (s *server) setJobStatus(job *TranscodeJob, status, errMsg string) {
+	job.Status = status
+	job.Error = errMsg
+	job.UpdatedAt = time.Now()
+	if err := s.jobService.UpdateJob(job); err != nil {
+		log.Printf("[JOBS] Failed to update job %s: %v", job.Id, err)
+	}
+}
+
+func (s *server) setVideoStatus(videoId, status string) {
+	meta, err := s.metadataService.Read(videoId)
+	if err != nil || meta == nil {
+		log.Printf("[JOBS] Failed to load metadata for %s: %v", videoId, err)
+		return
+	}
+	meta.Status = status
+	if err := s.metadataService.Update(meta); err != nil {
+		log.Printf("[JOBS] Failed to update metadata for %s: %v", videoId, err)
+	}
+}
+
+// SetTranscoder replaces the transcoder used for new jobs.
+func (s *server) SetTranscoder(t Transcoder) {
+	s.transcoder = t
+}
+
+// runJob transcodes the spooled source, stores every output file and
+// finally fills in the video's metadata and marks it ready. If it fails
+// after storing output, the stored files are deleted again.
+func (s *server) runJob(job *TranscodeJob) (err error) {
+	tempDir, err := os.MkdirTemp("", "transcode-*")
+	if err != nil {
+		return fmt.Errorf("cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	stored := false
+	defer func() {
+		if err != nil && stored {
+			if delErr := s.contentService.Delete(job.VideoId); delErr != nil {
+				log.Printf("[JOBS] Failed to delete partial output of %s: %v", job.VideoId, delErr)
+			}
+		}
+	}()
+
+	result, err := s.transcoder.Transcode(job.SourcePath, tempDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range result.Files {
+		stored = true
+		if err := s.storeFile(job.VideoId, name, filepath.Join(tempDir, name)); err != nil {
+			return fmt.Errorf("failed to store content: %v", err)
+		}
+	}
+
+	meta, err := s.metadataService.Read(job.VideoId)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %v", err)
+	}
+	if meta == nil {
+		return ErrVideoNotFound
+	}
+	meta.Duration = result.Duration
+	meta.Width = result.Width
+	meta.Height = result.Height
+	meta.Codec = result.Codec
+	meta.SegmentCount = 0
+	for _, name := range result.Files {
+		if strings.HasSuffix(name, ".m4s") {
+			meta.SegmentCount++
+		}
+	}
+	if info, err := os.Stat(job.SourcePath); err == nil {
+		meta.FileSize = info.Size()
+	}
+	meta.Status = VideoReady
+	if err := s.metadataService.Update(meta); err != nil {
+		return fmt.Errorf("failed to store metadata: %w", err)
+	}
+	return nil
+}
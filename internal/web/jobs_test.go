@@ -0,0 +1,342 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newJobServer returns a server that transcodes with FakeTranscoder on one
+// worker, keeping metadata and jobs in SQLite and content in contentSvc.
+func newJobServer(t *testing.T, contentSvc VideoContentService) (*server, *SQLiteVideoMetadataService) {
+	t.Helper()
+	dir := t.TempDir()
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	s := NewServer(metadata, contentSvc, metadata)
+	s.SetTranscoder(NewFakeTranscoder(false))
+	if err := s.StartTranscodeWorkers(1, filepath.Join(dir, "spool")); err != nil {
+		t.Fatalf("StartTranscodeWorkers: %v", err)
+	}
+	t.Cleanup(s.StopTranscodeWorkers)
+	return s, metadata
+}
+
+// waitForJob polls until the job leaves the queued and processing states.
+func waitForJob(t *testing.T, jobs JobService, id string) *TranscodeJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := jobs.ReadJob(id)
+		if err != nil {
+			t.Fatalf("ReadJob: %v", err)
+		}
+		if job.Status != JobQueued && job.Status != JobProcessing {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+// flakyContent fails to create one file while failing is set.
+type flakyContent struct {
+	*FSVideoContentService
+	failing  atomic.Bool
+	filename string
+}
+
+func (f *flakyContent) Create(videoId, filename string) (io.WriteCloser, error) {
+	if f.failing.Load() && filename == f.filename {
+		return nil, errors.New("node unavailable")
+	}
+	return f.FSVideoContentService.Create(videoId, filename)
+}
+
+func TestFailedJobCleansUpAndCanBeRetried(t *testing.T) {
+	contentDir := t.TempDir()
+	content := &flakyContent{
+		FSVideoContentService: NewFSVideoContentService(contentDir),
+		filename:              posterFile,
+	}
+	content.failing.Store(true)
+	s, metadata := newJobServer(t, content)
+
+	meta, job, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "clip"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+	job = waitForJob(t, metadata, job.Id)
+	if job.Status != JobFailed {
+		t.Fatalf("job status = %s, want %s", job.Status, JobFailed)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(contentDir, meta.Id)); len(entries) != 0 {
+		t.Fatalf("failed job left %d stored files", len(entries))
+	}
+	if _, err := os.Stat(job.SourcePath); err != nil {
+		t.Fatalf("failed job's source was removed: %v", err)
+	}
+	if got, _ := metadata.Read(meta.Id); got.Status != VideoFailed {
+		t.Fatalf("video status = %s, want %s", got.Status, VideoFailed)
+	}
+
+	content.failing.Store(false)
+	if err := s.retryJob(job); err != nil {
+		t.Fatalf("retryJob: %v", err)
+	}
+	job = waitForJob(t, metadata, job.Id)
+	if job.Status != JobDone {
+		t.Fatalf("retried job status = %s (%s), want %s", job.Status, job.Error, JobDone)
+	}
+	if _, err := os.Stat(job.SourcePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("done job's source was kept: %v", err)
+	}
+	if got, _ := metadata.Read(meta.Id); got.Status != VideoReady {
+		t.Fatalf("video status = %s, want %s", got.Status, VideoReady)
+	}
+	if err := s.retryJob(job); err == nil {
+		t.Fatal("retryJob accepted a finished job")
+	}
+}
+
+func TestUploadTranscodeAndServe(t *testing.T) {
+	contentDir := t.TempDir()
+	s, metadata := newJobServer(t, NewFSVideoContentService(contentDir))
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("description", "a test clip")
+	form.WriteField("tags", "demo, test")
+	part, _ := form.CreateFormFile("file", "holiday clip.mp4")
+	part.Write([]byte("fake mp4 bytes"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.handleUpload(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+
+	job := waitForJob(t, metadata, rec.Header().Get("X-Job-Id"))
+	if job.Status != JobDone {
+		t.Fatalf("job status = %s (%s), want %s", job.Status, job.Error, JobDone)
+	}
+
+	meta, err := metadata.Read(job.VideoId)
+	if err != nil || meta == nil {
+		t.Fatalf("Read(%s) = %v, %v", job.VideoId, meta, err)
+	}
+	if meta.Status != VideoReady || meta.Title != "holiday clip" || meta.SegmentCount != 4 ||
+		meta.Duration != 12*time.Second || meta.Width != 426 || meta.Height != 240 {
+		t.Fatalf("metadata = %+v", meta)
+	}
+	if strings.Join(meta.Tags, ",") != "demo,test" {
+		t.Fatalf("tags = %v, want [demo test]", meta.Tags)
+	}
+
+	for _, name := range []string{"manifest.mpd", "init-0.m4s", "chunk-0-00003.m4s", posterFile, thumbnailTrack} {
+		rec := getContent(s, "/content/"+meta.Id+"/"+name, "")
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s = %d with %d bytes", name, rec.Code, rec.Body.Len())
+		}
+	}
+	if rec := getContent(s, "/content/"+meta.Id+"/chunk-0-00001.m4s", ""); rec.Body.String() != "fake media segment 1\n" {
+		t.Errorf("chunk-0-00001.m4s = %q", rec.Body)
+	}
+}
+
+// gatedTranscoder signals started and then waits for release before
+// transcoding.
+type gatedTranscoder struct {
+	Transcoder
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedTranscoder) Transcode(sourcePath, outputDir string) (*TranscodeResult, error) {
+	g.started <- struct{}{}
+	<-g.release
+	return g.Transcoder.Transcode(sourcePath, outputDir)
+}
+
+func TestDeletingVideoCancelsItsJobs(t *testing.T) {
+	contentDir := t.TempDir()
+	s, metadata := newJobServer(t, NewFSVideoContentService(contentDir))
+	gate := &gatedTranscoder{Transcoder: s.transcoder, started: make(chan struct{}), release: make(chan struct{})}
+	s.SetTranscoder(gate)
+
+	running, runningJob, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "running"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+	<-gate.started
+	queued, queuedJob, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "queued"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+
+	// The queued job is cancelled at once.
+	if err := s.DeleteVideo(queued.Id); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	if job, _ := metadata.ReadJob(queuedJob.Id); job.Status != JobCancelled {
+		t.Fatalf("queued job status = %s, want %s", job.Status, JobCancelled)
+	}
+	if _, err := os.Stat(queuedJob.SourcePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("queued job's source was kept: %v", err)
+	}
+
+	// The running one cleans up once it finishes.
+	if err := s.DeleteVideo(running.Id); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	close(gate.release)
+	if job := waitForJob(t, metadata, runningJob.Id); job.Status != JobCancelled {
+		t.Fatalf("running job status = %s (%s), want %s", job.Status, job.Error, JobCancelled)
+	}
+	if _, err := os.Stat(runningJob.SourcePath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("running job's source was kept: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(contentDir, running.Id)); len(entries) != 0 {
+		t.Fatalf("deleted video's output was stored: %d files", len(entries))
+	}
+}
+
+// jobReadSpy records which job IDs were looked up.
+type jobReadSpy struct {
+	JobService
+	reads []string
+}
+
+func (j *jobReadSpy) ReadJob(id string) (*TranscodeJob, error) {
+	j.reads = append(j.reads, id)
+	return j.JobService.ReadJob(id)
+}
+
+func TestJobIDsAreValidated(t *testing.T) {
+	s, metadata := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	spy := &jobReadSpy{JobService: metadata}
+	s.jobService = spy
+
+	for _, path := range []string{"/jobs/..%2Fvideos%2Fx", "/jobs/ABCDEF", "/jobs/zz", "/jobs/" + strings.Repeat("a", 65)} {
+		rec := httptest.NewRecorder()
+		s.handleJob(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, http.StatusBadRequest)
+		}
+	}
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/jobs/.."},
+		{http.MethodGet, "/api/v1/jobs/not-hex"},
+		{http.MethodPost, "/api/v1/jobs/not-hex/retry"},
+	} {
+		rec := uploadRequest(s, req.method, req.path, nil, nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"invalid_argument"`) {
+			t.Errorf("%s %s = %d %s, want %d", req.method, req.path, rec.Code, rec.Body, http.StatusBadRequest)
+		}
+	}
+	if len(spy.reads) != 0 {
+		t.Fatalf("invalid job IDs were looked up: %q", spy.reads)
+	}
+
+	// A well-formed ID that names no job is simply not found.
+	rec := httptest.NewRecorder()
+	s.handleJob(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+newJobID(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET of an unknown job = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := uploadRequest(s, http.MethodGet, "/api/v1/jobs/"+newJobID(), nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("API GET of an unknown job = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// deleteBeforeReady deletes a video just before it is marked ready, as
+// if a delete request had arrived while its job stored its metadata.
+type deleteBeforeReady struct {
+	VideoMetadataService
+}
+
+func (d *deleteBeforeReady) Update(meta *VideoMetadata) error {
+	if meta.Status == VideoReady {
+		d.Delete(meta.Id)
+	}
+	return d.VideoMetadataService.Update(meta)
+}
+
+func TestJobForVideoDeletedBeforeUpdateIsCancelled(t *testing.T) {
+	contentDir := t.TempDir()
+	s, metadata := newJobServer(t, NewFSVideoContentService(contentDir))
+	s.metadataService = &deleteBeforeReady{VideoMetadataService: metadata}
+
+	if err := metadata.Update(&VideoMetadata{Id: "missing", Status: VideoReady}); !errors.Is(err, ErrVideoNotFound) {
+		t.Fatalf("Update of a missing video = %v, want ErrVideoNotFound", err)
+	}
+
+	meta, job, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "clip"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+	if job := waitForJob(t, metadata, job.Id); job.Status != JobCancelled {
+		t.Fatalf("job status = %s (%s), want %s", job.Status, job.Error, JobCancelled)
+	}
+	if got, _ := metadata.Read(meta.Id); got != nil {
+		t.Fatalf("deleted video was written back: %+v", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(contentDir, meta.Id)); len(entries) != 0 {
+		t.Fatalf("deleted video's output was kept: %d files", len(entries))
+	}
+}
+
+func TestJobsFailEarly(t *testing.T) {
+	dir := t.TempDir()
+	metadata, err := NewSQLiteVideoMetadataService(filepath.Join(dir, "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	idle := NewServer(metadata, NewFSVideoContentService(dir), metadata)
+	if _, _, err := idle.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "clip"}); !errors.Is(err, errNoTranscodeWorkers) {
+		t.Fatalf("queueUpload without workers = %v, want errNoTranscodeWorkers", err)
+	}
+	if page, _ := metadata.List(ListOptions{}); len(page.Videos) != 0 {
+		t.Fatalf("queueUpload without workers stored %d videos", len(page.Videos))
+	}
+
+	content := &flakyContent{
+		FSVideoContentService: NewFSVideoContentService(t.TempDir()),
+		filename:              posterFile,
+	}
+	content.failing.Store(true)
+	s, metadata := newJobServer(t, content)
+	meta, job, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "clip"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+	job = waitForJob(t, metadata, job.Id)
+	if err := metadata.Delete(meta.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	content.failing.Store(false)
+	if err := s.retryJob(job); err == nil {
+		t.Fatal("retryJob requeued the job of a deleted video")
+	}
+	if got, _ := metadata.ReadJob(job.Id); got.Status != JobFailed {
+		t.Fatalf("job status = %s, want %s", got.Status, JobFailed)
+	}
+	if got, _ := metadata.Read(meta.Id); got != nil {
+		t.Fatalf("retryJob recreated the deleted video: %+v", got)
+	}
+}
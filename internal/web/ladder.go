@@ -0,0 +1,200 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Rendition is one rung of the adaptive bitrate ladder.
+type Rendition struct {
+	Name         string `json:"name"`
+	Height       int    `json:"height"`
+	VideoBitrate string `json:"videoBitrate"`
+}
+
+// Ladder is the set of video renditions every upload is transcoded into,
+// all described by a single DASH manifest.
+type Ladder struct {
+	AudioBitrate string      `json:"audioBitrate"`
+	Renditions   []Rendition `json:"renditions"`
+}
+
+var DefaultLadder = &Ladder{
+	AudioBitrate: "128k",
+	Renditions: []Rendition{
+		{Name: "240p", Height: 240, VideoBitrate: "400k"},
+		{Name: "480p", Height: 480, VideoBitrate: "1000k"},
+		{Name: "720p", Height: 720, VideoBitrate: "3000k"},
+		{Name: "1080p", Height: 1080, VideoBitrate: "6000k"},
+	},
+}
+
+// LoadLadder reads a JSON ladder definition such as
+//
+//	{"audioBitrate": "128k", "renditions": [{"name": "480p", "height": 480, "videoBitrate": "1000k"}]}
+func LoadLadder(path string) (*Ladder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ladder Ladder
+	if err := json.Unmarshal(data, &ladder); err != nil {
+		return nil, fmt.Errorf("invalid ladder %s: %v", path, err)
+	}
+	if len(ladder.Renditions) == 0 {
+		return nil, fmt.Errorf("ladder %s has no renditions", path)
+	}
+	for _, r := range ladder.Renditions {
+		if r.Height <= 0 || r.VideoBitrate == "" {
+			return nil, fmt.Errorf("ladder %s: rendition %q needs a height and videoBitrate", path, r.Name)
+		}
+		if r.Height%2 != 0 {
+			return nil, fmt.Errorf("ladder %s: rendition %q height must be even for H.264", path, r.Name)
+		}
+	}
+	if ladder.AudioBitrate == "" {
+		ladder.AudioBitrate = DefaultLadder.AudioBitrate
+	}
+	sort.Slice(ladder.Renditions, func(i, j int) bool {
+		return ladder.Renditions[i].Height < ladder.Renditions[j].Height
+	})
+	return &ladder, nil
+}
+
+// forSource returns the rungs not taller than the source. A source smaller
+// than every rung gets the lowest rung at its own height, rounded down to
+// an even number as H.264 requires, so nothing is ever upscaled.
+func (l *Ladder) forSource(height int) []Rendition {
+	var rungs []Rendition
+	for _, r := range l.Renditions {
+		if r.Height <= height {
+			rungs = append(rungs, r)
+		}
+	}
+	if len(rungs) == 0 {
+		lowest := l.Renditions[0]
+		lowest.Height = max(height&^1, 2)
+		rungs = append(rungs, lowest)
+	}
+	return rungs
+}
+
+type probeResult struct {
+	Width    int
+	Height   int
+	Codec    string
+	Duration time.Duration
+	HasAudio bool
+}
+
+// probeVideo asks ffprobe for the source's duration, video dimensions and
+// codec, and whether it has an audio track.
+func probeVideo(path string) (*probeResult, error) {
+	out, err := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,width,height:format=duration",
+		"-of", "json",
+		path,
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed: %v", err)
+	}
+
+	var parsed struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %v", err)
+	}
+
+	result := &probeResult{}
+	for _, s := range parsed.Streams {
+		switch s.CodecType {
+		case "video":
+			if result.Height == 0 {
+				result.Width, result.Height = s.Width, s.Height
+				result.Codec = s.CodecName
+			}
+		case "audio":
+			result.HasAudio = true
+		}
+	}
+	if result.Height == 0 {
+		return nil, fmt.Errorf("no video stream found")
+	}
+	if seconds, err := strconv.ParseFloat(parsed.Format.Duration, 64); err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	return result, nil
+}
+
+// hlsMasterPlaylist is the name ffmpeg gives the HLS master playlist it
+// writes next to manifest.mpd when HLS output is on. Its variant playlists
+// reference the same fMP4 segments as the DASH manifest, so HLS costs only
+// a few extra small files.
+const hlsMasterPlaylist = "master.m3u8"
+
+// dashArgs builds the ffmpeg command line that encodes every rung and
+// writes them as one DASH presentation at manifestPath, plus HLS playlists
+// over the same segments if hls is set. Rung heights are even and the
+// scale filter picks an even width, so odd-sized sources encode too.
+func dashArgs(sourcePath, manifestPath string, rungs []Rendition, audioBitrate string, hasAudio, hls bool) []string {
+	args := []string{"-i", sourcePath}
+	for range rungs {
+		args = append(args, "-map", "0:v:0")
+	}
+	if hasAudio {
+		args = append(args, "-map", "0:a:0")
+	}
+
+	args = append(args,
+		"-c:v", "libx264",
+		"-c:a", "aac",
+		"-bf", "1",
+		"-keyint_min", "120",
+		"-g", "120",
+		"-sc_threshold", "0",
+	)
+	for i, r := range rungs {
+		idx := strconv.Itoa(i)
+		args = append(args,
+			"-filter:v:"+idx, fmt.Sprintf("scale=-2:%d", r.Height),
+			"-b:v:"+idx, r.VideoBitrate,
+		)
+	}
+
+	adaptationSets := "id=0,streams=v"
+	if hasAudio {
+		args = append(args, "-b:a", audioBitrate)
+		adaptationSets += " id=1,streams=a"
+	}
+
+	if hls {
+		args = append(args, "-hls_playlist", "1")
+	}
+
+	return append(args,
+		"-adaptation_sets", adaptationSets,
+		"-f", "dash",
+		"-use_timeline", "1",
+		"-use_template", "1",
+		"-init_seg_name", "init-$RepresentationID$.m4s",
+		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.m4s",
+		"-seg_duration", "4",
+		manifestPath,
+	)
+}
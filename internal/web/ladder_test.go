@@ -0,0 +1,130 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeLadder(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ladder.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLadder(t *testing.T) {
+	ladder, err := LoadLadder(writeLadder(t, `{"renditions": [
+		{"name": "720p", "height": 720, "videoBitrate": "3000k"},
+		{"name": "360p", "height": 360, "videoBitrate": "800k"}
+	]}`))
+	if err != nil {
+		t.Fatalf("LoadLadder: %v", err)
+	}
+	if ladder.AudioBitrate != DefaultLadder.AudioBitrate {
+		t.Errorf("audio bitrate = %q, want the default %q", ladder.AudioBitrate, DefaultLadder.AudioBitrate)
+	}
+	if len(ladder.Renditions) != 2 || ladder.Renditions[0].Name != "360p" || ladder.Renditions[1].Name != "720p" {
+		t.Errorf("renditions = %v, want 360p then 720p", ladder.Renditions)
+	}
+
+	for name, data := range map[string]string{
+		"not JSON":        `renditions: 720p`,
+		"no renditions":   `{"audioBitrate": "96k", "renditions": []}`,
+		"no height":       `{"renditions": [{"name": "hd", "videoBitrate": "3000k"}]}`,
+		"negative height": `{"renditions": [{"name": "hd", "height": -720, "videoBitrate": "3000k"}]}`,
+		"no bitrate":      `{"renditions": [{"name": "hd", "height": 720}]}`,
+		"odd height":      `{"renditions": [{"name": "odd", "height": 481, "videoBitrate": "1000k"}]}`,
+	} {
+		if _, err := LoadLadder(writeLadder(t, data)); err == nil {
+			t.Errorf("LoadLadder accepted a ladder with %s", name)
+		}
+	}
+	if _, err := LoadLadder(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadLadder accepted a missing file")
+	}
+}
+
+func TestLadderForSource(t *testing.T) {
+	tests := []struct {
+		height int
+		want   []int
+	}{
+		{1080, []int{240, 480, 720, 1080}},
+		{2160, []int{240, 480, 720, 1080}},
+		{720, []int{240, 480, 720}},
+		{719, []int{240, 480}},
+		{240, []int{240}},
+		{180, []int{180}},
+		{179, []int{178}},
+		{1, []int{2}},
+	}
+	for _, tt := range tests {
+		var got []int
+		for _, r := range DefaultLadder.forSource(tt.height) {
+			got = append(got, r.Height)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("forSource(%d) = %v, want %v", tt.height, got, tt.want)
+		}
+	}
+}
+
+// argAfter returns the argument following flag, or "" if flag is absent.
+func argAfter(args []string, flag string) string {
+	i := slices.Index(args, flag)
+	if i < 0 || i+1 >= len(args) {
+		return ""
+	}
+	return args[i+1]
+}
+
+func TestDashArgs(t *testing.T) {
+	rungs := DefaultLadder.forSource(479)
+	args := dashArgs("in.mp4", "out/manifest.mpd", rungs, "128k", true, false)
+
+	if args[0] != "-i" || args[1] != "in.mp4" || args[len(args)-1] != "out/manifest.mpd" {
+		t.Fatalf("args = %v, want input first and manifest last", args)
+	}
+	joined := strings.Join(args, " ")
+	if got := strings.Count(joined, "-map 0:v:0"); got != len(rungs) {
+		t.Errorf("%d video maps for %d rungs", got, len(rungs))
+	}
+	for i, r := range rungs {
+		idx := []string{"0", "1"}[i]
+		if got := argAfter(args, "-filter:v:"+idx); got != "scale=-2:"+strings.TrimSuffix(r.Name, "p") {
+			t.Errorf("rung %d filter = %q", i, got)
+		}
+		if got := argAfter(args, "-b:v:"+idx); got != r.VideoBitrate {
+			t.Errorf("rung %d bitrate = %q, want %q", i, got, r.VideoBitrate)
+		}
+	}
+	if !strings.Contains(joined, "-map 0:a:0") || argAfter(args, "-b:a") != "128k" {
+		t.Errorf("audio is not mapped: %v", args)
+	}
+	if got := argAfter(args, "-adaptation_sets"); got != "id=0,streams=v id=1,streams=a" {
+		t.Errorf("adaptation sets = %q", got)
+	}
+	if slices.Contains(args, "-hls_playlist") {
+		t.Error("HLS playlists requested with hls off")
+	}
+
+	// An odd-sized source without audio, with HLS.
+	args = dashArgs("in.mp4", "manifest.mpd", DefaultLadder.forSource(201), "128k", false, true)
+	if got := argAfter(args, "-filter:v:0"); got != "scale=-2:200" {
+		t.Errorf("filter for a 201-line source = %q, want scale=-2:200", got)
+	}
+	if slices.Contains(args, "0:a:0") || slices.Contains(args, "-b:a") {
+		t.Errorf("audio mapped for a silent source: %v", args)
+	}
+	if got := argAfter(args, "-adaptation_sets"); got != "id=0,streams=v" {
+		t.Errorf("adaptation sets = %q", got)
+	}
+	if argAfter(args, "-hls_playlist") != "1" {
+		t.Errorf("HLS playlists not requested: %v", args)
+	}
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// listCursor marks the last video of a page: its position in the sort
+// order and its ID, which breaks ties. Key is backend specific.
+type listCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	Id   string `json:"i"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses opts.Cursor, returning nil if there is none.
+func decodeCursor(opts ListOptions) (*listCursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != opts.Sort || c.Id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// normalizeListOptions fills in the default sort order and rejects unknown
+// ones.
+func normalizeListOptions(opts ListOptions) (ListOptions, error) {
+	switch opts.Sort {
+	case "":
+		opts.Sort = SortNewest
+	case SortNewest, SortOldest, SortTitle:
+	default:
+		return opts, fmt.Errorf("unknown sort order %q", opts.Sort)
+	}
+	if opts.Limit < 0 {
+		opts.Limit = 0
+	}
+	return opts, nil
+}
+
+// listOptionsFromQuery reads ListOptions from the q, match, sort, cursor
+// and limit query parameters. match=prefix selects a prefix search.
+func listOptionsFromQuery(query url.Values) (ListOptions, error) {
+	opts := ListOptions{
+		Sort:         query.Get("sort"),
+		Search:       query.Get("q"),
+		SearchPrefix: query.Get("match") == "prefix",
+		Cursor:       query.Get("cursor"),
+		Limit:        defaultPageSize,
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("invalid limit %q", raw)
+		}
+		opts.Limit = min(limit, maxPageSize)
+	}
+	return normalizeListOptions(opts)
+}
+
+// pageQuery builds the query string for another page of the same listing.
+func pageQuery(opts ListOptions, cursor string) string {
+	query := url.Values{}
+	if opts.Search != "" {
+		query.Set("q", opts.Search)
+		if opts.SearchPrefix {
+			query.Set("match", "prefix")
+		}
+	}
+	if opts.Sort != SortNewest {
+		query.Set("sort", opts.Sort)
+	}
+	if opts.Limit != defaultPageSize {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	return query.Encode()
+}
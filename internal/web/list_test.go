@@ -0,0 +1,103 @@
+package web
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tritontube/internal/videoid"
+)
+
+// testPagination stores videos with tied titles and upload times in svc
+// and checks that following NextCursor at several page sizes returns the
+// same videos, in the same order, as one unpaginated List.
+func testPagination(t *testing.T, svc VideoMetadataService) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	titles := []string{"Beta", "alpha", "Alpha", "gamma", "beta", "Delta", "alpha"}
+	for i, title := range titles {
+		meta := &VideoMetadata{
+			Id:         videoid.New(),
+			Title:      title,
+			UploadedAt: base.Add(time.Duration(i/2) * time.Hour),
+			Status:     VideoReady,
+		}
+		if err := svc.Create(meta); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		t.Cleanup(func() { svc.Delete(meta.Id) })
+	}
+
+	for _, tt := range []struct {
+		opts  ListOptions
+		count int
+	}{
+		{ListOptions{Sort: SortNewest}, 7},
+		{ListOptions{Sort: SortOldest}, 7},
+		{ListOptions{Sort: SortTitle}, 7},
+		{ListOptions{Sort: SortTitle, Search: "alp", SearchPrefix: true}, 3},
+		{ListOptions{Sort: SortNewest, Search: "ta"}, 3},
+	} {
+		opts := tt.opts
+		all, err := svc.List(opts)
+		if err != nil {
+			t.Fatalf("List(%+v): %v", opts, err)
+		}
+		if all.NextCursor != "" || len(all.Videos) != tt.count {
+			t.Fatalf("List(%+v) without a limit = %d videos, cursor %q; want %d and none",
+				opts, len(all.Videos), all.NextCursor, tt.count)
+		}
+		want := videoIDs(all.Videos)
+
+		for _, limit := range []int{1, 2, 3} {
+			paged := opts
+			paged.Limit = limit
+			var got []string
+			for pages := 0; ; pages++ {
+				if pages > len(titles) {
+					t.Fatalf("List(%+v) did not reach the last page", paged)
+				}
+				page, err := svc.List(paged)
+				if err != nil {
+					t.Fatalf("List(%+v): %v", paged, err)
+				}
+				if len(page.Videos) > limit {
+					t.Fatalf("List(%+v) returned %d videos", paged, len(page.Videos))
+				}
+				got = append(got, videoIDs(page.Videos)...)
+				if page.NextCursor == "" {
+					break
+				}
+				paged.Cursor = page.NextCursor
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("%s search %q limit %d: pages gave %v, want %v", opts.Sort, opts.Search, limit, got, want)
+			}
+		}
+	}
+
+	page, err := svc.List(ListOptions{Sort: SortNewest, Limit: 2})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	_, err = svc.List(ListOptions{Sort: SortTitle, Limit: 2, Cursor: page.NextCursor})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("newest cursor used for title sort = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func videoIDs(videos []VideoMetadata) []string {
+	var ids []string
+	for _, v := range videos {
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
+
+func TestSQLitePagination(t *testing.T) {
+	testPagination(t, newTestSQLite(t))
+}
+
+func TestEtcdPagination(t *testing.T) {
+	testPagination(t, newTestEtcd(t))
+}
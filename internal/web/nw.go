@@ -4,66 +4,156 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"log"
+	"maps"
 	"net"
-	"sort"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"tritontube/internal/contentkey"
 	"tritontube/internal/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type NetworkVideoContentService struct {
 	proto.UnimplementedVideoContentAdminServiceServer
 
-	nodes       map[string]proto.StorageClient
-	nodesHashes []uint64
-	hashToNode  map[uint64]string
-	mu          sync.RWMutex
+	// nodes holds a client for every node on either ring, over the
+	// connection in conns, which the health tracker shares.
+	nodes    map[string]proto.StorageClient
+	conns    map[string]*grpc.ClientConn
+	health   *healthTracker
+	ring     *hashRing
+	replicas int
+	mu       sync.RWMutex
+
+	// next is the ring files are moving to during a rebalance, nil
+	// otherwise, and nextOp the operation moving them. While next is
+	// set, writes go to its owners and reads try them before the owners
+	// in ring. rebalancing allows one membership change at a time.
+	next        *hashRing
+	nextOp      string
+	rebalancing sync.Mutex
+	ops         OperationStore
+
+	// store persists ring membership; version is that of the ring in
+	// use. Without a store membership changes last until restart. If
+	// the store is shared, leader elects the one server that changes it.
+	store   RingStore
+	version int64
+	leader  MigrationLeader
+
+	// deleteVideo removes a video's metadata and content for the admin
+	// DeleteVideo RPC. Without it only content is deleted.
+	deleteVideo func(videoId string) error
+
+	// pendingDeletes holds, per node, videos a Delete could not remove
+	// there. They are retried every deleteRetryInterval and before a
+	// rebalance lists files. deletes, if set, persists them so they
+	// survive a restart.
+	deletesMu      sync.Mutex
+	pendingDeletes map[string]map[string]bool
+	deletes        PendingDeleteStore
 }
 
+// deleteRetryInterval is how often deletes unconfirmed by a node are
+// retried.
+const deleteRetryInterval = 30 * time.Second
+
 var _ VideoContentService = (*NetworkVideoContentService)(nil)
+var _ ContentPinner = (*NetworkVideoContentService)(nil)
 
 func hashStringToUint64(s string) uint64 {
 	sum := sha256.Sum256([]byte(s))
 	return binary.BigEndian.Uint64(sum[:8])
 }
 
-func NewNetworkVideoContentService(addresses []string, adminHostPort string) (*NetworkVideoContentService, error) {
+func dialStorage(addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial(
+		addr,
+		grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(100*1024*1024),
+			grpc.MaxCallSendMsgSize(100*1024*1024),
+		),
+	)
+}
+
+// NewNetworkVideoContentService connects to the given storage nodes and
+// stores every file on replicas successive distinct nodes of the ring.
+// Each node spec is "addr" or "addr=weight"; a node gets vnodes*weight
+// tokens on the ring. If store holds a ring it takes precedence over the
+// node specs, which only seed a new cluster.
+func NewNetworkVideoContentService(nodeSpecs []string, adminHostPort string, replicas, vnodes int, store RingStore) (*NetworkVideoContentService, error) {
+	if replicas < 1 {
+		return nil, fmt.Errorf("replication factor must be at least 1")
+	}
+	if vnodes < 1 {
+		return nil, fmt.Errorf("virtual node count must be at least 1")
+	}
+
 	n := &NetworkVideoContentService{
-		nodes:      make(map[string]proto.StorageClient),
-		hashToNode: make(map[uint64]string),
-	}
-
-	for _, addr := range addresses {
-		conn, err := grpc.Dial(
-			addr,
-			grpc.WithInsecure(),
-			grpc.WithDefaultCallOptions(
-				grpc.MaxCallRecvMsgSize(100*1024*1024),
-				grpc.MaxCallSendMsgSize(100*1024*1024),
-			),
-		)
+		nodes:    make(map[string]proto.StorageClient),
+		conns:    make(map[string]*grpc.ClientConn),
+		health:   newHealthTracker(),
+		replicas: replicas,
+		store:    store,
+		ops:      newMemoryOperations(),
+
+		pendingDeletes: make(map[string]map[string]bool),
+	}
+	if ops, ok := store.(OperationStore); ok {
+		n.ops = ops
+	}
+	n.deletes, _ = store.(PendingDeleteStore)
+	if err := n.loadPendingDeletes(); err != nil {
+		return nil, err
+	}
+
+	seed := make(map[string]int)
+	for _, spec := range nodeSpecs {
+		addr, weight, err := parseNodeSpec(spec)
 		if err != nil {
-			log.Printf("[ERROR] Failed to connect to %s: %v", addr, err)
 			return nil, err
 		}
-		client := proto.NewStorageClient(conn)
-		hash := hashStringToUint64(addr)
-		n.nodes[addr] = client
-		n.hashToNode[hash] = addr
-		n.nodesHashes = append(n.nodesHashes, hash)
+		seed[addr] = weight
+	}
+	state, err := loadRing(store, &RingState{Version: 1, VNodes: vnodes, Weights: seed})
+	if err != nil {
+		return nil, err
 	}
 
-	sort.Slice(n.nodesHashes, func(i, j int) bool {
-		return n.nodesHashes[i] < n.nodesHashes[j]
-	})
+	if err := n.setRing(state); err != nil {
+		return nil, err
+	}
 
-	log.Printf("[INIT] Initialized with %d nodes", len(n.nodesHashes))
+	log.Printf("[INIT] Initialized ring version %d with %d nodes (%d tokens), replication factor %d",
+		n.version, n.ring.size(), len(n.ring.hashes), replicas)
+
+	n.leader, _ = store.(MigrationLeader)
+	if watcher, ok := store.(RingWatcher); ok {
+		go watcher.WatchRing(context.Background(), n.applyRing)
+	}
+	if n.next != nil {
+		log.Printf("[INIT] Ring version %d has an unfinished rebalance to %v, resuming it", n.version, n.next.nodes())
+		go n.resumeInterrupted()
+	}
+	go func() {
+		ticker := time.NewTicker(deleteRetryInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n.retryDeletes()
+		}
+	}()
 
 	go func() {
 		listener, err := net.Listen("tcp", adminHostPort)
@@ -84,292 +174,1246 @@ func NewNetworkVideoContentService(addresses []string, adminHostPort string) (*N
 	return n, nil
 }
 
-func (n *NetworkVideoContentService) getClientForKey(key string) (proto.StorageClient, string) {
-	hash := hashStringToUint64(key)
+// readNodesForKey returns the nodes that may hold key, primary first.
+// During a rebalance that is the replica set in the new ring followed by
+// the rest of the old one, since a key is on one or the other until it
+// has been moved.
+func (n *NetworkVideoContentService) readNodesForKey(key string) []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	owners := n.ring.owners(key, n.replicas)
+	if n.next != nil {
+		candidates := n.next.owners(key, n.replicas)
+		for _, addr := range owners {
+			if !slices.Contains(candidates, addr) {
+				candidates = append(candidates, addr)
+			}
+		}
+		owners = candidates
+	}
+	log.Printf("[ROUTING] Key '%s' → Hash %d → Nodes %v", key, hashStringToUint64(key), owners)
+	return owners
+}
 
+// writeNodesForKey returns the replica set key is written to, primary
+// first, in the new ring during a rebalance.
+func (n *NetworkVideoContentService) writeNodesForKey(key string) []string {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
-	idx := sort.Search(len(n.nodesHashes), func(i int) bool {
-		return n.nodesHashes[i] >= hash
-	})
-	if idx == len(n.nodesHashes) {
-		idx = 0
+	ring := n.ring
+	if n.next != nil {
+		ring = n.next
+	}
+	owners := ring.owners(key, n.replicas)
+	log.Printf("[ROUTING] Key '%s' → Hash %d → Nodes %v", key, hashStringToUint64(key), owners)
+	return owners
+}
+
+// members returns every node of the ring and, during a rebalance, of the
+// ring being moved to, sorted. The caller must hold n.mu.
+func (n *NetworkVideoContentService) members() []string {
+	addrs := n.ring.nodes()
+	if n.next != nil {
+		for _, addr := range n.next.nodes() {
+			if !n.ring.contains(addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+		slices.Sort(addrs)
+	}
+	return addrs
+}
+
+// memberWeight returns addr's weight in the newest ring it is part of.
+// The caller must hold n.mu.
+func (n *NetworkVideoContentService) memberWeight(addr string) int {
+	if n.next != nil && n.next.contains(addr) {
+		return n.next.weights[addr]
 	}
+	return n.ring.weights[addr]
+}
+
+func (n *NetworkVideoContentService) getClient(addr string) proto.StorageClient {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.nodes[addr]
+}
+
+const uploadChunkSize = 1024 * 1024
 
-	nodeHash := n.nodesHashes[idx]
-	nodeAddr := n.hashToNode[nodeHash]
-	log.Printf("[ROUTING] Key '%s' → Hash %d → Node %s", key, hash, nodeAddr)
-	return n.nodes[nodeAddr], nodeAddr
+func (n *NetworkVideoContentService) Open(videoId, filename string) (io.ReadCloser, int64, error) {
+	return n.openRange(videoId, filename, 0, 0)
 }
 
-func (n *NetworkVideoContentService) Read(videoId, filename string) ([]byte, error) {
+func (n *NetworkVideoContentService) OpenRange(videoId, filename string, offset, length int64) (io.ReadCloser, error) {
+	r, _, err := n.openRange(videoId, filename, offset, length)
+	return r, err
+}
+
+func (n *NetworkVideoContentService) openRange(videoId, filename string, offset, length int64) (io.ReadCloser, int64, error) {
 	key := fmt.Sprintf("%s/%s", videoId, filename)
-	client, nodeAddr := n.getClientForKey(key)
 
-	log.Printf("[READ] %s from node %s", key, nodeAddr)
+	var lastErr error
+	for _, nodeAddr := range n.preferHealthy(n.readNodesForKey(key)) {
+		log.Printf("[READ] %s [%d+%d] from node %s", key, offset, length, nodeAddr)
+		r, size, err := openOnNode(n.getClient(nodeAddr), videoId, filename, offset, length)
+		if err == nil {
+			return r, size, nil
+		}
+		log.Printf("[ERROR] Read %s from node %s failed, trying next replica: %v", key, nodeAddr, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no storage nodes available")
+	}
+	return nil, 0, lastErr
+}
 
-	stream, err := client.Download(context.Background(), &proto.FileRequest{
+func (n *NetworkVideoContentService) Stat(videoId, filename string) (*ContentInfo, error) {
+	pinned, err := n.Pin(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	return pinned.Info(), nil
+}
+
+// Pin stats the replicas of a file in read order and returns the first
+// that has it. The error is NotFound only if every replica answered that
+// it lacks the file.
+func (n *NetworkVideoContentService) Pin(videoId, filename string) (PinnedContent, error) {
+	key := fmt.Sprintf("%s/%s", videoId, filename)
+
+	var lastErr error
+	for _, nodeAddr := range n.preferHealthy(n.readNodesForKey(key)) {
+		client := n.getClient(nodeAddr)
+		info, err := client.Stat(context.Background(), &proto.FileRequest{
+			VideoId:  videoId,
+			Filename: filename,
+		})
+		if err == nil {
+			return &pinnedReplica{
+				svc:      n,
+				addr:     nodeAddr,
+				client:   client,
+				videoId:  videoId,
+				filename: filename,
+				info: &ContentInfo{
+					Size:    info.Size,
+					ModTime: time.Unix(0, info.ModTimeUnixNano),
+					Sha256:  info.Sha256,
+				},
+			}, nil
+		}
+		log.Printf("[ERROR] Stat %s on node %s failed, trying next replica: %v", key, nodeAddr, err)
+		if lastErr == nil || status.Code(lastErr) == codes.NotFound {
+			lastErr = err
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no storage nodes available")
+	}
+	return nil, lastErr
+}
+
+// pinnedReplica reads a file from the one node whose Stat it holds. If
+// that node refuses the file as corrupt, reads move to another replica
+// with the same digest, which holds the same bytes, so headers already
+// sent from the pinned Stat stay true.
+type pinnedReplica struct {
+	svc      *NetworkVideoContentService
+	addr     string
+	client   proto.StorageClient
+	videoId  string
+	filename string
+	info     *ContentInfo
+}
+
+func (p *pinnedReplica) Info() *ContentInfo { return p.info }
+
+func (p *pinnedReplica) OpenRange(offset, length int64) (io.ReadCloser, error) {
+	r, _, err := openOnNode(p.client, p.videoId, p.filename, offset, length)
+	if status.Code(err) != codes.DataLoss || p.info.Sha256 == "" {
+		return r, err
+	}
+
+	key := fmt.Sprintf("%s/%s", p.videoId, p.filename)
+	log.Printf("[READ] Node %s refused corrupt %s, trying another replica", p.addr, key)
+	for _, addr := range p.svc.preferHealthy(p.svc.readNodesForKey(key)) {
+		if addr == p.addr {
+			continue
+		}
+		client := p.svc.getClient(addr)
+		info, statErr := client.Stat(context.Background(), &proto.FileRequest{VideoId: p.videoId, Filename: p.filename})
+		if statErr != nil || info.Sha256 != p.info.Sha256 {
+			continue
+		}
+		r, _, openErr := openOnNode(client, p.videoId, p.filename, offset, length)
+		if openErr != nil {
+			log.Printf("[ERROR] Read %s from node %s failed: %v", key, addr, openErr)
+			continue
+		}
+		p.addr, p.client = addr, client
+		return r, nil
+	}
+	return nil, err
+}
+
+// openOnNode starts a download and waits for the first chunk, so a missing
+// file or dead node is reported here rather than on the first Read. The
+// returned size is that of the whole file.
+func openOnNode(client proto.StorageClient, videoId, filename string, offset, length int64) (io.ReadCloser, int64, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Download(ctx, &proto.FileRequest{
 		VideoId:  videoId,
 		Filename: filename,
+		Offset:   offset,
+		Length:   length,
 	})
 	if err != nil {
-		log.Printf("[ERROR] Download start failed: %v", err)
-		return nil, err
+		cancel()
+		return nil, 0, err
 	}
 
-	var data []byte
-	for {
-		chunk, err := stream.Recv()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
+	first, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	r := &downloadReader{
+		key:    fmt.Sprintf("%s/%s", videoId, filename),
+		stream: stream,
+		buf:    first.Data,
+		cancel: cancel,
+	}
+	if offset == 0 && length == 0 && first.Sha256 != "" {
+		r.hasher = sha256.New()
+		r.expected = first.Sha256
+		r.hasher.Write(first.Data)
+	}
+	return r, first.Size, nil
+}
+
+// downloadReader adapts a Download stream to an io.ReadCloser. When the
+// whole file is read, its digest is checked against the one the node
+// reported and a mismatch is returned in place of io.EOF.
+type downloadReader struct {
+	key      string
+	stream   proto.Storage_DownloadClient
+	buf      []byte
+	cancel   context.CancelFunc
+	hasher   hash.Hash
+	expected string
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err == io.EOF && r.hasher != nil {
+			if actual := hex.EncodeToString(r.hasher.Sum(nil)); actual != r.expected {
+				log.Printf("[ERROR] Checksum mismatch reading %s: expected %s, got %s", r.key, r.expected, actual)
+				return 0, fmt.Errorf("checksum mismatch: expected %s, got %s", r.expected, actual)
 			}
-			log.Printf("[ERROR] Failed to receive chunk: %v", err)
-			return nil, err
 		}
-		data = append(data, chunk.Data...)
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Data
+		if r.hasher != nil {
+			r.hasher.Write(chunk.Data)
+		}
 	}
-	return data, nil
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
 }
 
-func (n *NetworkVideoContentService) Write(videoId, filename string, data []byte) error {
+func (r *downloadReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+func (n *NetworkVideoContentService) Create(videoId, filename string) (io.WriteCloser, error) {
 	if strings.HasSuffix(filename, ".mp4") {
 		log.Printf("[SKIP] Skipping storage of raw MP4 file: %s", filename)
-		return nil
+		return nopWriteCloser{io.Discard}, nil
 	}
 
 	key := fmt.Sprintf("%s/%s", videoId, filename)
-	client, nodeAddr := n.getClientForKey(key)
+	owners := n.writeNodesForKey(key)
+	if len(owners) == 0 {
+		return nil, fmt.Errorf("no storage nodes available")
+	}
+	// Every owner must take the write, so one that health checks mark
+	// down fails it now rather than after streaming to the others.
+	for _, nodeAddr := range owners {
+		if n.health.state(nodeAddr) == NodeDown {
+			log.Printf("[ERROR] Not writing %s: owner %s is down", key, nodeAddr)
+			return nil, status.Errorf(codes.Unavailable, "storage node %s, an owner of %s, is down", nodeAddr, key)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &replicatedWriter{videoId: videoId, filename: filename, cancel: cancel, hasher: sha256.New()}
+	for _, nodeAddr := range owners {
+		log.Printf("[WRITE] %s to node %s", key, nodeAddr)
+		stream, err := n.getClient(nodeAddr).Upload(ctx)
+		if err != nil {
+			log.Printf("[ERROR] Upload start to node %s failed: %v", nodeAddr, err)
+			cancel()
+			return nil, err
+		}
+		w.streams = append(w.streams, stream)
+		w.addrs = append(w.addrs, nodeAddr)
+	}
+	return w, nil
+}
 
-	log.Printf("[WRITE] %s to node %s", key, nodeAddr)
+type nopWriteCloser struct {
+	io.Writer
+}
 
-	stream, err := client.Upload(context.Background())
-	if err != nil {
-		log.Printf("[ERROR] Upload start failed: %v", err)
-		return err
+func (nopWriteCloser) Close() error { return nil }
+
+// replicatedWriter fans writes out to one Upload stream per replica. Any
+// replica failure, including a replica acknowledging a digest other than
+// that of the bytes sent, fails the whole write.
+type replicatedWriter struct {
+	videoId  string
+	filename string
+	streams  []proto.Storage_UploadClient
+	addrs    []string
+	cancel   context.CancelFunc
+	hasher   hash.Hash
+	sent     bool
+	err      error
+}
+
+func (w *replicatedWriter) send(data []byte) error {
+	for i, stream := range w.streams {
+		err := stream.Send(&proto.FileChunk{
+			VideoId:  w.videoId,
+			Filename: w.filename,
+			Data:     data,
+		})
+		if err != nil {
+			log.Printf("[ERROR] Upload chunk to node %s failed: %v", w.addrs[i], err)
+			w.err = err
+			w.cancel()
+			return err
+		}
+	}
+	w.hasher.Write(data)
+	w.sent = true
+	return nil
+}
+
+func (w *replicatedWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
 	}
+	for start := 0; start < len(p); start += uploadChunkSize {
+		end := start + uploadChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := w.send(p[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(p), nil
+}
 
-	const chunkSize = 1024 * 1024
-	for start := 0; start < len(data); start += chunkSize {
-		end := start + chunkSize
-		if end > len(data) {
-			end = len(data)
+func (w *replicatedWriter) Close() error {
+	defer w.cancel()
+	if w.err != nil {
+		return w.err
+	}
+	// An empty file still needs one chunk to name it.
+	if !w.sent {
+		if err := w.send(nil); err != nil {
+			return err
 		}
-		err = stream.Send(&proto.FileChunk{
-			VideoId:  videoId,
-			Filename: filename,
-			Data:     data[start:end],
-		})
+	}
+	digest := hex.EncodeToString(w.hasher.Sum(nil))
+	for i, stream := range w.streams {
+		ack, err := stream.CloseAndRecv()
 		if err != nil {
-			log.Printf("[ERROR] Upload chunk failed: %v", err)
+			log.Printf("[ERROR] Upload finalize on node %s failed: %v", w.addrs[i], err)
+			return err
+		}
+		if !ack.Success {
+			return fmt.Errorf("upload ack failed for %s/%s on %s", w.videoId, w.filename, w.addrs[i])
+		}
+		if ack.Sha256 != digest {
+			log.Printf("[ERROR] Checksum mismatch writing %s/%s to node %s: sent %s, stored %s",
+				w.videoId, w.filename, w.addrs[i], digest, ack.Sha256)
+			return fmt.Errorf("checksum mismatch on %s for %s/%s", w.addrs[i], w.videoId, w.filename)
+		}
+	}
+	return nil
+}
+
+// Delete removes every file of videoId from every node on the ring, and
+// on the ring being moved to during a rebalance. Nodes that fail do not
+// fail the call; their deletes are retried until they succeed. It only
+// fails if such a retry cannot be recorded, so the caller keeps the video
+// rather than orphan its files.
+func (n *NetworkVideoContentService) Delete(videoId string) error {
+	n.mu.RLock()
+	addrs := n.members()
+	n.mu.RUnlock()
+
+	var failed []string
+	for _, addr := range addrs {
+		if err := deleteOnNode(n.getClient(addr), videoId); err != nil {
+			log.Printf("[DELETE] Failed to delete %s on node %s, will retry: %v", videoId, addr, err)
+			if err := n.deferDelete(addr, videoId); err != nil {
+				log.Printf("[DELETE] Failed to record pending delete of %s on node %s: %v", videoId, addr, err)
+				failed = append(failed, addr)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not delete from or record a retry for nodes %v", failed)
+	}
+	return nil
+}
+
+// deferDelete records that videoId could not be deleted from addr, so
+// retryDeletes removes it once the node is reachable again.
+func (n *NetworkVideoContentService) deferDelete(addr, videoId string) error {
+	if n.deletes != nil {
+		if err := n.deletes.AddPendingDelete(PendingDelete{Node: addr, VideoId: videoId}); err != nil {
 			return err
 		}
 	}
+	n.keepPending(addr, videoId)
+	return nil
+}
+
+// keepPending marks videoId for retry on addr in memory only.
+func (n *NetworkVideoContentService) keepPending(addr, videoId string) {
+	n.deletesMu.Lock()
+	defer n.deletesMu.Unlock()
+	if n.pendingDeletes[addr] == nil {
+		n.pendingDeletes[addr] = make(map[string]bool)
+	}
+	n.pendingDeletes[addr][videoId] = true
+}
+
+// forgetDelete drops a pending delete from the store once it is done or
+// its node has left the ring.
+func (n *NetworkVideoContentService) forgetDelete(addr, videoId string) {
+	if n.deletes == nil {
+		return
+	}
+	if err := n.deletes.RemovePendingDelete(PendingDelete{Node: addr, VideoId: videoId}); err != nil {
+		log.Printf("[DELETE] Failed to clear pending delete of %s on node %s: %v", videoId, addr, err)
+	}
+}
 
-	_, err = stream.CloseAndRecv()
+// loadPendingDeletes reads the deletes a previous run left unfinished.
+func (n *NetworkVideoContentService) loadPendingDeletes() error {
+	if n.deletes == nil {
+		return nil
+	}
+	deletes, err := n.deletes.ListPendingDeletes()
 	if err != nil {
-		log.Printf("[ERROR] Upload finalize failed: %v", err)
+		return fmt.Errorf("failed to load pending deletes: %v", err)
 	}
-	return err
+	for _, d := range deletes {
+		n.keepPending(d.Node, d.VideoId)
+	}
+	if len(deletes) > 0 {
+		log.Printf("[INIT] Loaded %d pending deletes", len(deletes))
+	}
+	return nil
 }
 
-func (svc *NetworkVideoContentService) AddNode(ctx context.Context, req *proto.AddNodeRequest) (*proto.AddNodeResponse, error) {
+// retryDeletes retries deferred deletes on nodes that are still members.
+// Deletes for nodes that left the ring are dropped with them.
+func (n *NetworkVideoContentService) retryDeletes() {
+	n.deletesMu.Lock()
+	pending := n.pendingDeletes
+	n.pendingDeletes = make(map[string]map[string]bool)
+	n.deletesMu.Unlock()
+
+	for addr, videoIds := range pending {
+		client := n.getClient(addr)
+		for videoId := range videoIds {
+			if client == nil {
+				n.forgetDelete(addr, videoId)
+				continue
+			}
+			if n.health.state(addr) == NodeDown {
+				n.keepPending(addr, videoId)
+				continue
+			}
+			if err := deleteOnNode(client, videoId); err != nil {
+				n.keepPending(addr, videoId)
+				continue
+			}
+			n.forgetDelete(addr, videoId)
+			log.Printf("[DELETE] Deleted %s on node %s after retry", videoId, addr)
+		}
+	}
+}
+
+func deleteOnNode(client proto.StorageClient, videoId string) error {
+	ctx := context.Background()
+	filesResp, err := client.ListVideoFiles(ctx, &proto.ListVideoFilesRequest{
+		VideoId: videoId,
+	})
+	if err != nil {
+		return err
+	}
+	if len(filesResp.Filenames) == 0 {
+		return nil
+	}
+
+	resp, err := client.DeleteFiles(ctx, &proto.BatchDeleteRequest{
+		VideoId:   videoId,
+		Filenames: filesResp.Filenames,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("some files were not deleted")
+	}
+	return nil
+}
+
+// SetVideoDeleter installs the function the admin DeleteVideo RPC uses, so
+// metadata is removed along with content.
+func (svc *NetworkVideoContentService) SetVideoDeleter(fn func(videoId string) error) {
 	svc.mu.Lock()
 	defer svc.mu.Unlock()
+	svc.deleteVideo = fn
+}
 
-	newAddr := req.NodeAddress
-	newHash := hashStringToUint64(newAddr)
+func (svc *NetworkVideoContentService) DeleteVideo(ctx context.Context, req *proto.DeleteVideoRequest) (*proto.DeleteVideoResponse, error) {
+	svc.mu.RLock()
+	deleteVideo := svc.deleteVideo
+	svc.mu.RUnlock()
 
-	if _, exists := svc.nodes[newAddr]; exists {
-		log.Printf("[AddNode] Node %s already exists", newAddr)
-		return nil, fmt.Errorf("node already exists")
+	if err := contentkey.CheckVideoID(req.VideoId); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if deleteVideo == nil {
+		deleteVideo = svc.Delete
+	}
+	if err := deleteVideo(req.VideoId); err != nil {
+		log.Printf("[DeleteVideo] Failed to delete %s: %v", req.VideoId, err)
+		return nil, err
 	}
 
-	conn, err := grpc.Dial(newAddr,
-		grpc.WithInsecure(),
-		grpc.WithDefaultCallOptions(
-			grpc.MaxCallRecvMsgSize(1024*1024*100),
-			grpc.MaxCallSendMsgSize(1024*1024*100),
-		),
-	)
+	log.Printf("[DeleteVideo] Deleted %s", req.VideoId)
+	return &proto.DeleteVideoResponse{}, nil
+}
+
+// Scrub collects scrub status from every node and, if asked, overwrites
+// each corrupt or unverified file with a copy from another replica. The
+// copy is checksum verified, so a replica that is itself corrupt or has no
+// checksum is skipped.
+func (svc *NetworkVideoContentService) Scrub(ctx context.Context, req *proto.ScrubClusterRequest) (*proto.ScrubClusterResponse, error) {
+	svc.mu.RLock()
+	addrs := svc.members()
+	svc.mu.RUnlock()
+
+	resp := &proto.ScrubClusterResponse{}
+	for _, addr := range addrs {
+		report := &proto.NodeScrubReport{NodeAddress: addr}
+		resp.Nodes = append(resp.Nodes, report)
+
+		scrubStatus, err := svc.getClient(addr).Scrub(ctx, &proto.ScrubRequest{Start: req.Start})
+		if err != nil {
+			log.Printf("[Scrub] Failed to get scrub status from %s: %v", addr, err)
+			report.Error = err.Error()
+			continue
+		}
+		report.Running = scrubStatus.Running
+		report.FilesScanned = scrubStatus.FilesScanned
+		report.FilesTotal = scrubStatus.FilesTotal
+		report.LastCompletedUnix = scrubStatus.LastCompletedUnix
+
+		for _, c := range scrubStatus.Corrupt {
+			finding := &proto.ScrubFinding{
+				VideoId:        c.VideoId,
+				Filename:       c.Filename,
+				ExpectedSha256: c.ExpectedSha256,
+				ActualSha256:   c.ActualSha256,
+			}
+			if req.Repair {
+				if err := svc.repairFile(c.VideoId, c.Filename, addr); err != nil {
+					log.Printf("[Scrub] Failed to repair %s/%s on %s: %v", c.VideoId, c.Filename, addr, err)
+					finding.Repair = err.Error()
+				} else {
+					log.Printf("[Scrub] Repaired %s/%s on %s", c.VideoId, c.Filename, addr)
+					finding.Repair = "repaired"
+					resp.RepairedCount++
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	return resp, nil
+}
+
+func (svc *NetworkVideoContentService) repairFile(videoId, filename, badAddr string) error {
+	key := fmt.Sprintf("%s/%s", videoId, filename)
+	target := svc.getClient(badAddr)
+	if target == nil {
+		return fmt.Errorf("node %s is not in the ring", badAddr)
+	}
+
+	for _, addr := range svc.readNodesForKey(key) {
+		if addr == badAddr {
+			continue
+		}
+		// A replica without a checksum cannot vouch for its bytes.
+		info, err := svc.getClient(addr).Stat(context.Background(), &proto.FileRequest{VideoId: videoId, Filename: filename})
+		if err != nil || info.Sha256 == "" {
+			log.Printf("[Scrub] Replica %s cannot repair %s: no verified copy", addr, key)
+			continue
+		}
+		if _, err := migrateFileSync(context.Background(), videoId, filename, svc.getClient(addr), target); err != nil {
+			log.Printf("[Scrub] Replica %s cannot repair %s: %v", addr, key, err)
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("no healthy replica")
+}
+
+// AddNode starts a rebalance operation adding the node and returns its
+// ID without waiting for files to move.
+func (svc *NetworkVideoContentService) AddNode(ctx context.Context, req *proto.AddNodeRequest) (*proto.AddNodeResponse, error) {
+	newAddr := req.NodeAddress
+	weight := int(req.Weight)
+	if weight == 0 {
+		weight = 1
+	}
+	if weight < 0 {
+		return nil, fmt.Errorf("weight must be positive")
+	}
+
+	op, err := svc.startOperation("add", newAddr, weight, func(ring *hashRing) (*hashRing, error) {
+		if ring.contains(newAddr) {
+			log.Printf("[AddNode] Node %s already exists", newAddr)
+			return nil, fmt.Errorf("node already exists")
+		}
+		return ring.add(newAddr, weight), nil
+	})
 	if err != nil {
-		log.Printf("[AddNode] Failed to connect to %s: %v", newAddr, err)
-		return nil, fmt.Errorf("failed to connect to new node: %v", err)
+		return nil, err
 	}
-	client := proto.NewStorageClient(conn)
+	return &proto.AddNodeResponse{OperationId: op.Id}, nil
+}
+
+// RemoveNode starts a rebalance operation removing the node and returns
+// its ID without waiting for files to move.
+func (svc *NetworkVideoContentService) RemoveNode(ctx context.Context, req *proto.RemoveNodeRequest) (*proto.RemoveNodeResponse, error) {
+	removeAddr := req.NodeAddress
 
-	svc.nodes[newAddr] = client
-	svc.hashToNode[newHash] = newAddr
-	svc.nodesHashes = append(svc.nodesHashes, newHash)
-	sort.Slice(svc.nodesHashes, func(i, j int) bool {
-		return svc.nodesHashes[i] < svc.nodesHashes[j]
+	op, err := svc.startOperation("remove", removeAddr, 0, func(ring *hashRing) (*hashRing, error) {
+		if ring.size() == 1 {
+			return nil, fmt.Errorf("system needs to have atleast one")
+		}
+		if !ring.contains(removeAddr) {
+			return nil, fmt.Errorf("node does not exist")
+		}
+		return ring.remove(removeAddr), nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &proto.RemoveNodeResponse{OperationId: op.Id}, nil
+}
 
-	var newIndex int
-	for i, h := range svc.nodesHashes {
-		if h == newHash {
-			newIndex = i
-			break
+// changeMembership moves the cluster to the ring change derives from the
+// current one, recording progress in op. The new ring is saved as the
+// transitional ring before any file moves, so every server writes to its
+// owners and reads from both rings meanwhile; svc.mu is only held to
+// start and finish the transition, never while files are copied. A
+// rebalance left unfinished by a crash is completed first. If any file
+// cannot be moved, or leadership is lost, the transitional ring stays in
+// use, so reads still cover both rings, for a later run to finish.
+// Repeating the operation that failed is such a run: it moves the files
+// left behind under the new operation.
+func (svc *NetworkVideoContentService) changeMembership(op *RebalanceOperation, change func(ring *hashRing) (*hashRing, error)) error {
+	leading, release, err := svc.leadMigrations(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	svc.mu.Lock()
+	if err := svc.syncRing(); err != nil {
+		svc.mu.Unlock()
+		return err
+	}
+	oldRing, pending, version := svc.ring, svc.next, svc.version
+	svc.mu.Unlock()
+	if pending != nil {
+		if retry, err := change(oldRing); err == nil && maps.Equal(retry.weights, pending.weights) {
+			log.Printf("[REBALANCE] Operation %s retrying the move to %v", op.Id, pending.nodes())
+			op.RingVersion = version
+			if err := svc.rebalance(leading, oldRing, pending, op); err != nil {
+				return err
+			}
+			return svc.finishRebalance()
 		}
 	}
 
-	predIndex := newIndex - 1
-	if predIndex < 0 {
-		predIndex = len(svc.nodesHashes) - 1
+	if err := svc.finishInterrupted(leading); err != nil {
+		return err
 	}
-	predHash := svc.nodesHashes[predIndex]
-	predAddr := svc.hashToNode[predHash]
 
-	succIndex := (newIndex + 1) % len(svc.nodesHashes)
-	succHash := svc.nodesHashes[succIndex]
-	succAddr := svc.hashToNode[succHash]
-	succClient := svc.nodes[succAddr]
+	svc.mu.Lock()
+	if err := svc.syncRing(); err != nil {
+		svc.mu.Unlock()
+		return err
+	}
+	oldRing = svc.ring
+	newRing, err := change(oldRing)
+	if err == nil {
+		err = svc.startRebalance(newRing, op)
+	}
+	svc.mu.Unlock()
+	if err != nil {
+		return err
+	}
 
-	log.Printf("[AddNode] New node %s (hash: %d), predecessor: %s (hash: %d), successor: %s (hash: %d)",
-		newAddr, newHash, predAddr, predHash, succAddr, succHash)
+	log.Printf("[REBALANCE] Operation %s moving from %d to %d nodes", op.Id, oldRing.size(), newRing.size())
+	if err := svc.rebalance(leading, oldRing, newRing, op); err != nil {
+		return err
+	}
+	return svc.finishRebalance()
+}
 
-	if len(svc.nodesHashes) == 1 || succAddr == newAddr {
-		log.Printf("[AddNode] Skipping migration - single node configuration")
-		return &proto.AddNodeResponse{MigratedFileCount: 0}, nil
+// finishInterrupted completes a rebalance whose transitional ring was
+// saved but never finished, because its server crashed, continuing the
+// operation that started it. ctx is that of the migration leadership.
+func (svc *NetworkVideoContentService) finishInterrupted(ctx context.Context) error {
+	svc.mu.Lock()
+	if err := svc.syncRing(); err != nil {
+		svc.mu.Unlock()
+		return err
+	}
+	oldRing, newRing, opId, version := svc.ring, svc.next, svc.nextOp, svc.version
+	svc.mu.Unlock()
+	if newRing == nil {
+		return nil
 	}
 
-	var migratedCount int32
-	videosResp, err := succClient.ListVideos(ctx, &proto.ListVideosRequest{})
-	if err != nil {
-		log.Printf("[AddNode] ListVideos failed from %s: %v", succAddr, err)
-		return nil, fmt.Errorf("list videos failed: %v", err)
+	op := svc.interruptedOperation(opId, version)
+	log.Printf("[REBALANCE] Resuming operation %s to %v", op.Id, newRing.nodes())
+	err := svc.rebalance(ctx, oldRing, newRing, op)
+	if err == nil {
+		err = svc.finishRebalance()
 	}
+	svc.completeOperation(op, err)
+	return err
+}
 
-	log.Printf("[AddNode] Found %d video IDs on successor %s", len(videosResp.VideoIds), succAddr)
-	for _, vid := range videosResp.VideoIds {
-		filesResp, err := succClient.ListVideoFiles(ctx, &proto.ListVideoFilesRequest{
-			VideoId: vid,
-		})
-		if err != nil {
-			log.Printf("[AddNode] Error listing files for %s: %v", vid, err)
-			continue
+// startRebalance connects to every node of newRing and saves it as the
+// transitional ring of op. The caller must hold svc.mu.
+func (svc *NetworkVideoContentService) startRebalance(newRing *hashRing, op *RebalanceOperation) error {
+	for _, addr := range newRing.nodes() {
+		if err := svc.connect(addr); err != nil {
+			log.Printf("[REBALANCE] Failed to connect to %s: %v", addr, err)
+			return fmt.Errorf("failed to connect to new node: %v", err)
 		}
+	}
+	if err := svc.saveRing(svc.ring, newRing, op.Id); err != nil {
+		return fmt.Errorf("failed to persist the ring: %v", err)
+	}
+	svc.next, svc.nextOp = newRing, op.Id
+	op.RingVersion = svc.version
+	svc.saveOperation(op)
+	return nil
+}
 
-		var delFilenames []string
+// finishRebalance swaps in the transitional ring once its files are in
+// place and disconnects from nodes that left. The ring is saved first: if
+// that fails the transitional ring stays in use, here and in the store,
+// so no server drops a node that other servers still read from.
+func (svc *NetworkVideoContentService) finishRebalance() error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
 
-		log.Printf("[AddNode] Checking %d files for video %s", len(filesResp.Filenames), vid)
-		for _, fname := range filesResp.Filenames {
-			key := fmt.Sprintf("%s/%s", vid, fname)
-			keyHash := hashStringToUint64(key)
+	if err := svc.saveRing(svc.next, nil, ""); err != nil {
+		return fmt.Errorf("rebalanced but failed to persist the ring: %v", err)
+	}
+	svc.ring, svc.next, svc.nextOp = svc.next, nil, ""
+	svc.dropNonMembers()
+	return nil
+}
 
-			inRange := inRangeExclusive(predHash, newHash, keyHash)
-			log.Printf("[AddNode] Key '%s' → Hash %d → InRange: %v", key, keyHash, inRange)
+// connect dials addr unless there is a client for it already. The caller
+// must hold svc.mu.
+func (svc *NetworkVideoContentService) connect(addr string) error {
+	if _, ok := svc.nodes[addr]; ok {
+		return nil
+	}
+	conn, err := dialStorage(addr)
+	if err != nil {
+		return err
+	}
+	svc.nodes[addr] = proto.NewStorageClient(conn)
+	svc.conns[addr] = conn
+	svc.health.track(addr, conn)
+	return nil
+}
 
-			if inRange {
-				err := migrateFileSync(vid, fname, succClient, client)
-				if err != nil {
-					log.Printf("[AddNode] Failed to migrate source file %s/%s: %v", vid, fname, err)
-				} else {
-					delFilenames = append(delFilenames, fname)
-					migratedCount++
+// dropNonMembers stops probing nodes on neither ring and closes their
+// connections. The caller must hold svc.mu.
+func (svc *NetworkVideoContentService) dropNonMembers() {
+	for addr := range svc.nodes {
+		if !svc.ring.contains(addr) && (svc.next == nil || !svc.next.contains(addr)) {
+			delete(svc.nodes, addr)
+			svc.health.untrack(addr)
+			if conn := svc.conns[addr]; conn != nil {
+				if err := conn.Close(); err != nil {
+					log.Printf("[RING] Failed to close connection to %s: %v", addr, err)
 				}
+				delete(svc.conns, addr)
 			}
 		}
+	}
+}
 
-		if len(delFilenames) > 0 {
-			_, err := succClient.DeleteFiles(ctx, &proto.BatchDeleteRequest{
-				VideoId:   vid,
-				Filenames: delFilenames,
-			})
-			if err != nil {
-				log.Printf("[AddNode] Failed to delete source files for Video ID %s: %v", vid, err)
-			}
+func (svc *NetworkVideoContentService) ListNodes(ctx context.Context, req *proto.ListNodesRequest) (*proto.ListNodesResponse, error) {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	sortedNodes := svc.members()
+	resp := &proto.ListNodesResponse{
+		Nodes:       sortedNodes,
+		RingVersion: svc.version,
+		Rebalancing: svc.next != nil,
+		OperationId: svc.nextOp,
+	}
+	for _, addr := range sortedNodes {
+		resp.Statuses = append(resp.Statuses, svc.nodeStatus(addr, svc.memberWeight(addr)))
+	}
+
+	log.Printf("[ListNodes] Returning %d nodes: %v", len(sortedNodes), sortedNodes)
+	return resp, nil
+}
+
+// ClusterInfo reports the current ring membership, including nodes
+// joining or leaving in a rebalance.
+func (svc *NetworkVideoContentService) ClusterInfo() ClusterInfo {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	info := ClusterInfo{
+		Backend:     "nw",
+		Replicas:    svc.replicas,
+		VNodes:      svc.ring.vnodes,
+		RingVersion: svc.version,
+		Rebalancing: svc.next != nil,
+		Operation:   svc.nextOp,
+	}
+	for _, addr := range svc.members() {
+		info.Nodes = append(info.Nodes, ClusterNode{
+			Address: addr,
+			Weight:  svc.memberWeight(addr),
+			State:   svc.health.state(addr),
+		})
+	}
+	return info
+}
+
+// loadRing returns the ring stored in store, reconciled with the seed
+// from the command line, or saves and returns the seed if none is stored.
+// The stored ring wins: files were migrated according to it, so seed
+// nodes it lacks must be added with the admin CLI rather than assumed.
+func loadRing(store RingStore, seed *RingState) (*RingState, error) {
+	if store == nil {
+		return seed, nil
+	}
+	stored, err := store.LoadRing()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ring: %v", err)
+	}
+	if stored == nil {
+		if err := store.SaveRing(seed); err != nil {
+			return nil, fmt.Errorf("failed to save initial ring: %v", err)
 		}
+		log.Printf("[RING] No stored ring, saved the %d seed nodes as version 1", len(seed.Weights))
+		return seed, nil
 	}
 
-	log.Printf("[AddNode] Finished adding %s. Migrated %d files from %s", newAddr, migratedCount, succAddr)
-	return &proto.AddNodeResponse{MigratedFileCount: migratedCount}, nil
+	if stored.VNodes != seed.VNodes {
+		log.Printf("[RING] Using stored vnodes %d instead of -vnodes %d", stored.VNodes, seed.VNodes)
+	}
+	for addr, weight := range seed.Weights {
+		storedWeight, ok := stored.Weights[addr]
+		if !ok {
+			log.Printf("[RING] Ignoring seed node %s, not in stored ring version %d; add it with the admin CLI", addr, stored.Version)
+		} else if storedWeight != weight {
+			log.Printf("[RING] Using stored weight %d for %s instead of %d", storedWeight, addr, weight)
+		}
+	}
+	for addr := range stored.Weights {
+		if _, ok := seed.Weights[addr]; !ok {
+			log.Printf("[RING] Using stored node %s missing from the seed list", addr)
+		}
+	}
+	log.Printf("[RING] Loaded ring version %d with %d nodes", stored.Version, len(stored.Weights))
+	return stored, nil
 }
 
-func (svc *NetworkVideoContentService) RemoveNode(ctx context.Context, req *proto.RemoveNodeRequest) (*proto.RemoveNodeResponse, error) {
+// saveRing persists ring, and next as its transitional ring if not nil,
+// as the next version. It does not change the rings in use, so callers
+// only switch to them once they are saved. The caller must hold svc.mu.
+func (svc *NetworkVideoContentService) saveRing(ring, next *hashRing, opId string) error {
+	if svc.store == nil {
+		svc.version++
+		return nil
+	}
+	state := &RingState{Version: svc.version + 1, VNodes: ring.vnodes, Weights: ring.weights}
+	if next != nil {
+		state.Next = next.weights
+		state.Operation = opId
+	}
+	if err := svc.store.SaveRing(state); err != nil {
+		log.Printf("[RING] Failed to save ring version %d: %v", state.Version, err)
+		return err
+	}
+	svc.version = state.Version
+	if state.Next != nil {
+		log.Printf("[RING] Saved ring version %d with %d nodes, rebalancing to %d", state.Version, len(state.Weights), len(state.Next))
+	} else {
+		log.Printf("[RING] Saved ring version %d with %d nodes", state.Version, len(state.Weights))
+	}
+	return nil
+}
+
+// leadMigrations makes this server the only one changing membership,
+// if the store is shared between servers. The returned context is done
+// once leadership is lost.
+func (svc *NetworkVideoContentService) leadMigrations(ctx context.Context) (context.Context, func(), error) {
+	if svc.leader == nil {
+		return ctx, func() {}, nil
+	}
+	return svc.leader.LeadMigrations(ctx)
+}
+
+// applyRing switches to a ring saved by another server, if it is newer
+// than the one in use.
+func (svc *NetworkVideoContentService) applyRing(state *RingState) {
 	svc.mu.Lock()
 	defer svc.mu.Unlock()
 
-	numNodes := len(svc.nodesHashes)
-	if numNodes == 1 {
-		return nil, fmt.Errorf("system needs to have atleast one")
+	prev := svc.version
+	if err := svc.setRing(state); err != nil {
+		log.Printf("[RING] Failed to switch to ring version %d, keeping %d: %v", state.Version, prev, err)
+	} else if svc.version != prev {
+		log.Printf("[RING] Switched from ring version %d to %d with %d nodes", prev, svc.version, svc.ring.size())
 	}
+}
 
-	removeAddr := req.NodeAddress
-	removeHash := hashStringToUint64(removeAddr)
+// syncRing catches up with the stored ring before a membership change,
+// in case the watch has not delivered it yet. The caller must hold
+// svc.mu.
+func (svc *NetworkVideoContentService) syncRing() error {
+	if svc.store == nil {
+		return nil
+	}
+	state, err := svc.store.LoadRing()
+	if err != nil {
+		return fmt.Errorf("failed to load ring: %v", err)
+	}
+	if state != nil {
+		return svc.setRing(state)
+	}
+	return nil
+}
 
-	client, exists := svc.nodes[removeAddr]
-	if !exists {
-		return nil, fmt.Errorf("node does not exist")
+// setRing replaces the ring, and the transitional ring, with state if it
+// is newer, connecting to nodes that joined and dropping those that left.
+// The caller must hold svc.mu.
+func (svc *NetworkVideoContentService) setRing(state *RingState) error {
+	if state.Version <= svc.version {
+		return nil
+	}
+	for addr := range state.Weights {
+		if err := svc.connect(addr); err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
+	}
+	for addr := range state.Next {
+		if err := svc.connect(addr); err != nil {
+			return fmt.Errorf("failed to connect to %s: %v", addr, err)
+		}
 	}
 
-	var succHash uint64
-	for i, h := range svc.nodesHashes {
-		if h == removeHash {
-			succHash = svc.nodesHashes[(i+1)%len(svc.nodesHashes)]
-			break
+	svc.ring = newHashRing(state.VNodes, state.Weights)
+	svc.next, svc.nextOp = nil, ""
+	if state.Next != nil {
+		svc.next, svc.nextOp = newHashRing(state.VNodes, state.Next), state.Operation
+	}
+	svc.version = state.Version
+	svc.dropNonMembers()
+	return nil
+}
+
+// rebalanceKey identifies one file on the storage nodes.
+type rebalanceKey struct{ videoId, filename string }
+
+// rebalance moves files so that every key ends up on exactly its replica
+// set in newRing. It lists every file on every node of both rings, since
+// nodes cannot list by token range; only keys whose replica set changed
+// are then copied. Each key is copied to any new owner that lacks it, and
+// only then deleted from holders that are no longer owners. Keys whose
+// copy fails are left in place. Progress is counted in op, which carries
+// over counts from an interrupted run. It runs without svc.mu; every node
+// of both rings must have been connected.
+//
+// Writers that chose their owners from oldRing, because they were opened
+// before the transitional ring was saved or on a server that had not yet
+// seen it, may add files after the listing, so the nodes are listed again
+// once the first pass is done and any new keys moved too.
+//
+// It returns an error, so the caller keeps the transitional ring, if a
+// node of newRing could not be listed or a key is missing any of its new
+// owners. Departing nodes that cannot be listed are skipped, see
+// listHolders. It stops, deleting nothing, once ctx is done.
+func (svc *NetworkVideoContentService) rebalance(ctx context.Context, oldRing, newRing *hashRing, op *RebalanceOperation) error {
+	// Deleted videos left on a node that was down must not be copied
+	// back onto their owners.
+	svc.retryDeletes()
+
+	members := oldRing.nodes()
+	for _, addr := range newRing.nodes() {
+		if !oldRing.contains(addr) {
+			members = append(members, addr)
+		}
+	}
+
+	holders, order, err := svc.listHolders(ctx, newRing, members)
+	if err != nil {
+		return err
+	}
+
+	// Copies made before an interruption now count as held, so the
+	// total is what was moved already plus what is left.
+	op.FilesTotal = op.FilesMoved + svc.pendingCopies(newRing, holders, order)
+	op.FilesFailed = 0
+	svc.saveOperation(op)
+
+	// deletions[addr][videoId] lists files to drop from addr once copied.
+	deletions := make(map[string]map[string][]string)
+	if err := svc.moveKeys(ctx, newRing, holders, order, op, deletions); err != nil {
+		return err
+	}
+
+	relisted, relistedOrder, err := svc.listHolders(ctx, newRing, members)
+	if err != nil {
+		return err
+	}
+	var late []rebalanceKey
+	for _, k := range relistedOrder {
+		if _, ok := holders[k]; !ok {
+			late = append(late, k)
+		}
+	}
+	if len(late) > 0 {
+		log.Printf("[REBALANCE] Moving %d files written during the first pass", len(late))
+		op.FilesTotal += svc.pendingCopies(newRing, relisted, late)
+		if err := svc.moveKeys(ctx, newRing, relisted, late, op, deletions); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		svc.saveOperation(op)
+		return stopped(ctx)
+	}
+	for addr, videos := range deletions {
+		for vid, filenames := range videos {
+			_, err := svc.getClient(addr).DeleteFiles(ctx, &proto.BatchDeleteRequest{
+				VideoId:   vid,
+				Filenames: filenames,
+			})
+			if err != nil {
+				log.Printf("[REBALANCE] Failed to delete source files for Video ID %s on %s: %v", vid, addr, err)
+			}
 		}
 	}
-	succAddr := svc.hashToNode[succHash]
-	succClient := svc.nodes[succAddr]
+	svc.saveOperation(op)
 
-	var migratedCount int32
+	if op.FilesFailed > 0 {
+		return fmt.Errorf("%d files could not be copied to their new owners, keeping the transitional ring", op.FilesFailed)
+	}
+	return nil
+}
+
+// stopped explains why a rebalance under ctx gave up.
+func stopped(ctx context.Context) error {
+	return fmt.Errorf("stopped moving files: %v", context.Cause(ctx))
+}
+
+// listHolders lists every file on addrs, returning the nodes holding each
+// key and the keys in the order they were found. A node that is not in
+// newRing and cannot be listed is skipped, so that a dead node can still
+// be removed: its keys are copied from their other holders, and keys it
+// alone held are lost with it. Any other node must be listed.
+func (svc *NetworkVideoContentService) listHolders(ctx context.Context, newRing *hashRing, addrs []string) (map[rebalanceKey][]string, []rebalanceKey, error) {
+	holders := make(map[rebalanceKey][]string)
+	var order []rebalanceKey
+	for _, addr := range addrs {
+		if ctx.Err() != nil {
+			return nil, nil, stopped(ctx)
+		}
+		keys, err := svc.listNodeFiles(ctx, addr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, stopped(ctx)
+			}
+			if !newRing.contains(addr) {
+				log.Printf("[REBALANCE] Skipping departing node %s, files only it holds cannot be moved: %v", addr, err)
+				continue
+			}
+			return nil, nil, err
+		}
+		for _, k := range keys {
+			if _, ok := holders[k]; !ok {
+				order = append(order, k)
+			}
+			holders[k] = append(holders[k], addr)
+		}
+	}
+	return holders, order, nil
+}
+
+// listNodeFiles lists every file on the node at addr.
+func (svc *NetworkVideoContentService) listNodeFiles(ctx context.Context, addr string) ([]rebalanceKey, error) {
+	client := svc.getClient(addr)
 	videosResp, err := client.ListVideos(ctx, &proto.ListVideosRequest{})
 	if err != nil {
-		return nil, fmt.Errorf("list videos failed: %v", err)
+		log.Printf("[REBALANCE] ListVideos failed on %s: %v", addr, err)
+		return nil, fmt.Errorf("failed to list videos on %s: %v", addr, err)
 	}
+	var keys []rebalanceKey
 	for _, vid := range videosResp.VideoIds {
 		filesResp, err := client.ListVideoFiles(ctx, &proto.ListVideoFilesRequest{
 			VideoId: vid,
 		})
 		if err != nil {
-			log.Printf("[RemoveNode] Error listing files for %s: %v", vid, err)
-			continue
+			log.Printf("[REBALANCE] Error listing files for %s on %s: %v", vid, addr, err)
+			return nil, fmt.Errorf("failed to list files of %s on %s: %v", vid, addr, err)
 		}
 		for _, fname := range filesResp.Filenames {
-			if err := migrateFileSync(vid, fname, client, succClient); err == nil {
-				migratedCount++
-			}
+			keys = append(keys, rebalanceKey{vid, fname})
 		}
 	}
+	return keys, nil
+}
 
-	delete(svc.nodes, removeAddr)
-	delete(svc.hashToNode, removeHash)
-	for i, h := range svc.nodesHashes {
-		if h == removeHash {
-			svc.nodesHashes = append(svc.nodesHashes[:i], svc.nodesHashes[i+1:]...)
-			break
+// pendingCopies counts the copies keys need to reach their owners in
+// newRing.
+func (svc *NetworkVideoContentService) pendingCopies(newRing *hashRing, holders map[rebalanceKey][]string, keys []rebalanceKey) int64 {
+	var pending int64
+	for _, k := range keys {
+		for _, owner := range newRing.owners(fmt.Sprintf("%s/%s", k.videoId, k.filename), svc.replicas) {
+			if !slices.Contains(holders[k], owner) {
+				pending++
+			}
 		}
 	}
-
-	log.Printf("[RemoveNode] Removed %s (hash %d), migrated %d files to %s", removeAddr, removeHash, migratedCount, succAddr)
-	return &proto.RemoveNodeResponse{MigratedFileCount: migratedCount}, nil
+	return pending
 }
 
-func (svc *NetworkVideoContentService) ListNodes(ctx context.Context, req *proto.ListNodesRequest) (*proto.ListNodesResponse, error) {
-	svc.mu.RLock()
-	defer svc.mu.RUnlock()
+// moveKeys copies each of keys to the owners in newRing that lack it,
+// counting progress in op, and adds the holders that are no longer
+// owners to deletions once a key's replica set is complete.
+func (svc *NetworkVideoContentService) moveKeys(ctx context.Context, newRing *hashRing, holders map[rebalanceKey][]string, keys []rebalanceKey, op *RebalanceOperation, deletions map[string]map[string][]string) error {
+	lastSave := time.Now()
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			svc.saveOperation(op)
+			return stopped(ctx)
+		}
+		if time.Since(lastSave) >= operationSaveInterval {
+			svc.saveOperation(op)
+			lastSave = time.Now()
+		}
 
-	var sortedNodes []string
-	for _, hash := range svc.nodesHashes {
-		addr := svc.hashToNode[hash]
-		sortedNodes = append(sortedNodes, addr)
-	}
+		key := fmt.Sprintf("%s/%s", k.videoId, k.filename)
+		owners := newRing.owners(key, svc.replicas)
 
-	log.Printf("[ListNodes] Returning %d nodes: %v", len(sortedNodes), sortedNodes)
-	return &proto.ListNodesResponse{Nodes: sortedNodes}, nil
-}
+		has := make(map[string]bool)
+		for _, addr := range holders[k] {
+			has[addr] = true
+		}
+
+		complete := true
+		for _, owner := range owners {
+			if has[owner] {
+				continue
+			}
+			// Written to the new owner since the listing above.
+			if _, err := svc.getClient(owner).Stat(ctx, &proto.FileRequest{VideoId: k.videoId, Filename: k.filename}); err == nil {
+				has[owner] = true
+				op.FilesTotal--
+				continue
+			}
+			copied := false
+			for _, src := range holders[k] {
+				n, err := migrateFileSync(ctx, k.videoId, k.filename, svc.getClient(src), svc.getClient(owner))
+				if err == nil {
+					copied = true
+					op.BytesMoved += n
+					break
+				}
+				log.Printf("[REBALANCE] Failed to copy %s from %s to %s: %v", key, src, owner, err)
+			}
+			if !copied {
+				complete = false
+				op.FilesFailed++
+				continue
+			}
+			has[owner] = true
+			op.FilesMoved++
+		}
+		if !complete {
+			log.Printf("[REBALANCE] Keeping all copies of %s, replica set incomplete", key)
+			continue
+		}
 
-func inRangeExclusive(start, end, hash uint64) bool {
-	if start < end {
-		return hash > start && hash <= end
+		isOwner := make(map[string]bool)
+		for _, owner := range owners {
+			isOwner[owner] = true
+		}
+		for _, addr := range holders[k] {
+			if isOwner[addr] {
+				continue
+			}
+			if deletions[addr] == nil {
+				deletions[addr] = make(map[string][]string)
+			}
+			deletions[addr][k.videoId] = append(deletions[addr][k.videoId], k.filename)
+		}
 	}
-	return hash > start || hash <= end
+	return nil
 }
 
-func migrateFileSync(videoId, filename string, from proto.StorageClient, to proto.StorageClient) error {
-	ctx := context.Background()
+// migrateFileSync copies one file between nodes and only reports success
+// once the bytes received match the source's digest and the destination
+// acknowledges the same digest, so callers can safely delete the source.
+// A source without a digest is copied as unverified, keeping the scrubber
+// reporting it on the destination.
+// It returns the number of bytes copied.
+func migrateFileSync(ctx context.Context, videoId, filename string, from proto.StorageClient, to proto.StorageClient) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	log.Printf("[MIGRATE] Starting migration of %s/%s", videoId, filename)
 
 	downloadStream, err := from.Download(ctx, &proto.FileRequest{
@@ -378,16 +1422,19 @@ func migrateFileSync(videoId, filename string, from proto.StorageClient, to prot
 	})
 	if err != nil {
 		log.Printf("[MIGRATE] Failed to start download for %s/%s: %v", videoId, filename, err)
-		return err
+		return 0, err
 	}
 
 	uploadStream, err := to.Upload(ctx)
 	if err != nil {
 		log.Printf("[MIGRATE] Failed to start upload for %s/%s: %v", videoId, filename, err)
-		return err
+		return 0, err
 	}
 
 	chunkCount := 0
+	var size int64
+	hasher := sha256.New()
+	var expected string
 	for {
 		chunk, err := downloadStream.Recv()
 		if err == io.EOF {
@@ -395,26 +1442,44 @@ func migrateFileSync(videoId, filename string, from proto.StorageClient, to prot
 		}
 		if err != nil {
 			log.Printf("[MIGRATE] Error receiving chunk for %s/%s: %v", videoId, filename, err)
-			return err
+			return 0, err
 		}
 
+		if chunkCount == 0 {
+			expected = chunk.Sha256
+			// Without a source digest nobody has checked these bytes,
+			// so the copy must not get a digest of its own.
+			chunk.Unverified = expected == ""
+		}
 		chunkCount++
+		hasher.Write(chunk.Data)
+		size += int64(len(chunk.Data))
 		if err := uploadStream.Send(chunk); err != nil {
 			log.Printf("[MIGRATE] Error sending chunk for %s/%s: %v", videoId, filename, err)
-			return err
+			return 0, err
 		}
 	}
 
 	ack, err := uploadStream.CloseAndRecv()
 	if err != nil {
 		log.Printf("[MIGRATE] Failed to finalize upload for %s/%s: %v", videoId, filename, err)
-		return err
+		return 0, err
 	}
 	if !ack.Success {
 		log.Printf("[MIGRATE] Upload failed (ack=false) for %s/%s", videoId, filename)
-		return fmt.Errorf("upload ack failed for %s/%s", videoId, filename)
+		return 0, fmt.Errorf("upload ack failed for %s/%s", videoId, filename)
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if expected != "" && actual != expected {
+		log.Printf("[MIGRATE] Checksum mismatch for %s/%s: source %s, received %s", videoId, filename, expected, actual)
+		return 0, fmt.Errorf("checksum mismatch for %s/%s", videoId, filename)
+	}
+	if ack.Sha256 != actual {
+		log.Printf("[MIGRATE] Checksum mismatch for %s/%s: sent %s, stored %s", videoId, filename, actual, ack.Sha256)
+		return 0, fmt.Errorf("checksum mismatch for %s/%s", videoId, filename)
 	}
 
 	log.Printf("[MIGRATE] Successfully migrated %s/%s with %d chunks", videoId, filename, chunkCount)
-	return nil
+	return size, nil
 }
@@ -0,0 +1,691 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tritontube/internal/proto"
+	"tritontube/internal/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// startStorageNode serves a storage node over a temporary directory and
+// returns its address.
+func startStorageNode(t *testing.T) string {
+	t.Helper()
+	addr, _ := serveStorageNode(t, t.TempDir(), "127.0.0.1:0")
+	return addr
+}
+
+// startStorageNodes starts count storage nodes and returns their
+// addresses and the directory of each.
+func startStorageNodes(t *testing.T, count int) ([]string, map[string]string) {
+	t.Helper()
+	var addrs []string
+	dirs := make(map[string]string)
+	for i := 0; i < count; i++ {
+		dir := t.TempDir()
+		addr, _ := serveStorageNode(t, dir, "127.0.0.1:0")
+		addrs = append(addrs, addr)
+		dirs[addr] = dir
+	}
+	return addrs, dirs
+}
+
+// serveStorageNode serves a storage node over dir at addr, returning its
+// address and a function that stops it.
+func serveStorageNode(t *testing.T, dir, addr string) (string, func()) {
+	t.Helper()
+	srv, err := storage.NewServer(dir)
+	if err != nil {
+		t.Fatalf("storage.NewServer: %v", err)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := grpc.NewServer()
+	proto.RegisterStorageServer(g, srv)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	return lis.Addr().String(), g.Stop
+}
+
+// newTestCluster returns a service whose ring holds addrs, connected but
+// without a ring store, admin server or health checks.
+func newTestCluster(t *testing.T, replicas int, addrs ...string) *NetworkVideoContentService {
+	t.Helper()
+	n := &NetworkVideoContentService{
+		nodes:          make(map[string]proto.StorageClient),
+		conns:          make(map[string]*grpc.ClientConn),
+		health:         newHealthTracker(),
+		replicas:       replicas,
+		ops:            newMemoryOperations(),
+		pendingDeletes: make(map[string]map[string]bool),
+	}
+	weights := make(map[string]int)
+	for _, addr := range addrs {
+		weights[addr] = 1
+	}
+	if err := n.setRing(&RingState{Version: 1, VNodes: 16, Weights: weights}); err != nil {
+		t.Fatalf("setRing: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, conn := range n.conns {
+			conn.Close()
+		}
+	})
+	return n
+}
+
+// putTestFiles stores count files of a video through n and returns their
+// keys.
+func putTestFiles(t *testing.T, n *NetworkVideoContentService, videoId string, count int) []string {
+	t.Helper()
+	var keys []string
+	for i := 0; i < count; i++ {
+		filename := fmt.Sprintf("chunk-0-%05d.m4s", i)
+		w, err := n.Create(videoId, filename)
+		if err != nil {
+			t.Fatalf("Create %s: %v", filename, err)
+		}
+		io.WriteString(w, "segment "+filename)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close %s: %v", filename, err)
+		}
+		keys = append(keys, videoId+"/"+filename)
+	}
+	return keys
+}
+
+// holds reports whether the node at addr stores key.
+func holds(n *NetworkVideoContentService, addr, key string) bool {
+	videoId, filename, _ := strings.Cut(key, "/")
+	_, err := n.getClient(addr).Stat(context.Background(), &proto.FileRequest{VideoId: videoId, Filename: filename})
+	return err == nil
+}
+
+// failingRingStore refuses to save rings while failing is set.
+type failingRingStore struct {
+	saved   []*RingState
+	failing bool
+}
+
+func (f *failingRingStore) LoadRing() (*RingState, error) {
+	if len(f.saved) == 0 {
+		return nil, nil
+	}
+	return f.saved[len(f.saved)-1], nil
+}
+
+func (f *failingRingStore) SaveRing(state *RingState) error {
+	if f.failing {
+		return errors.New("etcdserver: request timed out")
+	}
+	f.saved = append(f.saved, state)
+	return nil
+}
+
+func TestFinishRebalanceKeepsRingWhenSaveFails(t *testing.T) {
+	n := newTestNetworkService(1, map[string]string{"a:1": NodeUp, "b:1": NodeUp})
+	store := &failingRingStore{failing: true}
+	n.store = store
+	oldRing := n.ring
+	n.next, n.nextOp = oldRing.remove("b:1"), "op1"
+
+	if err := n.finishRebalance(); err == nil {
+		t.Fatal("finishRebalance succeeded without saving the ring")
+	}
+	if n.ring != oldRing || n.next == nil || n.nextOp != "op1" {
+		t.Fatalf("unsaved ring was swapped in: ring %v, next %v", n.ring.nodes(), n.next)
+	}
+
+	store.failing = false
+	if err := n.finishRebalance(); err != nil {
+		t.Fatalf("finishRebalance: %v", err)
+	}
+	if n.ring.contains("b:1") || n.next != nil {
+		t.Fatalf("ring after finish = %v, next %v", n.ring.nodes(), n.next)
+	}
+	if saved := store.saved[len(store.saved)-1]; saved.Version != n.version || saved.Next != nil || len(saved.Weights) != 1 {
+		t.Fatalf("saved ring = %+v, in use version %d", saved, n.version)
+	}
+}
+
+func TestDroppedNodeConnectionIsClosed(t *testing.T) {
+	n := newTestNetworkService(1, map[string]string{"a:1": NodeUp})
+	if err := n.connect("127.0.0.1:1"); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	n.ring = n.ring.add("127.0.0.1:1", 1)
+	conn := n.conns["127.0.0.1:1"]
+
+	n.ring = n.ring.remove("127.0.0.1:1")
+	n.dropNonMembers()
+	if got := conn.GetState(); got != connectivity.Shutdown {
+		t.Fatalf("connection state after drop = %v, want %v", got, connectivity.Shutdown)
+	}
+	if _, ok := n.conns["127.0.0.1:1"]; ok || n.getClient("127.0.0.1:1") != nil {
+		t.Fatal("dropped node is still connected")
+	}
+	if _, ok := n.health.nodes["127.0.0.1:1"]; ok {
+		t.Fatal("dropped node is still probed")
+	}
+}
+
+func TestRebalanceStopsWhenLeadershipIsLost(t *testing.T) {
+	a, b := startStorageNode(t), startStorageNode(t)
+	n := newTestCluster(t, 1, a)
+	keys := putTestFiles(t, n, "01JAAAAAAAAAAAAAAAAAAAAAAA", 20)
+	if err := n.connect(b); err != nil {
+		t.Fatal(err)
+	}
+
+	leading, stop := context.WithCancelCause(context.Background())
+	stop(ErrNotLeader)
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning}
+	err := n.rebalance(leading, n.ring, n.ring.add(b, 1), op)
+	if err == nil || !strings.Contains(err.Error(), ErrNotLeader.Error()) {
+		t.Fatalf("rebalance after losing leadership = %v", err)
+	}
+	for _, key := range keys {
+		if !holds(n, a, key) {
+			t.Fatalf("%s was deleted from %s", key, a)
+		}
+		if holds(n, b, key) {
+			t.Fatalf("%s was copied to %s", key, b)
+		}
+	}
+}
+
+// hookedClient runs onUpload before each Upload to the node, failing the
+// Upload if it returns an error.
+type hookedClient struct {
+	proto.StorageClient
+	onUpload func() error
+}
+
+func (c *hookedClient) Upload(ctx context.Context, opts ...grpc.CallOption) (proto.Storage_UploadClient, error) {
+	if err := c.onUpload(); err != nil {
+		return nil, err
+	}
+	return c.StorageClient.Upload(ctx, opts...)
+}
+
+func TestFailedRebalanceKeepsTransitionalRing(t *testing.T) {
+	a, b := startStorageNode(t), startStorageNode(t)
+	n := newTestCluster(t, 1, a, b)
+	keys := putTestFiles(t, n, "01JAAAAAAAAAAAAAAAAAAAAAAA", 20)
+	var failing atomic.Bool
+	failing.Store(true)
+	n.nodes[a] = &hookedClient{StorageClient: n.nodes[a], onUpload: func() error {
+		if failing.Load() {
+			return errors.New("disk full")
+		}
+		return nil
+	}}
+	removeB := func(ring *hashRing) (*hashRing, error) { return ring.remove(b), nil }
+
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning}
+	err := n.changeMembership(op, removeB)
+	if err == nil {
+		t.Fatal("rebalance with failed copies succeeded")
+	}
+	n.completeOperation(op, err)
+	if op.FilesFailed == 0 || op.Status != OpFailed {
+		t.Fatalf("operation %s with %d failed files", op.Status, op.FilesFailed)
+	}
+	if !n.ring.contains(b) || n.next == nil || n.next.contains(b) || n.nextOp != "op1" {
+		t.Fatalf("ring %v, transitional %v after failed rebalance", n.ring.nodes(), n.next)
+	}
+	for _, key := range keys {
+		videoId, filename, _ := strings.Cut(key, "/")
+		r, _, err := n.Open(videoId, filename)
+		if err != nil {
+			t.Fatalf("%s unreadable after failed rebalance: %v", key, err)
+		}
+		r.Close()
+	}
+
+	// Removing b again retries the move.
+	failing.Store(false)
+	retry := &RebalanceOperation{Id: "op2", Status: OpRunning}
+	err = n.changeMembership(retry, removeB)
+	n.completeOperation(retry, err)
+	if err != nil || retry.Status != OpDone {
+		t.Fatalf("retried rebalance = %s, %v", retry.Status, err)
+	}
+	if n.ring.contains(b) || n.next != nil {
+		t.Fatalf("ring %v, transitional %v after retry", n.ring.nodes(), n.next)
+	}
+	for _, key := range keys {
+		if !holds(n, a, key) {
+			t.Fatalf("%s is missing from %s after retry", key, a)
+		}
+	}
+}
+
+func TestOperationWithFailedFilesIsNotDone(t *testing.T) {
+	n := newTestNetworkService(1, map[string]string{"a:1": NodeUp})
+	n.ops = newMemoryOperations()
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning, FilesTotal: 10, FilesMoved: 9, FilesFailed: 1}
+
+	n.completeOperation(op, nil)
+	if op.Status != OpFailed || op.Error == "" {
+		t.Fatalf("operation with a failed file = %s (%q), want %s", op.Status, op.Error, OpFailed)
+	}
+}
+
+func TestRebalanceMovesFilesWrittenByStaleWriters(t *testing.T) {
+	a, b := startStorageNode(t), startStorageNode(t)
+	n := newTestCluster(t, 1, a)
+	putTestFiles(t, n, "01JAAAAAAAAAAAAAAAAAAAAAAA", 20)
+	newRing := n.ring.add(b, 1)
+
+	// A server that has not seen the transitional ring writes to a
+	// while the first pass is copying to b.
+	peer := newTestCluster(t, 1, a)
+	var late []string
+	var once sync.Once
+	if err := n.connect(b); err != nil {
+		t.Fatal(err)
+	}
+	n.nodes[b] = &hookedClient{StorageClient: n.nodes[b], onUpload: func() error {
+		once.Do(func() {
+			for _, key := range putTestFiles(t, peer, "01JBBBBBBBBBBBBBBBBBBBBBBB", 20) {
+				if newRing.owners(key, 1)[0] == b {
+					late = append(late, key)
+				}
+			}
+		})
+		return nil
+	}}
+
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning}
+	if err := n.changeMembership(op, func(ring *hashRing) (*hashRing, error) { return newRing, nil }); err != nil {
+		t.Fatalf("changeMembership: %v", err)
+	}
+	if len(late) == 0 {
+		t.Fatal("no late file belongs on the new node")
+	}
+	for _, key := range late {
+		if !holds(n, b, key) || holds(n, a, key) {
+			t.Fatalf("late file %s was not moved to its new owner", key)
+		}
+	}
+}
+
+func TestDeleteRemovesEveryReplica(t *testing.T) {
+	a, b, c := startStorageNode(t), startStorageNode(t), startStorageNode(t)
+	n := newTestCluster(t, 2, a, b, c)
+	keys := putTestFiles(t, n, "01JAAAAAAAAAAAAAAAAAAAAAAA", 10)
+	other := putTestFiles(t, n, "01JBBBBBBBBBBBBBBBBBBBBBBB", 1)
+
+	if err := n.Delete("01JAAAAAAAAAAAAAAAAAAAAAAA"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for _, key := range keys {
+		for _, addr := range []string{a, b, c} {
+			if holds(n, addr, key) {
+				t.Fatalf("%s still on %s after Delete", key, addr)
+			}
+		}
+	}
+	if _, err := n.Stat("01JBBBBBBBBBBBBBBBBBBBBBBB", strings.TrimPrefix(other[0], "01JBBBBBBBBBBBBBBBBBBBBBBB/")); err != nil {
+		t.Fatalf("Delete removed another video: %v", err)
+	}
+}
+
+func TestDeleteRetriesUnreachableNode(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	a := startStorageNode(t)
+	bDir := t.TempDir()
+	b, stopB := serveStorageNode(t, bDir, "127.0.0.1:0")
+	n := newTestCluster(t, 2, a, b)
+	store := newTestSQLite(t)
+	n.deletes = store
+	keys := putTestFiles(t, n, videoId, 5)
+
+	stopB()
+	if err := n.Delete(videoId); err != nil {
+		t.Fatalf("Delete with a node down = %v, want nil", err)
+	}
+	for _, key := range keys {
+		if holds(n, a, key) {
+			t.Fatalf("%s still on reachable node %s", key, a)
+		}
+	}
+	want := []PendingDelete{{Node: b, VideoId: videoId}}
+	if got, err := store.ListPendingDeletes(); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored pending deletes = %v, %v; want %v", got, err, want)
+	}
+
+	// A restarted web server picks the delete up from the store.
+	n = newTestCluster(t, 2, a, b)
+	n.deletes = store
+	if err := n.loadPendingDeletes(); err != nil {
+		t.Fatalf("loadPendingDeletes: %v", err)
+	}
+	if !n.pendingDeletes[b][videoId] {
+		t.Fatalf("pending deletes = %v, want %s on %s", n.pendingDeletes, videoId, b)
+	}
+
+	// While the node is marked down the delete stays queued.
+	n.health.nodes[b].state = NodeDown
+	n.retryDeletes()
+	if !n.pendingDeletes[b][videoId] {
+		t.Fatal("retry on a down node dropped the pending delete")
+	}
+
+	n.health.nodes[b].state = NodeUp
+	serveStorageNode(t, bDir, b)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(n.pendingDeletes) > 0 && time.Now().Before(deadline) {
+		n.retryDeletes()
+		time.Sleep(50 * time.Millisecond)
+	}
+	for _, key := range keys {
+		if holds(n, b, key) {
+			t.Fatalf("%s still on %s after the retried delete", key, b)
+		}
+	}
+	if got, _ := store.ListPendingDeletes(); len(got) != 0 {
+		t.Fatalf("stored pending deletes after the retry = %v", got)
+	}
+}
+
+func TestContentSkipsCorruptReplica(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	addrs, dirs := startStorageNodes(t, 2)
+	n := newTestCluster(t, 2, addrs...)
+	data := strings.Repeat("0123456789", 10)
+	w, err := n.Create(videoId, "chunk-0-00001.m4s")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	io.WriteString(w, data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	corrupt := func(addr string) {
+		path := filepath.Join(dirs[addr], videoId, "chunk-0-00001.m4s")
+		if err := os.WriteFile(path, []byte(strings.Repeat("X", len(data))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &server{contentService: n}
+	path := "/content/" + videoId + "/chunk-0-00001.m4s"
+	order := n.preferHealthy(n.readNodesForKey(videoId + "/chunk-0-00001.m4s"))
+	corrupt(order[0])
+	for _, tt := range []struct{ rangeHeader, want string }{
+		{"bytes=10-19", data[10:20]},
+		{"", data},
+		{"bytes=20-29", data[20:30]},
+	} {
+		rec := getContent(s, path, tt.rangeHeader)
+		if rec.Code/100 != 2 || rec.Body.String() != tt.want {
+			t.Fatalf("GET %q with a corrupt first replica = %d %q, want %q", tt.rangeHeader, rec.Code, rec.Body, tt.want)
+		}
+	}
+	st, err := n.getClient(order[0]).Scrub(context.Background(), &proto.ScrubRequest{})
+	if err != nil || len(st.Corrupt) != 1 {
+		t.Fatalf("corrupt replica not reported by its node: %v, %v", st, err)
+	}
+
+	// With every replica corrupt, none of the bad bytes are served.
+	corrupt(order[1])
+	if rec := getContent(s, path, ""); strings.Contains(rec.Body.String(), "X") {
+		t.Fatalf("served corrupt bytes: %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestMigrateKeepsUnverifiedFilesUnverified(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	dirA := t.TempDir()
+	a, _ := serveStorageNode(t, dirA, "127.0.0.1:0")
+	b := startStorageNode(t)
+	n := newTestCluster(t, 1, a)
+	putTestFiles(t, n, videoId, 1)
+	if err := os.Remove(filepath.Join(dirA, videoId, ".sha256-chunk-0-00000.m4s")); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.connect(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrateFileSync(context.Background(), videoId, "chunk-0-00000.m4s", n.getClient(a), n.getClient(b)); err != nil {
+		t.Fatalf("migrateFileSync: %v", err)
+	}
+	info, err := n.getClient(b).Stat(context.Background(), &proto.FileRequest{VideoId: videoId, Filename: "chunk-0-00000.m4s"})
+	if err != nil {
+		t.Fatalf("Stat on destination: %v", err)
+	}
+	if info.Sha256 != "" {
+		t.Fatalf("copy of an unverified file got digest %s", info.Sha256)
+	}
+}
+
+// scrubCluster starts a scrub pass on every node, waits for them all to
+// finish and then collects the findings, repairing them if repair is set.
+func scrubCluster(t *testing.T, n *NetworkVideoContentService, repair bool) *proto.ScrubClusterResponse {
+	t.Helper()
+	if _, err := n.Scrub(context.Background(), &proto.ScrubClusterRequest{Start: true}); err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := n.Scrub(context.Background(), &proto.ScrubClusterRequest{})
+		if err != nil {
+			t.Fatalf("Scrub: %v", err)
+		}
+		running := false
+		for _, report := range resp.Nodes {
+			running = running || report.Running || report.LastCompletedUnix == 0
+		}
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scrub did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := n.Scrub(context.Background(), &proto.ScrubClusterRequest{Repair: repair})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	return resp
+}
+
+func findings(resp *proto.ScrubClusterResponse) map[string][]*proto.ScrubFinding {
+	found := make(map[string][]*proto.ScrubFinding)
+	for _, report := range resp.Nodes {
+		found[report.NodeAddress] = append(found[report.NodeAddress], report.Findings...)
+	}
+	return found
+}
+
+func TestScrubRepairsFromVerifiedReplica(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	addrs, dirs := startStorageNodes(t, 2)
+	n := newTestCluster(t, 2, addrs...)
+	putTestFiles(t, n, videoId, 2)
+	a, b := addrs[0], addrs[1]
+
+	// chunk 0 is corrupt on a, chunk 1 has lost its checksum on b.
+	corruptPath := filepath.Join(dirs[a], videoId, "chunk-0-00000.m4s")
+	if err := os.WriteFile(corruptPath, []byte("segment chunk-0-0000X.m4s"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dirs[b], videoId, ".sha256-chunk-0-00001.m4s")); err != nil {
+		t.Fatal(err)
+	}
+
+	found := findings(scrubCluster(t, n, false))
+	if len(found[a]) != 1 || found[a][0].Filename != "chunk-0-00000.m4s" || found[a][0].ExpectedSha256 == "" {
+		t.Fatalf("findings on %s = %v, want chunk 0 corrupt", a, found[a])
+	}
+	if len(found[b]) != 1 || found[b][0].Filename != "chunk-0-00001.m4s" || found[b][0].ExpectedSha256 != "" {
+		t.Fatalf("findings on %s = %v, want chunk 1 unverified", b, found[b])
+	}
+
+	resp := scrubCluster(t, n, true)
+	if resp.RepairedCount != 2 {
+		t.Fatalf("repaired %d files, want 2: %v", resp.RepairedCount, findings(resp))
+	}
+	if data, _ := os.ReadFile(corruptPath); string(data) != "segment chunk-0-00000.m4s" {
+		t.Fatalf("repaired file holds %q", data)
+	}
+	if found := findings(scrubCluster(t, n, false)); len(found[a])+len(found[b]) != 0 {
+		t.Fatalf("findings after repair = %v", found)
+	}
+}
+
+func TestScrubDoesNotRepairFromUnverifiedReplica(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	addrs, dirs := startStorageNodes(t, 2)
+	n := newTestCluster(t, 2, addrs...)
+	putTestFiles(t, n, videoId, 1)
+	a, b := addrs[0], addrs[1]
+
+	// The only other copy of the corrupt file has no checksum to vouch
+	// for it.
+	corruptPath := filepath.Join(dirs[a], videoId, "chunk-0-00000.m4s")
+	if err := os.WriteFile(corruptPath, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dirs[b], videoId, ".sha256-chunk-0-00000.m4s")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := scrubCluster(t, n, true)
+	if resp.RepairedCount != 0 {
+		t.Fatalf("repaired %d files from an unverified replica", resp.RepairedCount)
+	}
+	for _, f := range findings(resp)[a] {
+		if f.Repair == "repaired" {
+			t.Fatalf("finding %v was repaired", f)
+		}
+	}
+	if data, _ := os.ReadFile(corruptPath); string(data) != "garbage" {
+		t.Fatalf("corrupt file was overwritten with %q", data)
+	}
+}
+
+func TestLoadRingPrefersStoredRing(t *testing.T) {
+	store := newTestSQLite(t)
+	seed := &RingState{Version: 1, VNodes: 16, Weights: map[string]int{"a:1": 1, "b:1": 1}}
+
+	// The first start saves the -nw seed.
+	got, err := loadRing(store, seed)
+	if err != nil || got != seed {
+		t.Fatalf("loadRing on an empty store = %+v, %v; want the seed", got, err)
+	}
+	if stored, _ := store.LoadRing(); !reflect.DeepEqual(stored, seed) {
+		t.Fatalf("stored ring = %+v, want the seed", stored)
+	}
+
+	// The admin CLI then removed b and added c with weight 2.
+	changed := &RingState{Version: 2, VNodes: 16, Weights: map[string]int{"a:1": 1, "c:1": 2}}
+	if err := store.SaveRing(changed); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart with the old flags, a new node and other weights and
+	// vnodes keeps the stored ring.
+	restart := &RingState{Version: 1, VNodes: 64, Weights: map[string]int{"a:1": 3, "b:1": 1, "d:1": 1}}
+	got, err = loadRing(store, restart)
+	if err != nil || !reflect.DeepEqual(got, changed) {
+		t.Fatalf("loadRing on restart = %+v, %v; want %+v", got, err, changed)
+	}
+	if stored, _ := store.LoadRing(); !reflect.DeepEqual(stored, changed) {
+		t.Fatalf("loadRing changed the stored ring to %+v", stored)
+	}
+
+	if got, err := loadRing(nil, restart); err != nil || got != restart {
+		t.Fatalf("loadRing without a store = %+v, %v; want the seed", got, err)
+	}
+}
+
+func TestRemoveUnreachableNode(t *testing.T) {
+	const videoId = "01JAAAAAAAAAAAAAAAAAAAAAAA"
+	addrs, _ := startStorageNodes(t, 2)
+	a, b := addrs[0], addrs[1]
+	c, stopC := serveStorageNode(t, t.TempDir(), "127.0.0.1:0")
+	n := newTestCluster(t, 2, a, b, c)
+	keys := putTestFiles(t, n, videoId, 20)
+	var failing atomic.Bool
+	failing.Store(true)
+	n.nodes[b] = &hookedClient{StorageClient: n.nodes[b], onUpload: func() error {
+		if failing.Load() {
+			return errors.New("disk full")
+		}
+		return nil
+	}}
+	removeC := func(ring *hashRing) (*hashRing, error) { return ring.remove(c), nil }
+
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning}
+	if err := n.changeMembership(op, removeC); err == nil {
+		t.Fatal("rebalance with failed copies succeeded")
+	}
+	if n.next == nil || n.nextOp != "op1" {
+		t.Fatalf("transitional ring %v not kept", n.next)
+	}
+
+	// c dies before the removal is retried; every file it held has a
+	// replica on a or b to copy from.
+	stopC()
+	failing.Store(false)
+	retry := &RebalanceOperation{Id: "op2", Status: OpRunning}
+	err := n.changeMembership(retry, removeC)
+	n.completeOperation(retry, err)
+	if err != nil || retry.Status != OpDone {
+		t.Fatalf("retried removal of a dead node = %s, %v", retry.Status, err)
+	}
+	if n.ring.contains(c) || n.next != nil {
+		t.Fatalf("ring %v, transitional %v after removal", n.ring.nodes(), n.next)
+	}
+	for _, key := range keys {
+		if !holds(n, a, key) || !holds(n, b, key) {
+			t.Fatalf("%s is not on both remaining nodes", key)
+		}
+	}
+}
+
+func TestRebalanceFailsWhenRemainingNodeIsUnreachable(t *testing.T) {
+	a := startStorageNode(t)
+	b, stopB := serveStorageNode(t, t.TempDir(), "127.0.0.1:0")
+	c := startStorageNode(t)
+	n := newTestCluster(t, 2, a, b, c)
+	keys := putTestFiles(t, n, "01JAAAAAAAAAAAAAAAAAAAAAAA", 10)
+
+	stopB()
+	op := &RebalanceOperation{Id: "op1", Status: OpRunning}
+	if err := n.changeMembership(op, func(ring *hashRing) (*hashRing, error) { return ring.remove(c), nil }); err == nil {
+		t.Fatal("rebalance succeeded without listing a remaining node")
+	}
+	if !n.ring.contains(c) || n.next == nil {
+		t.Fatalf("ring %v, transitional %v after failed rebalance", n.ring.nodes(), n.next)
+	}
+	for _, key := range keys {
+		for _, owner := range n.ring.owners(key, 2) {
+			if owner == c && !holds(n, c, key) {
+				t.Fatalf("%s was deleted from %s", key, c)
+			}
+		}
+	}
+}
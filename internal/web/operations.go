@@ -0,0 +1,238 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"tritontube/internal/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Progress is saved at most once per operationSaveInterval while files
+// move, and WatchOperation polls the store at operationPollInterval, so
+// watchers on any web server sharing the store see the same progress.
+const (
+	operationSaveInterval = time.Second
+	operationPollInterval = 500 * time.Millisecond
+)
+
+// memoryOperations keeps operations for a network service without a
+// metadata store; they are lost on restart like the ring itself.
+type memoryOperations struct {
+	mu  sync.Mutex
+	ops map[string]RebalanceOperation
+}
+
+func newMemoryOperations() *memoryOperations {
+	return &memoryOperations{ops: make(map[string]RebalanceOperation)}
+}
+
+func (m *memoryOperations) CreateOperation(op *RebalanceOperation) error {
+	return m.UpdateOperation(op)
+}
+
+func (m *memoryOperations) UpdateOperation(op *RebalanceOperation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops[op.Id] = *op
+	return nil
+}
+
+func (m *memoryOperations) ReadOperation(id string) (*RebalanceOperation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op, ok := m.ops[id]
+	if !ok {
+		return nil, nil
+	}
+	return &op, nil
+}
+
+// startOperation records a rebalance to the ring change derives from the
+// current one and runs it in the background. change is checked against
+// the current ring first so obvious mistakes fail the RPC rather than
+// the operation.
+func (svc *NetworkVideoContentService) startOperation(kind, node string, weight int, change func(ring *hashRing) (*hashRing, error)) (*RebalanceOperation, error) {
+	if !svc.rebalancing.TryLock() {
+		return nil, status.Error(codes.FailedPrecondition, "a rebalance is already in progress")
+	}
+
+	svc.mu.RLock()
+	_, err := change(svc.ring)
+	svc.mu.RUnlock()
+	if err != nil {
+		svc.rebalancing.Unlock()
+		return nil, err
+	}
+
+	now := time.Now()
+	op := &RebalanceOperation{
+		Id:        newJobID(),
+		Kind:      kind,
+		Node:      node,
+		Weight:    weight,
+		Status:    OpRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := svc.ops.CreateOperation(op); err != nil {
+		svc.rebalancing.Unlock()
+		log.Printf("[REBALANCE] Failed to record operation: %v", err)
+		return nil, err
+	}
+	log.Printf("[REBALANCE] Started operation %s: %s %s", op.Id, kind, node)
+
+	go func() {
+		defer svc.rebalancing.Unlock()
+		svc.completeOperation(op, svc.changeMembership(op, change))
+	}()
+	return op, nil
+}
+
+// resumeInterrupted finishes a rebalance found in the stored ring at
+// startup, unless a new operation gets to it first.
+func (svc *NetworkVideoContentService) resumeInterrupted() {
+	if !svc.rebalancing.TryLock() {
+		return
+	}
+	defer svc.rebalancing.Unlock()
+
+	leading, release, err := svc.leadMigrations(context.Background())
+	if err != nil {
+		log.Printf("[REBALANCE] Cannot resume interrupted rebalance: %v", err)
+		return
+	}
+	defer release()
+
+	if err := svc.finishInterrupted(leading); err != nil {
+		log.Printf("[REBALANCE] Failed to resume interrupted rebalance: %v", err)
+	}
+}
+
+// interruptedOperation returns the operation that started the
+// transitional ring, or a new "resume" operation if it was not recorded.
+func (svc *NetworkVideoContentService) interruptedOperation(id string, version int64) *RebalanceOperation {
+	if id != "" {
+		op, err := svc.ops.ReadOperation(id)
+		if err != nil {
+			log.Printf("[REBALANCE] Failed to read operation %s: %v", id, err)
+		}
+		if op != nil {
+			op.Status = OpRunning
+			return op
+		}
+	}
+
+	now := time.Now()
+	op := &RebalanceOperation{
+		Id:          newJobID(),
+		Kind:        "resume",
+		Status:      OpRunning,
+		RingVersion: version,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := svc.ops.CreateOperation(op); err != nil {
+		log.Printf("[REBALANCE] Failed to record operation: %v", err)
+	}
+	return op
+}
+
+// saveOperation persists op's progress. Failures are only logged: the
+// rebalance itself does not depend on them.
+func (svc *NetworkVideoContentService) saveOperation(op *RebalanceOperation) {
+	op.UpdatedAt = time.Now()
+	if err := svc.ops.UpdateOperation(op); err != nil {
+		log.Printf("[REBALANCE] Failed to save operation %s: %v", op.Id, err)
+	}
+}
+
+// completeOperation records how op ended. It only counts as done if every
+// file reached its new owners; otherwise the transitional ring is still in
+// use and the operation failed.
+func (svc *NetworkVideoContentService) completeOperation(op *RebalanceOperation, err error) {
+	op.Status = OpDone
+	if err == nil && op.FilesFailed > 0 {
+		err = fmt.Errorf("%d files could not be copied to their new owners", op.FilesFailed)
+	}
+	if err != nil {
+		op.Status = OpFailed
+		op.Error = err.Error()
+	}
+	svc.saveOperation(op)
+	log.Printf("[REBALANCE] Operation %s %s: moved %d/%d files (%d bytes), %d failed",
+		op.Id, op.Status, op.FilesMoved, op.FilesTotal, op.BytesMoved, op.FilesFailed)
+}
+
+func (svc *NetworkVideoContentService) readOperation(id string) (*RebalanceOperation, error) {
+	if !isHexID(id) {
+		return nil, status.Error(codes.InvalidArgument, "invalid operation ID")
+	}
+	op, err := svc.ops.ReadOperation(id)
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		return nil, status.Error(codes.NotFound, "operation not found")
+	}
+	return op, nil
+}
+
+func (svc *NetworkVideoContentService) GetOperation(ctx context.Context, req *proto.GetOperationRequest) (*proto.Operation, error) {
+	op, err := svc.readOperation(req.OperationId)
+	if err != nil {
+		return nil, err
+	}
+	return operationProto(op), nil
+}
+
+func (svc *NetworkVideoContentService) WatchOperation(req *proto.GetOperationRequest, stream proto.VideoContentAdminService_WatchOperationServer) error {
+	ticker := time.NewTicker(operationPollInterval)
+	defer ticker.Stop()
+
+	var last *RebalanceOperation
+	for {
+		op, err := svc.readOperation(req.OperationId)
+		if err != nil {
+			return err
+		}
+		if last == nil || *op != *last {
+			if err := stream.Send(operationProto(op)); err != nil {
+				return err
+			}
+			last = op
+		}
+		if op.Status != OpRunning {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func operationProto(op *RebalanceOperation) *proto.Operation {
+	return &proto.Operation{
+		Id:          op.Id,
+		Kind:        op.Kind,
+		NodeAddress: op.Node,
+		Weight:      int32(op.Weight),
+		Status:      op.Status,
+		Error:       op.Error,
+		RingVersion: op.RingVersion,
+		FilesTotal:  op.FilesTotal,
+		FilesMoved:  op.FilesMoved,
+		BytesMoved:  op.BytesMoved,
+		FilesFailed: op.FilesFailed,
+		CreatedUnix: op.CreatedAt.Unix(),
+		UpdatedUnix: op.UpdatedAt.Unix(),
+	}
+}
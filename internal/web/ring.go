@@ -0,0 +1,123 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hashRing is an immutable consistent hash ring. Each node owns
+// vnodes*weight tokens on the ring, so nodes with a larger weight take a
+// proportionally larger share of keys. Mutations return a new ring so
+// callers can compute the layout before and after a membership change and
+// diff the two.
+type hashRing struct {
+	vnodes     int
+	weights    map[string]int
+	hashes     []uint64
+	hashToNode map[uint64]string
+}
+
+func newHashRing(vnodes int, weights map[string]int) *hashRing {
+	r := &hashRing{
+		vnodes:     vnodes,
+		weights:    weights,
+		hashToNode: make(map[uint64]string),
+	}
+	for addr, weight := range weights {
+		for i := 0; i < vnodes*weight; i++ {
+			hash := hashStringToUint64(tokenKey(addr, i))
+			r.hashToNode[hash] = addr
+			r.hashes = append(r.hashes, hash)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+	return r
+}
+
+// tokenKey names the i-th token of a node. The first token hashes the bare
+// address so a ring with one virtual node per node matches the original
+// single-point layout.
+func tokenKey(addr string, i int) string {
+	if i == 0 {
+		return addr
+	}
+	return fmt.Sprintf("%s#%d", addr, i)
+}
+
+// parseNodeSpec splits an "addr" or "addr=weight" node spec.
+func parseNodeSpec(spec string) (string, int, error) {
+	addr, weightStr, found := strings.Cut(spec, "=")
+	if !found {
+		return addr, 1, nil
+	}
+	weight, err := strconv.Atoi(weightStr)
+	if err != nil || weight < 1 {
+		return "", 0, fmt.Errorf("invalid weight in node spec %q", spec)
+	}
+	return addr, weight, nil
+}
+
+func (r *hashRing) add(addr string, weight int) *hashRing {
+	weights := make(map[string]int)
+	for a, w := range r.weights {
+		weights[a] = w
+	}
+	weights[addr] = weight
+	return newHashRing(r.vnodes, weights)
+}
+
+func (r *hashRing) remove(addr string) *hashRing {
+	weights := make(map[string]int)
+	for a, w := range r.weights {
+		if a != addr {
+			weights[a] = w
+		}
+	}
+	return newHashRing(r.vnodes, weights)
+}
+
+func (r *hashRing) contains(addr string) bool {
+	_, ok := r.weights[addr]
+	return ok
+}
+
+func (r *hashRing) size() int {
+	return len(r.weights)
+}
+
+// nodes returns the node addresses in sorted order.
+func (r *hashRing) nodes() []string {
+	var addrs []string
+	for addr := range r.weights {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// owners returns the first n distinct nodes at or after the key's hash,
+// walking clockwise. The first entry is the primary.
+func (r *hashRing) owners(key string, n int) []string {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+	hash := hashStringToUint64(key)
+	idx := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+
+	var owners []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(r.hashes) && len(owners) < n; i++ {
+		addr := r.hashToNode[r.hashes[(idx+i)%len(r.hashes)]]
+		if !seen[addr] {
+			seen[addr] = true
+			owners = append(owners, addr)
+		}
+	}
+	return owners
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("video%d/chunk-%05d.m4s", i/10, i%10)
+	}
+	return keys
+}
+
+func TestRingOwnersAreDistinct(t *testing.T) {
+	ring := newHashRing(16, map[string]int{"a:1": 1, "b:1": 2, "c:1": 1})
+
+	for _, replicas := range []int{1, 2, 3, 5} {
+		want := min(replicas, ring.size())
+		for _, key := range testKeys(500) {
+			owners := ring.owners(key, replicas)
+			if len(owners) != want {
+				t.Fatalf("owners(%q, %d) = %v, want %d nodes", key, replicas, owners, want)
+			}
+			seen := make(map[string]bool)
+			for _, addr := range owners {
+				if seen[addr] {
+					t.Fatalf("owners(%q, %d) = %v repeats %s", key, replicas, owners, addr)
+				}
+				seen[addr] = true
+			}
+		}
+	}
+}
+
+func TestRingOwnersEmpty(t *testing.T) {
+	ring := newHashRing(4, map[string]int{})
+	if owners := ring.owners("x/y", 2); owners != nil {
+		t.Fatalf("owners on empty ring = %v, want nil", owners)
+	}
+}
+
+func TestRingWeightProportionality(t *testing.T) {
+	weights := map[string]int{"a:1": 1, "b:1": 2, "c:1": 4}
+	ring := newHashRing(200, weights)
+
+	keys := testKeys(50000)
+	counts := make(map[string]int)
+	for _, key := range keys {
+		counts[ring.owners(key, 1)[0]]++
+	}
+
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	for addr, w := range weights {
+		want := float64(w) / float64(total)
+		got := float64(counts[addr]) / float64(len(keys))
+		if math.Abs(got-want) > 0.05 {
+			t.Errorf("%s (weight %d) owns %.3f of keys, want about %.3f", addr, w, got, want)
+		}
+	}
+}
+
+func TestRingMinimalMovementOnAdd(t *testing.T) {
+	old := newHashRing(32, map[string]int{"a:1": 1, "b:1": 1, "c:1": 1})
+	added := old.add("d:1", 1)
+
+	for _, replicas := range []int{1, 2} {
+		moved := 0
+		keys := testKeys(5000)
+		for _, key := range keys {
+			before := old.owners(key, replicas)
+			for _, addr := range added.owners(key, replicas) {
+				if addr != "d:1" && !slices.Contains(before, addr) {
+					t.Fatalf("key %q gained %s, only the new node may gain keys", key, addr)
+				}
+				if addr == "d:1" {
+					moved++
+				}
+			}
+		}
+		// d:1 should take about a quarter of each replica slot.
+		share := float64(moved) / float64(len(keys)*replicas)
+		if share < 0.1 || share > 0.4 {
+			t.Errorf("replicas=%d: new node took %.3f of placements, want about 0.25", replicas, share)
+		}
+	}
+}
+
+func TestRingMinimalMovementOnRemove(t *testing.T) {
+	old := newHashRing(32, map[string]int{"a:1": 1, "b:1": 1, "c:1": 1, "d:1": 1})
+	removed := old.remove("b:1")
+
+	for _, replicas := range []int{1, 2} {
+		for _, key := range testKeys(5000) {
+			before := old.owners(key, replicas)
+			after := removed.owners(key, replicas)
+			if !slices.Contains(before, "b:1") {
+				if fmt.Sprint(before) != fmt.Sprint(after) {
+					t.Fatalf("key %q did not live on b:1 but moved from %v to %v", key, before, after)
+				}
+				continue
+			}
+			for _, addr := range before {
+				if addr != "b:1" && !slices.Contains(after, addr) {
+					t.Fatalf("key %q lost surviving owner %s: %v -> %v", key, addr, before, after)
+				}
+			}
+		}
+	}
+}
+
+func TestParseNodeSpec(t *testing.T) {
+	tests := []struct {
+		spec   string
+		addr   string
+		weight int
+		ok     bool
+	}{
+		{"localhost:8090", "localhost:8090", 1, true},
+		{"localhost:8090=3", "localhost:8090", 3, true},
+		{"localhost:8090=0", "", 0, false},
+		{"localhost:8090=x", "", 0, false},
+	}
+	for _, tt := range tests {
+		addr, weight, err := parseNodeSpec(tt.spec)
+		if (err == nil) != tt.ok || addr != tt.addr || weight != tt.weight {
+			t.Errorf("parseNodeSpec(%q) = %q, %d, %v", tt.spec, addr, weight, err)
+		}
+	}
+}
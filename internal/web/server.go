@@ -4,17 +4,29 @@ package web
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"tritontube/internal/contentkey"
+	"tritontube/internal/videoid"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
@@ -23,6 +35,19 @@ type server struct {
 
 	metadataService VideoMetadataService
 	contentService  VideoContentService
+	jobService      JobService
+
+	spoolDir      string
+	transcoder    Transcoder
+	uploads       *uploadStore
+	maxUploadSize int64
+
+	// jobQueue feeds the transcode workers, which workers tracks. It is
+	// nil while they are stopped; jobsMu keeps it from being closed
+	// under a sender.
+	jobsMu   sync.RWMutex
+	jobQueue chan string
+	workers  sync.WaitGroup
 
 	mux *http.ServeMux
 }
@@ -30,54 +55,142 @@ type server struct {
 type indexData struct {
 	Id         string
 	EscapedID  string
+	Title      string
 	UploadTime time.Time
+	Duration   string
+	Resolution string
+	Status     string
+}
+
+type indexPage struct {
+	Videos []indexData
+	// Refresh reloads the page while videos are still processing.
+	Refresh bool
+
+	Search       string
+	SearchPrefix bool
+	Sort         string
+	// NextURL and FirstURL link to the next and first page of the same
+	// listing; each is empty when it would point at the current page.
+	NextURL  string
+	FirstURL string
 }
 
 type VideoData struct {
-	Id         string
-	UploadedAt time.Time
+	Id            string
+	EscapedID     string
+	Title         string
+	Description   string
+	Uploader      string
+	UploadedAt    time.Time
+	Duration      string
+	Resolution    string
+	Codec         string
+	FileSize      string
+	SegmentCount  int
+	Tags          []string
+	Status        string
+	HasHLS        bool
+	HasPoster     bool
+	HasThumbnails bool
 }
 
 func NewServer(
 	metadataService VideoMetadataService,
 	contentService VideoContentService,
+	jobService JobService,
 ) *server {
 	return &server{
 		metadataService: metadataService,
 		contentService:  contentService,
+		jobService:      jobService,
+		transcoder:      NewFFmpegTranscoder(DefaultLadder, false),
+		maxUploadSize:   DefaultMaxUploadSize,
 	}
 }
 
+// DefaultMaxUploadSize caps an uploaded source file unless
+// SetMaxUploadSize changes it.
+const DefaultMaxUploadSize = 10 << 30
+
+// errUploadTooLarge is returned for uploads over the maximum size.
+var errUploadTooLarge = errors.New("upload exceeds the maximum size")
+
+// SetMaxUploadSize limits the size of an upload, whether sent in one
+// request or as a resumable session.
+func (s *server) SetMaxUploadSize(n int64) {
+	s.maxUploadSize = n
+}
+
+// isTooLarge reports whether err came from reading past an
+// http.MaxBytesReader limit.
+func isTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr) || errors.Is(err, errUploadTooLarge)
+}
+
 func (s *server) Start(lis net.Listener) error {
 	s.mux = http.NewServeMux()
 	s.mux.HandleFunc("/upload", s.handleUpload)
 	s.mux.HandleFunc("/videos/", s.handleVideo)
 	s.mux.HandleFunc("/content/", s.handleVideoContent)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
+	s.mux.HandleFunc("/api/v1/", s.handleAPI)
 	s.mux.HandleFunc("/", s.handleIndex)
 
 	return http.Serve(lis, s.mux)
 }
 
 func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	data, err := s.metadataService.List()
+	opts, err := listOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := s.metadataService.List(opts)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "unable to list videos", http.StatusInternalServerError)
 		return
 	}
 
 	var video_metas []indexData
-	for _, meta := range data {
+	refresh := false
+	for _, meta := range data.Videos {
+		if meta.Status == VideoProcessing {
+			refresh = true
+		}
 		video_metas = append(video_metas, indexData{
 			Id:         meta.Id,
 			EscapedID:  url.PathEscape(meta.Id),
+			Title:      displayTitle(&meta),
 			UploadTime: meta.UploadedAt,
+			Duration:   formatDuration(meta.Duration),
+			Resolution: formatResolution(meta.Width, meta.Height),
+			Status:     meta.Status,
 		})
 	}
 
 	indexTemplate := template.Must(template.New("index").Parse(indexHTML))
 
 	var buf bytes.Buffer
-	if err := indexTemplate.Execute(&buf, video_metas); err != nil {
+	page := indexPage{
+		Videos:       video_metas,
+		Refresh:      refresh,
+		Search:       opts.Search,
+		SearchPrefix: opts.SearchPrefix,
+		Sort:         opts.Sort,
+	}
+	if data.NextCursor != "" {
+		page.NextURL = "/?" + pageQuery(opts, data.NextCursor)
+	}
+	if opts.Cursor != "" {
+		page.FirstURL = "/?" + pageQuery(opts, "")
+	}
+	if err := indexTemplate.Execute(&buf, page); err != nil {
 		http.Error(w, "template error", http.StatusInternalServerError)
 		return
 	}
@@ -86,8 +199,16 @@ func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+// handleUpload takes the whole file in one multipart request, as the
+// HTML form sends it. It is not resumable; clients that need to survive a
+// dropped connection use the sessions under /api/v1/uploads instead.
 func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
 	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		if isTooLarge(err) {
+			http.Error(w, errUploadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "failed to parse multi-part form", http.StatusBadRequest)
 		return
 	}
@@ -104,81 +225,292 @@ func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	videoID := strings.TrimSuffix(handler.Filename, ".mp4")
+	_, job, err := s.queueUpload(file, uploadFormFields(handler.Filename, r.FormValue))
+	if errors.Is(err, errUploadTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	exists, _ := s.metadataService.Read(videoID)
-	if exists != nil {
-		http.Error(w, "video ID already exists", http.StatusConflict)
+	w.Header().Set("X-Job-Id", job.Id)
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
+	w.Header().Set("Location", "/jobs/"+job.Id)
+	writeJSON(w, http.StatusAccepted, jobResponse(job))
+}
+
+// uploadFields is the user-supplied metadata sent with an upload.
+type uploadFields struct {
+	Title       string
+	Description string
+	Uploader    string
+	Tags        []string
+}
+
+// uploadFormFields reads uploadFields from form or query values. The title
+// defaults to the uploaded file's name without its extension.
+func uploadFormFields(filename string, value func(string) string) uploadFields {
+	fields := uploadFields{
+		Title:       strings.TrimSpace(value("title")),
+		Description: strings.TrimSpace(value("description")),
+		Uploader:    strings.TrimSpace(value("uploader")),
+		Tags:        parseTags(value("tags")),
+	}
+	if fields.Title == "" && filename != "" {
+		fields.Title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if fields.Title == "" {
+		fields.Title = "Untitled"
+	}
+	return fields
+}
 
-	tempDir, err := os.MkdirTemp("", "upload-*")
+// queueUpload spools src, records the new video as processing and queues
+// its transcode job. The returned error is safe to show to clients.
+func (s *server) queueUpload(src io.Reader, fields uploadFields) (*VideoMetadata, *TranscodeJob, error) {
+	job, err := s.newTranscodeJob()
 	if err != nil {
-		http.Error(w, "cannot create temp dir", http.StatusInternalServerError)
-		return
+		return nil, nil, err
 	}
-	defer os.RemoveAll(tempDir)
 
-	mp4Path := filepath.Join(tempDir, handler.Filename)
-	outFile, err := os.Create(mp4Path)
+	outFile, err := os.Create(job.SourcePath)
 	if err != nil {
-		http.Error(w, "cannot save file", http.StatusInternalServerError)
-		return
+		return nil, nil, fmt.Errorf("cannot save file")
+	}
+	// One byte past the limit is enough to tell the upload is too large.
+	n, err := io.Copy(outFile, io.LimitReader(src, s.maxUploadSize+1))
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > s.maxUploadSize {
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(job.SourcePath)
+		if isTooLarge(err) {
+			return nil, nil, errUploadTooLarge
+		}
+		return nil, nil, fmt.Errorf("cannot save file")
 	}
-	defer outFile.Close()
-	io.Copy(outFile, file)
 
-	manifestPath := filepath.Join(tempDir, "manifest.mpd")
-	cmd := exec.Command("ffmpeg",
-		"-i", mp4Path,
-		"-c:v", "libx264",
-		"-c:a", "aac",
-		"-bf", "1",
-		"-keyint_min", "120",
-		"-g", "120",
-		"-sc_threshold", "0",
-		"-b:v", "3000k",
-		"-b:a", "128k",
-		"-f", "dash",
-		"-use_timeline", "1",
-		"-use_template", "1",
-		"-init_seg_name", "init-$RepresentationID$.m4s",
-		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.m4s",
-		"-seg_duration", "4",
-		manifestPath,
-	)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		log.Println("FFmpeg error:", string(output))
-		http.Error(w, "ffmpeg failed", http.StatusInternalServerError)
-		return
+	meta, queued, err := s.submitJob(job, fields)
+	if err != nil {
+		os.Remove(job.SourcePath)
+	}
+	return meta, queued, err
+}
+
+// queueUploadFile is queueUpload for a file already on the spool
+// filesystem, which is moved rather than copied. If the job cannot be
+// recorded the file is moved back, so a resumable upload can simply be
+// finalized again.
+func (s *server) queueUploadFile(path string, fields uploadFields) (*VideoMetadata, *TranscodeJob, error) {
+	job, err := s.newTranscodeJob()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.Rename(path, job.SourcePath); err != nil {
+		log.Printf("[UPLOAD] Failed to spool %s: %v", path, err)
+		return nil, nil, fmt.Errorf("cannot save file")
 	}
 
-	err = filepath.Walk(tempDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return err
+	meta, queued, err := s.submitJob(job, fields)
+	if err != nil {
+		if renameErr := os.Rename(job.SourcePath, path); renameErr != nil {
+			log.Printf("[UPLOAD] Failed to return %s to %s: %v", job.SourcePath, path, renameErr)
 		}
-		data, readErr := os.ReadFile(path)
-		if readErr != nil {
-			return readErr
+	}
+	return meta, queued, err
+}
+
+// newTranscodeJob returns a queued job for a new video, spooled under
+// spoolDir. It fails if the transcode workers have not been started.
+func (s *server) newTranscodeJob() (*TranscodeJob, error) {
+	if !s.workersRunning() {
+		return nil, errNoTranscodeWorkers
+	}
+	job := &TranscodeJob{
+		Id:        newJobID(),
+		VideoId:   videoid.New(),
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	job.SourcePath = filepath.Join(s.spoolDir, job.Id+".mp4")
+	return job, nil
+}
+
+// submitJob records the video as processing and queues its spooled job.
+// On failure the spooled source is left for the caller to dispose of.
+func (s *server) submitJob(job *TranscodeJob, fields uploadFields) (*VideoMetadata, *TranscodeJob, error) {
+	videoID := job.VideoId
+	meta := &VideoMetadata{
+		Id:          videoID,
+		UploadedAt:  time.Now(),
+		Title:       fields.Title,
+		Description: fields.Description,
+		Uploader:    fields.Uploader,
+		Tags:        fields.Tags,
+		Status:      VideoProcessing,
+	}
+	if err := s.metadataService.Create(meta); err != nil {
+		log.Printf("[UPLOAD] Failed to store metadata for %s: %v", videoID, err)
+		return nil, nil, fmt.Errorf("failed to store metadata")
+	}
+
+	if err := s.jobService.CreateJob(job); err != nil {
+		log.Printf("[UPLOAD] Failed to create job for %s: %v", videoID, err)
+		s.metadataService.Delete(videoID)
+		return nil, nil, fmt.Errorf("failed to create job")
+	}
+	if err := s.enqueueJob(job.Id); err != nil {
+		s.setJobStatus(job, JobCancelled, err.Error())
+		s.metadataService.Delete(videoID)
+		return nil, nil, err
+	}
+	log.Printf("[UPLOAD] Queued job %s for video %s", job.Id, videoID)
+	return meta, job, nil
+}
+
+// parseTags splits a comma-separated tag list, dropping blanks and
+// duplicates.
+func parseTags(raw string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
 		}
-		return s.contentService.Write(videoID, filepath.Base(path), data)
-	})
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
 
+func displayTitle(meta *VideoMetadata) string {
+	if meta.Title != "" {
+		return meta.Title
+	}
+	return meta.Id
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	secs := int(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+func formatResolution(width, height int) string {
+	if width == 0 || height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+type jobJSON struct {
+	Id        string    `json:"id"`
+	VideoId   string    `json:"videoId"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func jobResponse(job *TranscodeJob) jobJSON {
+	return jobJSON{
+		Id:        job.Id,
+		VideoId:   job.VideoId,
+		Status:    job.Status,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	jobId := r.URL.Path[len("/jobs/"):]
+	if !isHexID(jobId) {
+		http.Error(w, "invalid job ID", http.StatusBadRequest)
+		return
+	}
+	job, err := s.jobService.ReadJob(jobId)
 	if err != nil {
-		http.Error(w, "failed to store content", http.StatusInternalServerError)
+		http.Error(w, "failed to fetch job", http.StatusInternalServerError)
 		return
 	}
-
-	if err := s.metadataService.Create(videoID, time.Now()); err != nil {
-		http.Error(w, "failed to store metadata", http.StatusInternalServerError)
+	if job == nil {
+		http.NotFound(w, r)
 		return
 	}
+	writeJSON(w, http.StatusOK, jobResponse(job))
+}
+
+// storeFile streams the local file at path into the content service.
+func (s *server) storeFile(videoId, filename, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	dst, err := s.contentService.Create(videoId, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
 }
 
 func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
 	videoId := r.URL.Path[len("/videos/"):]
+	if !videoid.Valid(videoId) {
+		http.Error(w, "invalid video ID", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodDelete:
+		s.handleVideoDelete(w, r, videoId)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	metaData, err := s.metadataService.Read(videoId)
 	if err != nil {
 		http.Error(w, "failed to fetch metadata", http.StatusInternalServerError)
@@ -190,8 +522,29 @@ func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := VideoData{
-		Id:         metaData.Id,
-		UploadedAt: metaData.UploadedAt,
+		Id:           metaData.Id,
+		EscapedID:    url.PathEscape(metaData.Id),
+		Title:        displayTitle(metaData),
+		Description:  metaData.Description,
+		Uploader:     metaData.Uploader,
+		UploadedAt:   metaData.UploadedAt,
+		Duration:     formatDuration(metaData.Duration),
+		Resolution:   formatResolution(metaData.Width, metaData.Height),
+		Codec:        metaData.Codec,
+		SegmentCount: metaData.SegmentCount,
+		Tags:         metaData.Tags,
+		Status:       metaData.Status,
+	}
+	if metaData.FileSize > 0 {
+		data.FileSize = formatSize(metaData.FileSize)
+	}
+	if metaData.Status == VideoReady {
+		_, hlsErr := s.contentService.Stat(metaData.Id, hlsMasterPlaylist)
+		data.HasHLS = hlsErr == nil
+		_, posterErr := s.contentService.Stat(metaData.Id, posterFile)
+		data.HasPoster = posterErr == nil
+		_, thumbsErr := s.contentService.Stat(metaData.Id, thumbnailTrack)
+		data.HasThumbnails = thumbsErr == nil
 	}
 
 	var buf bytes.Buffer
@@ -205,6 +558,41 @@ func (s *server) handleVideo(w http.ResponseWriter, r *http.Request) {
 	w.Write(buf.Bytes())
 }
 
+func (s *server) handleVideoDelete(w http.ResponseWriter, r *http.Request, videoId string) {
+	metaData, err := s.metadataService.Read(videoId)
+	if err != nil {
+		http.Error(w, "failed to fetch metadata", http.StatusInternalServerError)
+		return
+	}
+	if metaData == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := s.DeleteVideo(videoId); err != nil {
+		http.Error(w, "failed to delete video", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteVideo removes a video's content and then its metadata. Content
+// goes first so a partial failure leaves the video listed and the delete
+// can simply be retried, instead of orphaning segments on storage nodes.
+func (s *server) DeleteVideo(videoId string) error {
+	if err := s.contentService.Delete(videoId); err != nil {
+		log.Printf("[DELETE] Content delete for %s incomplete: %v", videoId, err)
+		return err
+	}
+	if err := s.metadataService.Delete(videoId); err != nil {
+		log.Printf("[DELETE] Metadata delete for %s failed: %v", videoId, err)
+		return err
+	}
+	s.removeJobSources(videoId)
+	log.Printf("[DELETE] Deleted video %s", videoId)
+	return nil
+}
+
 func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 	videoId := r.URL.Path[len("/content/"):]
 	parts := strings.Split(videoId, "/")
@@ -214,13 +602,191 @@ func (s *server) handleVideoContent(w http.ResponseWriter, r *http.Request) {
 	}
 	videoId = parts[0]
 	filename := parts[1]
+	if _, err := contentkey.New(videoId, filename); err != nil {
+		http.Error(w, "invalid content path", http.StatusBadRequest)
+		return
+	}
 
-	data, err := s.contentService.Read(videoId, filename)
+	pinned, err := s.pinContent(videoId, filename)
+	if isContentNotFound(err) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
+		log.Printf("[CONTENT] Stat %s/%s failed: %v", videoId, filename, err)
 		http.Error(w, "failed to read content", http.StatusInternalServerError)
 		return
 	}
+	info := pinned.Info()
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	content := &contentSeeker{open: pinned.OpenRange, size: info.Size}
+	content.start, content.end = singleRange(r.Header.Get("Range"), info.Size)
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType(filename))
+	if info.Sha256 != "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, info.Sha256))
+	} else {
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size))
+	}
+	http.ServeContent(w, r, filename, info.ModTime, content)
+}
+
+var contentTypes = map[string]string{
+	".mpd":  "application/dash+xml",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".vtt":  "text/vtt",
+	".jpg":  "image/jpeg",
+}
+
+func contentType(filename string) string {
+	ext := filepath.Ext(filename)
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// pinContent stats a file and returns reads of the same copy, so the
+// size, ETag and Last-Modified headers match the bytes served.
+func (s *server) pinContent(videoId, filename string) (PinnedContent, error) {
+	if p, ok := s.contentService.(ContentPinner); ok {
+		return p.Pin(videoId, filename)
+	}
+	info, err := s.contentService.Stat(videoId, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &statPinned{svc: s.contentService, videoId: videoId, filename: filename, info: info}, nil
+}
+
+// statPinned pins a file of a content service with a single copy.
+type statPinned struct {
+	svc      VideoContentService
+	videoId  string
+	filename string
+	info     *ContentInfo
+}
+
+func (p *statPinned) Info() *ContentInfo { return p.info }
+
+func (p *statPinned) OpenRange(offset, length int64) (io.ReadCloser, error) {
+	return p.svc.OpenRange(p.videoId, p.filename, offset, length)
+}
+
+// isContentNotFound reports whether err means the file does not exist,
+// from the local filesystem or a storage node.
+func isContentNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || status.Code(err) == codes.NotFound
+}
+
+// contentSeeker presents a stored file as an io.ReadSeeker for
+// http.ServeContent. Seeking is free; the next Read opens a ranged stream
+// at the current offset. A Read at start fetches only up to end, the
+// requested range; any other offset, or reading on past end, streams to
+// the end of the file. The last bytes of the file are only returned once
+// the stream has ended cleanly, so a whole-file digest mismatch fails the
+// response before http.ServeContent, which stops at the size, finishes it.
+type contentSeeker struct {
+	open       func(offset, length int64) (io.ReadCloser, error)
+	size       int64
+	offset     int64
+	start, end int64
+	r          io.ReadCloser
+}
+
+func (c *contentSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative seek offset")
+	}
+	if offset != c.offset {
+		c.Close()
+		c.offset = offset
+	}
+	return offset, nil
+}
+
+func (c *contentSeeker) Read(p []byte) (int, error) {
+	if c.offset >= c.size {
+		return 0, io.EOF
+	}
+	if c.r == nil {
+		var length int64
+		if c.offset == c.start && c.end < c.size {
+			length = c.end - c.offset
+		}
+		r, err := c.open(c.offset, length)
+		if err != nil {
+			return 0, err
+		}
+		c.r = r
+	}
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	if err == nil && c.offset == c.size {
+		var extra [1]byte
+		if _, endErr := c.r.Read(extra[:]); endErr != nil && endErr != io.EOF {
+			c.offset -= int64(n)
+			return 0, endErr
+		}
+	}
+	if err == io.EOF && c.offset < c.size {
+		// The requested range is done but the caller wants more.
+		c.Close()
+		err = nil
+	}
+	return n, err
+}
+
+// singleRange returns the bytes [start, end) a Range header asks for,
+// or 0 and size for no header, several ranges or one that is invalid,
+// which http.ServeContent handles on its own.
+func singleRange(header string, size int64) (start, end int64) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, size
+		}
+		return max(size-n, 0), size
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, size
+	}
+	if last == "" {
+		return start, size
+	}
+	end, err = strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, size
+	}
+	return start, min(end+1, size)
+}
+
+func (c *contentSeeker) Close() error {
+	if c.r == nil {
+		return nil
+	}
+	err := c.r.Close()
+	c.r = nil
+	return err
 }
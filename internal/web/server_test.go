@@ -0,0 +1,264 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"tritontube/internal/videoid"
+)
+
+// newContentServer returns a server over an FS content service holding
+// one 100-byte file, and that file's video ID.
+func newContentServer(t *testing.T) (*server, string) {
+	t.Helper()
+	content := NewFSVideoContentService(t.TempDir())
+	videoId := videoid.New()
+
+	w, err := content.Create(videoId, "manifest.mpd")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return &server{contentService: content}, videoId
+}
+
+func getContent(s *server, path, rangeHeader string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	rec := httptest.NewRecorder()
+	s.handleVideoContent(rec, req)
+	return rec
+}
+
+func TestVideoContentRanges(t *testing.T) {
+	s, videoId := newContentServer(t)
+	path := "/content/" + videoId + "/manifest.mpd"
+
+	tests := []struct {
+		name         string
+		rangeHeader  string
+		status       int
+		contentRange string
+		first, n     int
+	}{
+		{"full", "", http.StatusOK, "", 0, 100},
+		{"closed", "bytes=10-19", http.StatusPartialContent, "bytes 10-19/100", 10, 10},
+		{"open-ended", "bytes=90-", http.StatusPartialContent, "bytes 90-99/100", 90, 10},
+		{"suffix", "bytes=-5", http.StatusPartialContent, "bytes 95-99/100", 95, 5},
+		{"suffix longer than file", "bytes=-500", http.StatusPartialContent, "bytes 0-99/100", 0, 100},
+		{"end past file", "bytes=50-500", http.StatusPartialContent, "bytes 50-99/100", 50, 50},
+		{"unsatisfiable", "bytes=100-", http.StatusRequestedRangeNotSatisfiable, "bytes */100", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getContent(s, path, tt.rangeHeader)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if tt.status == http.StatusRequestedRangeNotSatisfiable {
+				return
+			}
+			body, _ := io.ReadAll(rec.Body)
+			if len(body) != tt.n {
+				t.Fatalf("got %d bytes, want %d", len(body), tt.n)
+			}
+			for i, b := range body {
+				if int(b) != tt.first+i {
+					t.Fatalf("byte %d = %d, want %d", i, b, tt.first+i)
+				}
+			}
+		})
+	}
+}
+
+// rangeRecorder records the ranges opened on a content service.
+type rangeRecorder struct {
+	VideoContentService
+	opened [][2]int64
+}
+
+func (r *rangeRecorder) OpenRange(videoId, filename string, offset, length int64) (io.ReadCloser, error) {
+	r.opened = append(r.opened, [2]int64{offset, length})
+	return r.VideoContentService.OpenRange(videoId, filename, offset, length)
+}
+
+func TestVideoContentFetchesOnlyRange(t *testing.T) {
+	s, videoId := newContentServer(t)
+	path := "/content/" + videoId + "/manifest.mpd"
+	rec := &rangeRecorder{VideoContentService: s.contentService}
+	s.contentService = rec
+
+	tests := []struct {
+		rangeHeader, ifRange string
+		n                    int
+		opened               [][2]int64
+	}{
+		{"", "", 100, [][2]int64{{0, 0}}},
+		{"bytes=10-19", "", 10, [][2]int64{{10, 10}}},
+		{"bytes=0-", "", 100, [][2]int64{{0, 0}}},
+		{"bytes=-5", "", 5, [][2]int64{{95, 0}}},
+		// A stale If-Range serves the whole file; the read goes on past
+		// the requested range.
+		{"bytes=0-9", `"stale"`, 100, [][2]int64{{0, 10}, {10, 0}}},
+	}
+	for _, tt := range tests {
+		rec.opened = nil
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		if tt.ifRange != "" {
+			req.Header.Set("If-Range", tt.ifRange)
+		}
+		w := httptest.NewRecorder()
+		s.handleVideoContent(w, req)
+		if w.Body.Len() != tt.n {
+			t.Errorf("Range %q If-Range %q: got %d bytes, want %d", tt.rangeHeader, tt.ifRange, w.Body.Len(), tt.n)
+		}
+		if !reflect.DeepEqual(rec.opened, tt.opened) {
+			t.Errorf("Range %q If-Range %q opened %v, want %v", tt.rangeHeader, tt.ifRange, rec.opened, tt.opened)
+		}
+	}
+}
+
+func TestContentSeekerWithholdsEndOnFailedCheck(t *testing.T) {
+	errMismatch := errors.New("checksum mismatch")
+	c := &contentSeeker{
+		open: func(offset, length int64) (io.ReadCloser, error) {
+			return io.NopCloser(io.MultiReader(strings.NewReader("0123456789"), iotest.ErrReader(errMismatch))), nil
+		},
+		size: 10,
+		end:  10,
+	}
+	got, err := io.ReadAll(io.LimitReader(c, 10))
+	if !errors.Is(err, errMismatch) || len(got) == 10 {
+		t.Fatalf("read %q, %v; want the mismatch before the last bytes", got, err)
+	}
+}
+
+func TestVideoContentNotFound(t *testing.T) {
+	s, videoId := newContentServer(t)
+
+	for _, path := range []string{
+		"/content/" + videoId + "/missing.m4s",
+		"/content/" + videoid.New() + "/manifest.mpd",
+	} {
+		if rec := getContent(s, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestLegacyVideoIDs(t *testing.T) {
+	const legacyID = "Team-Demo_v1.2"
+	contentDir := t.TempDir()
+	content := NewFSVideoContentService(contentDir)
+	metadata := newTestSQLite(t)
+	s := NewServer(metadata, content, metadata)
+
+	if err := metadata.Create(&VideoMetadata{Id: legacyID, UploadedAt: time.Now(), Status: VideoReady}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	w, err := content.Create(legacyID, "manifest.mpd")
+	if err != nil {
+		t.Fatalf("content Create: %v", err)
+	}
+	w.Write([]byte("<MPD/>"))
+	w.Close()
+
+	escaped := url.PathEscape(legacyID)
+	for _, tt := range []struct {
+		method, path string
+		handler      http.HandlerFunc
+		status       int
+	}{
+		{http.MethodGet, "/videos/" + escaped, s.handleVideo, http.StatusOK},
+		{http.MethodGet, "/api/v1/videos/" + escaped, s.handleAPI, http.StatusOK},
+		{http.MethodGet, "/content/" + escaped + "/manifest.mpd", s.handleVideoContent, http.StatusOK},
+		{http.MethodDelete, "/api/v1/videos/" + escaped, s.handleAPI, http.StatusNoContent},
+		{http.MethodGet, "/videos/" + escaped, s.handleVideo, http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, legacyID)); !os.IsNotExist(err) {
+		t.Fatalf("content of deleted legacy video remains: %v", err)
+	}
+}
+
+// failingDelete fails every content Delete.
+type failingDelete struct {
+	*FSVideoContentService
+}
+
+func (failingDelete) Delete(videoId string) error {
+	return errors.New("storage node unreachable")
+}
+
+func TestDeleteVideo(t *testing.T) {
+	contentDir := t.TempDir()
+	content := NewFSVideoContentService(contentDir)
+	metadata := newTestSQLite(t)
+	s := NewServer(metadata, content, metadata)
+	videoId := videoid.New()
+	if err := metadata.Create(&VideoMetadata{Id: videoId, UploadedAt: time.Now(), Status: VideoReady}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	w, _ := content.Create(videoId, "manifest.mpd")
+	w.Write([]byte("<MPD/>"))
+	w.Close()
+
+	deleteVideo := func() int {
+		rec := httptest.NewRecorder()
+		s.handleVideo(rec, httptest.NewRequest(http.MethodDelete, "/videos/"+videoId, nil))
+		return rec.Code
+	}
+
+	// A failed content delete keeps the video listed so it can be retried.
+	s.contentService = failingDelete{content}
+	if code := deleteVideo(); code != http.StatusInternalServerError {
+		t.Fatalf("DELETE with failing content = %d, want 500", code)
+	}
+	if meta, _ := metadata.Read(videoId); meta == nil {
+		t.Fatal("failed delete removed the metadata")
+	}
+
+	s.contentService = content
+	if code := deleteVideo(); code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want 204", code)
+	}
+	if meta, _ := metadata.Read(videoId); meta != nil {
+		t.Fatal("metadata remains after delete")
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, videoId)); !os.IsNotExist(err) {
+		t.Fatalf("content remains after delete: %v", err)
+	}
+	if code := deleteVideo(); code != http.StatusNotFound {
+		t.Fatalf("second DELETE = %d, want 404", code)
+	}
+}
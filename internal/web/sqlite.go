@@ -2,10 +2,14 @@ package web
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SQLiteVideoMetadataService struct {
@@ -13,33 +17,152 @@ type SQLiteVideoMetadataService struct {
 }
 
 var _ VideoMetadataService = (*SQLiteVideoMetadataService)(nil)
+var _ JobService = (*SQLiteVideoMetadataService)(nil)
+var _ RingStore = (*SQLiteVideoMetadataService)(nil)
+var _ OperationStore = (*SQLiteVideoMetadataService)(nil)
+var _ PendingDeleteStore = (*SQLiteVideoMetadataService)(nil)
 
-func NewSQLiteVideoMetadataService(dbpath string) (*SQLiteVideoMetadataService, error) {
-	db, err := sql.Open("sqlite3", dbpath)
-	if err != nil {
-		return nil, err
-	}
-
-	createStmt := `
+// migrations upgrade the schema in order; PRAGMA user_version records how
+// many have been applied. The first one is idempotent so databases created
+// before versioning existed upgrade cleanly.
+var migrations = []string{
+	`
 		CREATE TABLE IF NOT EXISTS videos (
 			ID TEXT PRIMARY KEY,
 			uploaded_at DATETIME NOT NULL
 		);
+		CREATE TABLE IF NOT EXISTS jobs (
+			ID TEXT PRIMARY KEY,
+			video_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			source_path TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+	`,
+	`
+		ALTER TABLE videos ADD COLUMN title TEXT NOT NULL DEFAULT '';
+		ALTER TABLE videos ADD COLUMN description TEXT NOT NULL DEFAULT '';
+		ALTER TABLE videos ADD COLUMN uploader TEXT NOT NULL DEFAULT '';
+		ALTER TABLE videos ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE videos ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE videos ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE videos ADD COLUMN codec TEXT NOT NULL DEFAULT '';
+		ALTER TABLE videos ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE videos ADD COLUMN segment_count INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE videos ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+		ALTER TABLE videos ADD COLUMN status TEXT NOT NULL DEFAULT 'ready';
+	`,
+	`
+		UPDATE videos SET title = ID WHERE title = '';
+		CREATE INDEX videos_by_uploaded_at ON videos (uploaded_at, ID);
+		CREATE INDEX videos_by_title ON videos (title COLLATE NOCASE, ID);
+	`,
+	`
+		CREATE TABLE ring (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			version INTEGER NOT NULL,
+			vnodes INTEGER NOT NULL,
+			weights TEXT NOT NULL
+		);
+	`,
+	`
+		ALTER TABLE ring ADD COLUMN next TEXT NOT NULL DEFAULT 'null';
+	`,
+	`
+		ALTER TABLE ring ADD COLUMN operation TEXT NOT NULL DEFAULT '';
+		CREATE TABLE operations (
+			ID TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			node TEXT NOT NULL,
+			weight INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			ring_version INTEGER NOT NULL,
+			files_total INTEGER NOT NULL DEFAULT 0,
+			files_moved INTEGER NOT NULL DEFAULT 0,
+			bytes_moved INTEGER NOT NULL DEFAULT 0,
+			files_failed INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+	`,
+	// Early rows hold "2006-01-02 15:04:05", which sorts before RFC3339
+	// values on the same day; List orders and pages on the raw text.
+	`
+		UPDATE videos SET uploaded_at = strftime('%Y-%m-%dT%H:%M:%SZ', uploaded_at)
+		WHERE uploaded_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]';
+	`,
 	`
+		CREATE TABLE pending_deletes (
+			node TEXT NOT NULL,
+			video_id TEXT NOT NULL,
+			PRIMARY KEY (node, video_id)
+		);
+	`,
+}
 
-	if _, err := db.Exec(createStmt); err != nil {
+func NewSQLiteVideoMetadataService(dbpath string) (*SQLiteVideoMetadataService, error) {
+	db, err := sql.Open("sqlite3", dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
 		return nil, err
 	}
 
 	return &SQLiteVideoMetadataService{db: db}, nil
 }
 
-func (s *SQLiteVideoMetadataService) Create(videoID string, uploaded_at time.Time) error {
-	_, err := s.db.Exec(`
-		INSERT INTO videos (ID, uploaded_at)
-		VALUES (?, ?)
-	`, videoID, uploaded_at.UTC().Format(time.RFC3339))
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
 
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("[SQLITE] Applied schema migration %d", i+1)
+	}
+	return nil
+}
+
+const videoColumns = `ID, uploaded_at, title, description, uploader, duration_ms, width, height,
+		codec, file_size, segment_count, tags, status`
+
+func (s *SQLiteVideoMetadataService) Create(meta *VideoMetadata) error {
+	tags, err := json.Marshal(nonNilTags(meta.Tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO videos (`+videoColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, meta.Id, meta.UploadedAt.UTC().Format(time.RFC3339), meta.Title, meta.Description, meta.Uploader,
+		meta.Duration.Milliseconds(), meta.Width, meta.Height, meta.Codec, meta.FileSize,
+		meta.SegmentCount, string(tags), meta.Status)
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return ErrVideoExists
+	}
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
@@ -50,20 +173,130 @@ func (s *SQLiteVideoMetadataService) Create(videoID string, uploaded_at time.Tim
 	return nil
 }
 
+func (s *SQLiteVideoMetadataService) Update(meta *VideoMetadata) error {
+	tags, err := json.Marshal(nonNilTags(meta.Tags))
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE videos SET title = ?, description = ?, uploader = ?, duration_ms = ?, width = ?,
+			height = ?, codec = ?, file_size = ?, segment_count = ?, tags = ?, status = ?
+		WHERE ID = ?
+	`, meta.Title, meta.Description, meta.Uploader, meta.Duration.Milliseconds(), meta.Width,
+		meta.Height, meta.Codec, meta.FileSize, meta.SegmentCount, string(tags), meta.Status, meta.Id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrVideoNotFound
+	}
+	return nil
+}
+
 func (s *SQLiteVideoMetadataService) Read(videoID string) (*VideoMetadata, error) {
 	row := s.db.QueryRow(`
-		SELECT ID, uploaded_at
+		SELECT `+videoColumns+`
 		FROM videos
 		WHERE ID = ?
 	`, videoID)
 
-	var ID string
-	var uploaded_at string
+	meta, err := scanVideo(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return meta, err
+}
+
+// List pages through videos with keyset pagination: the cursor holds the
+// sort column value and ID of the last row returned, so each page is an
+// index range scan no matter how deep it is.
+func (s *SQLiteVideoMetadataService) List(opts ListOptions) (*VideoPage, error) {
+	opts, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	column, order, cmp := "uploaded_at", "uploaded_at DESC, ID DESC", "<"
+	switch opts.Sort {
+	case SortOldest:
+		order, cmp = "uploaded_at, ID", ">"
+	case SortTitle:
+		column, order, cmp = "title COLLATE NOCASE", "title COLLATE NOCASE, ID", ">"
+	}
+
+	var where []string
+	var args []any
+	if opts.Search != "" {
+		pattern := escapeLike(opts.Search) + "%"
+		if !opts.SearchPrefix {
+			pattern = "%" + pattern
+		}
+		where = append(where, `title LIKE ? ESCAPE '\'`)
+		args = append(args, pattern)
+	}
+	if cursor != nil {
+		where = append(where, fmt.Sprintf(`(%[1]s %[2]s ? OR (%[1]s = ? AND ID %[2]s ?))`, column, cmp))
+		args = append(args, cursor.Key, cursor.Key, cursor.Id)
+	}
+
+	query := `SELECT ` + videoColumns + ` FROM videos`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY ` + order
+	if opts.Limit > 0 {
+		// One extra row tells us whether there is a next page.
+		query += fmt.Sprintf(` LIMIT %d`, opts.Limit+1)
+	}
 
-	if err := row.Scan(&ID, &uploaded_at); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &VideoPage{}
+	for rows.Next() {
+		meta, err := scanVideo(rows)
+		if err != nil {
+			return nil, err
 		}
+		page.Videos = append(page.Videos, *meta)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.Limit > 0 && len(page.Videos) > opts.Limit {
+		page.Videos = page.Videos[:opts.Limit]
+		last := page.Videos[len(page.Videos)-1]
+		key := last.UploadedAt.UTC().Format(time.RFC3339)
+		if opts.Sort == SortTitle {
+			key = last.Title
+		}
+		page.NextCursor = encodeCursor(listCursor{Sort: opts.Sort, Key: key, Id: last.Id})
+	}
+	return page, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func scanVideo(row interface{ Scan(...any) error }) (*VideoMetadata, error) {
+	var meta VideoMetadata
+	var uploaded_at, tags string
+	var duration_ms int64
+	if err := row.Scan(&meta.Id, &uploaded_at, &meta.Title, &meta.Description, &meta.Uploader,
+		&duration_ms, &meta.Width, &meta.Height, &meta.Codec, &meta.FileSize,
+		&meta.SegmentCount, &tags, &meta.Status); err != nil {
 		return nil, err
 	}
 
@@ -74,42 +307,224 @@ func (s *SQLiteVideoMetadataService) Read(videoID string) (*VideoMetadata, error
 			return nil, err
 		}
 	}
+	meta.UploadedAt = t
+	meta.Duration = time.Duration(duration_ms) * time.Millisecond
+
+	if err := json.Unmarshal([]byte(tags), &meta.Tags); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func (s *SQLiteVideoMetadataService) Delete(videoID string) error {
+	_, err := s.db.Exec(`DELETE FROM videos WHERE ID = ?`, videoID)
+	return err
+}
 
-	return &VideoMetadata{
-		Id:         ID,
-		UploadedAt: t,
-	}, nil
+func (s *SQLiteVideoMetadataService) CreateJob(job *TranscodeJob) error {
+	_, err := s.db.Exec(`
+		INSERT INTO jobs (ID, video_id, status, error, source_path, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, job.Id, job.VideoId, job.Status, job.Error, job.SourcePath,
+		job.CreatedAt.UTC().Format(time.RFC3339), job.UpdatedAt.UTC().Format(time.RFC3339))
+	return err
 }
 
-func (s *SQLiteVideoMetadataService) List() ([]VideoMetadata, error) {
-	rows, err := s.db.Query(`SELECT ID, uploaded_at FROM videos`)
+func (s *SQLiteVideoMetadataService) UpdateJob(job *TranscodeJob) error {
+	_, err := s.db.Exec(`
+		UPDATE jobs SET status = ?, error = ?, updated_at = ?
+		WHERE ID = ?
+	`, job.Status, job.Error, job.UpdatedAt.UTC().Format(time.RFC3339), job.Id)
+	return err
+}
+
+func (s *SQLiteVideoMetadataService) ReadJob(id string) (*TranscodeJob, error) {
+	row := s.db.QueryRow(`
+		SELECT ID, video_id, status, error, source_path, created_at, updated_at
+		FROM jobs
+		WHERE ID = ?
+	`, id)
+
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (s *SQLiteVideoMetadataService) ListJobs() ([]TranscodeJob, error) {
+	rows, err := s.db.Query(`
+		SELECT ID, video_id, status, error, source_path, created_at, updated_at
+		FROM jobs
+		ORDER BY created_at
+	`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var videos []VideoMetadata
+	var jobs []TranscodeJob
 	for rows.Next() {
-		var Id string
-		var uploaded_at string
-
-		if err := rows.Scan(&Id, &uploaded_at); err != nil {
+		job, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
 
-		t, err := time.Parse(time.RFC3339, uploaded_at)
-		if err != nil {
-			t, err = time.Parse("2006-01-02 15:04:05", uploaded_at)
-			if err != nil {
-				return nil, err
-			}
-		}
+func scanJob(row interface{ Scan(...any) error }) (*TranscodeJob, error) {
+	var job TranscodeJob
+	var created_at, updated_at string
+	if err := row.Scan(&job.Id, &job.VideoId, &job.Status, &job.Error, &job.SourcePath, &created_at, &updated_at); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if job.CreatedAt, err = time.Parse(time.RFC3339, created_at); err != nil {
+		return nil, err
+	}
+	if job.UpdatedAt, err = time.Parse(time.RFC3339, updated_at); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *SQLiteVideoMetadataService) LoadRing() (*RingState, error) {
+	var state RingState
+	var weights, next string
+	err := s.db.QueryRow(`SELECT version, vnodes, weights, next, operation FROM ring WHERE id = 1`).
+		Scan(&state.Version, &state.VNodes, &weights, &next, &state.Operation)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(weights), &state.Weights); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(next), &state.Next); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *SQLiteVideoMetadataService) SaveRing(state *RingState) error {
+	weights, err := json.Marshal(state.Weights)
+	if err != nil {
+		return err
+	}
+	next, err := json.Marshal(state.Next)
+	if err != nil {
+		return err
+	}
+
+	var res sql.Result
+	if state.Version == 1 {
+		res, err = s.db.Exec(`
+			INSERT INTO ring (id, version, vnodes, weights, next, operation) VALUES (1, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING
+		`, state.Version, state.VNodes, string(weights), string(next), state.Operation)
+	} else {
+		res, err = s.db.Exec(`
+			UPDATE ring SET version = ?, vnodes = ?, weights = ?, next = ?, operation = ?
+			WHERE id = 1 AND version = ?
+		`, state.Version, state.VNodes, string(weights), string(next), state.Operation, state.Version-1)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRingConflict
+	}
+	return nil
+}
+
+func (s *SQLiteVideoMetadataService) CreateOperation(op *RebalanceOperation) error {
+	_, err := s.db.Exec(`
+		INSERT INTO operations (ID, kind, node, weight, status, error, ring_version, files_total,
+			files_moved, bytes_moved, files_failed, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, op.Id, op.Kind, op.Node, op.Weight, op.Status, op.Error, op.RingVersion, op.FilesTotal,
+		op.FilesMoved, op.BytesMoved, op.FilesFailed,
+		op.CreatedAt.UTC().Format(time.RFC3339), op.UpdatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *SQLiteVideoMetadataService) UpdateOperation(op *RebalanceOperation) error {
+	_, err := s.db.Exec(`
+		UPDATE operations SET status = ?, error = ?, ring_version = ?, files_total = ?, files_moved = ?,
+			bytes_moved = ?, files_failed = ?, updated_at = ?
+		WHERE ID = ?
+	`, op.Status, op.Error, op.RingVersion, op.FilesTotal, op.FilesMoved, op.BytesMoved, op.FilesFailed,
+		op.UpdatedAt.UTC().Format(time.RFC3339), op.Id)
+	return err
+}
+
+func (s *SQLiteVideoMetadataService) ReadOperation(id string) (*RebalanceOperation, error) {
+	var op RebalanceOperation
+	var created_at, updated_at string
+	err := s.db.QueryRow(`
+		SELECT ID, kind, node, weight, status, error, ring_version, files_total, files_moved,
+			bytes_moved, files_failed, created_at, updated_at
+		FROM operations
+		WHERE ID = ?
+	`, id).Scan(&op.Id, &op.Kind, &op.Node, &op.Weight, &op.Status, &op.Error, &op.RingVersion,
+		&op.FilesTotal, &op.FilesMoved, &op.BytesMoved, &op.FilesFailed, &created_at, &updated_at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		videos = append(videos, VideoMetadata{
-			Id:         Id,
-			UploadedAt: t,
-		})
+	if op.CreatedAt, err = time.Parse(time.RFC3339, created_at); err != nil {
+		return nil, err
 	}
+	if op.UpdatedAt, err = time.Parse(time.RFC3339, updated_at); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (s *SQLiteVideoMetadataService) AddPendingDelete(d PendingDelete) error {
+	_, err := s.db.Exec(`
+		INSERT INTO pending_deletes (node, video_id) VALUES (?, ?)
+		ON CONFLICT (node, video_id) DO NOTHING
+	`, d.Node, d.VideoId)
+	return err
+}
 
-	return videos, nil
+func (s *SQLiteVideoMetadataService) RemovePendingDelete(d PendingDelete) error {
+	_, err := s.db.Exec(`DELETE FROM pending_deletes WHERE node = ? AND video_id = ?`, d.Node, d.VideoId)
+	return err
+}
+
+func (s *SQLiteVideoMetadataService) ListPendingDeletes() ([]PendingDelete, error) {
+	rows, err := s.db.Query(`SELECT node, video_id FROM pending_deletes ORDER BY node, video_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletes []PendingDelete
+	for rows.Next() {
+		var d PendingDelete
+		if err := rows.Scan(&d.Node, &d.VideoId); err != nil {
+			return nil, err
+		}
+		deletes = append(deletes, d)
+	}
+	return deletes, rows.Err()
 }
@@ -0,0 +1,205 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSQLite(t *testing.T) *SQLiteVideoMetadataService {
+	t.Helper()
+	s, err := NewSQLiteVideoMetadataService(filepath.Join(t.TempDir(), "metadata.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteVideoMetadataService: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+	return s
+}
+
+func TestSQLiteMigratesUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.db")
+
+	// The schema and a row as written before migrations existed.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploaded := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS videos (
+			ID TEXT PRIMARY KEY,
+			uploaded_at DATETIME NOT NULL
+		);
+		INSERT INTO videos (ID, uploaded_at) VALUES ('intro', ?);
+	`, uploaded.Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		s, err := NewSQLiteVideoMetadataService(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", attempt, err)
+		}
+		var version int
+		if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		if version != len(migrations) {
+			t.Fatalf("user_version = %d, want %d", version, len(migrations))
+		}
+
+		meta, err := s.Read("intro")
+		if err != nil || meta == nil {
+			t.Fatalf("Read(intro) = %v, %v", meta, err)
+		}
+		if meta.Title != "intro" || meta.Status != VideoReady || !meta.UploadedAt.Equal(uploaded) || len(meta.Tags) != 0 {
+			t.Fatalf("migrated video = %+v", meta)
+		}
+		page, err := s.List(ListOptions{Sort: SortTitle})
+		if err != nil || len(page.Videos) != 1 {
+			t.Fatalf("List = %v, %v", page, err)
+		}
+		if ring, err := s.LoadRing(); err != nil || ring != nil {
+			t.Fatalf("LoadRing = %v, %v; want no ring", ring, err)
+		}
+		s.db.Close()
+	}
+}
+
+func TestSQLitePagesMixedTimestampFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.db")
+
+	// Rows from one day, some in the legacy format and some in RFC3339.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS videos (
+			ID TEXT PRIMARY KEY,
+			uploaded_at DATETIME NOT NULL
+		);
+		INSERT INTO videos (ID, uploaded_at) VALUES
+			('a', '2024-03-01 12:00:00'),
+			('b', '2024-03-01T11:00:00Z'),
+			('c', '2024-03-01 10:00:00'),
+			('d', '2024-03-01T13:00:00Z');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewSQLiteVideoMetadataService(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.db.Close()
+
+	for sort, want := range map[string]string{
+		SortNewest: "d,a,b,c",
+		SortOldest: "c,b,a,d",
+	} {
+		for _, limit := range []int{0, 1, 3} {
+			opts := ListOptions{Sort: sort, Limit: limit}
+			var got []string
+			for pages := 0; pages < 5; pages++ {
+				page, err := s.List(opts)
+				if err != nil {
+					t.Fatalf("List(%+v): %v", opts, err)
+				}
+				got = append(got, videoIDs(page.Videos)...)
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			if strings.Join(got, ",") != want {
+				t.Errorf("%s limit %d: pages gave %v, want %s", sort, limit, got, want)
+			}
+		}
+	}
+}
+
+func TestSQLiteCreateRejectsDuplicateID(t *testing.T) {
+	s := newTestSQLite(t)
+	meta := &VideoMetadata{Id: "01JAAAAAAAAAAAAAAAAAAAAAAA", Title: "first", UploadedAt: time.Now(), Status: VideoReady}
+	if err := s.Create(meta); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	dup := *meta
+	dup.Title = "second"
+	if err := s.Create(&dup); !errors.Is(err, ErrVideoExists) {
+		t.Fatalf("duplicate Create = %v, want ErrVideoExists", err)
+	}
+	if got, _ := s.Read(meta.Id); got.Title != "first" {
+		t.Fatalf("duplicate Create overwrote title with %q", got.Title)
+	}
+}
+
+func TestSQLiteSaveRingRejectsStaleVersions(t *testing.T) {
+	s := newTestSQLite(t)
+	v1 := &RingState{Version: 1, VNodes: 16, Weights: map[string]int{"a:1": 1, "b:1": 1}}
+	if err := s.SaveRing(v1); err != nil {
+		t.Fatalf("SaveRing v1: %v", err)
+	}
+	// A second server seeding an empty store at the same time.
+	if err := s.SaveRing(&RingState{Version: 1, VNodes: 16, Weights: map[string]int{"c:1": 1}}); !errors.Is(err, ErrRingConflict) {
+		t.Fatalf("second v1 = %v, want ErrRingConflict", err)
+	}
+
+	v2 := &RingState{Version: 2, VNodes: 16, Weights: v1.Weights, Next: map[string]int{"a:1": 1}, Operation: "op1"}
+	if err := s.SaveRing(v2); err != nil {
+		t.Fatalf("SaveRing v2: %v", err)
+	}
+	// Both a writer still on v1 and one that skipped a version lose.
+	for _, version := range []int64{2, 4} {
+		stale := &RingState{Version: version, VNodes: 16, Weights: map[string]int{"c:1": 1}}
+		if err := s.SaveRing(stale); !errors.Is(err, ErrRingConflict) {
+			t.Fatalf("SaveRing version %d over v2 = %v, want ErrRingConflict", version, err)
+		}
+	}
+	if got, err := s.LoadRing(); err != nil || !reflect.DeepEqual(got, v2) {
+		t.Fatalf("LoadRing = %+v, %v; want %+v", got, err, v2)
+	}
+}
+
+func TestSQLiteRingSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.db")
+	s, err := NewSQLiteVideoMetadataService(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []*RingState{
+		{Version: 1, VNodes: 32, Weights: map[string]int{"a:1": 1, "b:1": 2}},
+		{Version: 2, VNodes: 32, Weights: map[string]int{"a:1": 1, "b:1": 2}, Next: map[string]int{"b:1": 2}, Operation: "op1"},
+	}
+	for _, state := range states {
+		if err := s.SaveRing(state); err != nil {
+			t.Fatalf("SaveRing v%d: %v", state.Version, err)
+		}
+	}
+	s.db.Close()
+
+	s, err = NewSQLiteVideoMetadataService(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.db.Close()
+	got, err := s.LoadRing()
+	if err != nil || !reflect.DeepEqual(got, states[1]) {
+		t.Fatalf("LoadRing after restart = %+v, %v; want %+v", got, err, states[1])
+	}
+	// The reopened store continues from the stored version.
+	finished := &RingState{Version: 3, VNodes: 32, Weights: map[string]int{"b:1": 2}}
+	if err := s.SaveRing(finished); err != nil {
+		t.Fatalf("SaveRing v3 after restart: %v", err)
+	}
+	if got, _ := s.LoadRing(); got.Next != nil || got.Operation != "" || !reflect.DeepEqual(got.Weights, finished.Weights) {
+		t.Fatalf("LoadRing = %+v, want %+v", got, finished)
+	}
+}
@@ -7,6 +7,7 @@ const indexHTML = `
   <head>
     <meta charset="UTF-8" />
     <title>TritonTube</title>
+    {{if .Refresh}}<meta http-equiv="refresh" content="5" />{{end}}
 
     <!-- JetBrains Mono font import -->
     <link href="https://fonts.googleapis.com/css2?family=JetBrains+Mono&display=swap" rel="stylesheet" />
@@ -100,6 +101,18 @@ const indexHTML = `
         background: var(--primary-dark);
       }
 
+      input[type="text"],
+      input[type="search"],
+      textarea {
+        flex: 1 1 100%;
+        padding: 8px 12px;
+        background: var(--background);
+        color: var(--text);
+        border: 1px solid var(--border-color);
+        border-radius: 8px;
+        font-family: var(--code-font);
+      }
+
       input[type="submit"] {
         background: var(--accent);
         color: #fff;
@@ -123,6 +136,9 @@ const indexHTML = `
       }
 
       li {
+        display: flex;
+        justify-content: space-between;
+        align-items: center;
         background: var(--card-bg);
         padding: 12px 16px;
         margin-bottom: 10px;
@@ -146,6 +162,77 @@ const indexHTML = `
       a:hover {
         color: var(--accent-dark);
       }
+
+      form.search {
+        margin-top: 10px;
+        align-items: center;
+      }
+
+      form.search input[type="search"] {
+        flex: 1 1 200px;
+      }
+
+      select {
+        padding: 8px 12px;
+        background: var(--background);
+        color: var(--text);
+        border: 1px solid var(--border-color);
+        border-radius: 8px;
+        font-family: var(--code-font);
+      }
+
+      nav.pages {
+        display: flex;
+        justify-content: space-between;
+      }
+
+      a.thumb {
+        flex: 0 0 128px;
+        height: 72px;
+        margin-right: 16px;
+        border-radius: 6px;
+        background: var(--background);
+        overflow: hidden;
+      }
+
+      a.thumb img {
+        width: 100%;
+        height: 100%;
+        object-fit: cover;
+      }
+
+      .entry {
+        flex: 1;
+      }
+
+      .meta {
+        display: block;
+        font-size: 0.8em;
+        color: #aaa;
+      }
+
+      .status-processing {
+        color: var(--primary);
+      }
+
+      .status-failed {
+        color: #e74c3c;
+      }
+
+      button.delete {
+        background: transparent;
+        color: #e74c3c;
+        border: 1px solid #e74c3c;
+        padding: 4px 12px;
+        border-radius: 999px;
+        cursor: pointer;
+        font-family: var(--code-font);
+      }
+
+      button.delete:hover {
+        background: #e74c3c;
+        color: #fff;
+      }
     </style>
   </head>
   <body>
@@ -154,19 +241,60 @@ const indexHTML = `
     <h2>Upload an MP4 Video</h2>
     <form action="/upload" method="post" enctype="multipart/form-data">
       <input type="file" name="file" accept="video/mp4" required />
+      <input type="text" name="title" placeholder="Title" />
+      <input type="text" name="uploader" placeholder="Uploader" />
+      <input type="text" name="tags" placeholder="Tags, comma separated" />
+      <textarea name="description" placeholder="Description"></textarea>
       <input type="submit" value="Upload" />
     </form>
 
     <h2>Watchlist</h2>
+    <form class="search" action="/" method="get">
+      <input type="search" name="q" value="{{.Search}}" placeholder="Search titles" />
+      <label><input type="checkbox" name="match" value="prefix" {{if .SearchPrefix}}checked{{end}} /> prefix</label>
+      <select name="sort">
+        <option value="newest" {{if eq .Sort "newest"}}selected{{end}}>Newest</option>
+        <option value="oldest" {{if eq .Sort "oldest"}}selected{{end}}>Oldest</option>
+        <option value="title" {{if eq .Sort "title"}}selected{{end}}>Title</option>
+      </select>
+      <input type="submit" value="Search" />
+    </form>
     <ul>
-      {{range .}}
+      {{range .Videos}}
       <li>
-        <a href="/videos/{{.EscapedID}}">{{.Id}} ({{.UploadTime}})</a>
+        <a class="thumb" href="/videos/{{.EscapedID}}">
+          {{if eq .Status "ready"}}<img src="/content/{{.EscapedID}}/poster.jpg" alt="" loading="lazy" onerror="this.remove()" />{{end}}
+        </a>
+        <span class="entry">
+          <a href="/videos/{{.EscapedID}}">{{.Title}}</a>
+          <span class="meta">{{.UploadTime.Format "2006-01-02 15:04"}}{{if .Duration}} · {{.Duration}}{{end}}{{if .Resolution}} · {{.Resolution}}{{end}}</span>
+          {{if ne .Status "ready"}}<span class="status-{{.Status}}">{{.Status}}</span>{{end}}
+        </span>
+        <button class="delete" data-id="{{.EscapedID}}" onclick="deleteVideo(this)">Delete</button>
       </li>
       {{else}}
-      <li>No videos uploaded yet.</li>
+      <li>{{if .Search}}No videos match "{{.Search}}".{{else}}No videos uploaded yet.{{end}}</li>
       {{end}}
     </ul>
+    <nav class="pages">
+      {{if .FirstURL}}<a href="{{.FirstURL}}">« First page</a>{{end}}
+      {{if .NextURL}}<a href="{{.NextURL}}">Next page »</a>{{end}}
+    </nav>
+
+    <script>
+      function deleteVideo(button) {
+        if (!confirm("Delete this video?")) {
+          return;
+        }
+        fetch("/videos/" + button.dataset.id, { method: "DELETE" }).then(function (resp) {
+          if (resp.ok) {
+            location.reload();
+          } else {
+            resp.text().then(function (msg) { alert("Delete failed: " + msg); });
+          }
+        });
+      }
+    </script>
   </body>
 </html>
 `
@@ -176,7 +304,7 @@ const videoHTML = `
 <html lang="en">
   <head>
     <meta charset="UTF-8" />
-    <title>{{.Id}} - TritonTube</title>
+    <title>{{.Title}} - TritonTube</title>
 
     <!-- JetBrains Mono font -->
     <link href="https://fonts.googleapis.com/css2?family=JetBrains+Mono&display=swap" rel="stylesheet" />
@@ -217,6 +345,35 @@ const videoHTML = `
         color: #ccc;
       }
 
+      dl {
+        display: grid;
+        grid-template-columns: max-content 1fr;
+        gap: 4px 16px;
+        margin: 0 0 24px;
+        color: #ccc;
+      }
+
+      dt {
+        color: var(--primary-dark);
+      }
+
+      dd {
+        margin: 0;
+      }
+
+      .player {
+        position: relative;
+      }
+
+      #thumbPreview {
+        display: none;
+        position: absolute;
+        bottom: 80px;
+        border: 2px solid var(--text);
+        border-radius: 4px;
+        pointer-events: none;
+      }
+
       video {
         width: 100%;
         max-width: 100%;
@@ -243,16 +400,83 @@ const videoHTML = `
     </style>
   </head>
   <body>
-    <h1>{{.Id}}</h1>
-    <p>Uploaded at: {{.UploadedAt}}</p>
+    <h1>{{.Title}}</h1>
+    {{if .Description}}<p>{{.Description}}</p>{{end}}
 
-    <video id="dashPlayer" controls></video>
+    {{if eq .Status "ready"}}
+    <div class="player">
+      <video id="dashPlayer" controls{{if .HasPoster}} poster="/content/{{.EscapedID}}/poster.jpg"{{end}}>
+        {{if .HasThumbnails}}<track kind="metadata" label="thumbnails" src="/content/{{.EscapedID}}/thumbnails.vtt" />{{end}}
+      </video>
+      <div id="thumbPreview"></div>
+    </div>
 
     <script>
-      var url = "/content/{{.Id}}/manifest.mpd";
-      var player = dashjs.MediaPlayer().create();
-      player.initialize(document.querySelector("#dashPlayer"), url, false);
+      var video = document.querySelector("#dashPlayer");
+      var base = "/content/{{.EscapedID}}/";
+      var hasHLS = {{.HasHLS}};
+      var canMSE = window.MediaSource && MediaSource.isTypeSupported &&
+        MediaSource.isTypeSupported('video/mp4; codecs="avc1.42E01E,mp4a.40.2"');
+
+      // Safari on iOS has native HLS but no MediaSource for dash.js.
+      if (hasHLS && (!canMSE || !window.dashjs) && video.canPlayType("application/vnd.apple.mpegurl")) {
+        video.src = base + "master.m3u8";
+      } else {
+        var player = dashjs.MediaPlayer().create();
+        player.initialize(video, base + "manifest.mpd", false);
+      }
+
+      // Seek previews: while the pointer is over the native controls'
+      // seek bar, show the sprite tile whose cue covers that time.
+      var thumbs = video.querySelector("track[label=thumbnails]");
+      if (thumbs) {
+        var preview = document.querySelector("#thumbPreview");
+        thumbs.track.mode = "hidden";
+        var hidePreview = function () { preview.style.display = "none"; };
+        video.addEventListener("mouseleave", hidePreview);
+        video.addEventListener("mousemove", function (e) {
+          var rect = video.getBoundingClientRect();
+          var cues = thumbs.track.cues;
+          if (!video.duration || !cues || e.clientY < rect.bottom - 48) {
+            hidePreview();
+            return;
+          }
+          var t = (e.clientX - rect.left) / rect.width * video.duration;
+          for (var i = 0; i < cues.length; i++) {
+            if (t < cues[i].startTime || t >= cues[i].endTime) {
+              continue;
+            }
+            var m = cues[i].text.match(/^(.*)#xywh=(\d+),(\d+),(\d+),(\d+)$/);
+            if (!m) {
+              break;
+            }
+            var w = +m[4];
+            preview.style.backgroundImage = "url(" + base + m[1] + ")";
+            preview.style.backgroundPosition = "-" + m[2] + "px -" + m[3] + "px";
+            preview.style.width = w + "px";
+            preview.style.height = m[5] + "px";
+            preview.style.left = Math.min(Math.max(e.clientX - rect.left - w / 2, 0), rect.width - w) + "px";
+            preview.style.display = "block";
+            return;
+          }
+          hidePreview();
+        });
+      }
     </script>
+    {{else}}
+    <p>This video is {{.Status}}.</p>
+    {{end}}
+
+    <dl>
+      <dt>Uploaded</dt><dd>{{.UploadedAt.Format "2006-01-02 15:04:05"}}</dd>
+      {{if .Uploader}}<dt>Uploader</dt><dd>{{.Uploader}}</dd>{{end}}
+      {{if .Duration}}<dt>Duration</dt><dd>{{.Duration}}</dd>{{end}}
+      {{if .Resolution}}<dt>Resolution</dt><dd>{{.Resolution}}</dd>{{end}}
+      {{if .Codec}}<dt>Codec</dt><dd>{{.Codec}}</dd>{{end}}
+      {{if .FileSize}}<dt>File size</dt><dd>{{.FileSize}}</dd>{{end}}
+      {{if .SegmentCount}}<dt>Segments</dt><dd>{{.SegmentCount}}</dd>{{end}}
+      {{if .Tags}}<dt>Tags</dt><dd>{{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</dd>{{end}}
+    </dl>
 
     <p><a href="/">← Back to Home</a></p>
   </body>
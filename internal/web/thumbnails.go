@@ -0,0 +1,113 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Thumbnail outputs stored next to a video's segments. The sprite sheet
+// holds one tile every thumbnailInterval; the WebVTT track maps each time
+// range to its tile with a #xywh fragment, the format video players use
+// for seek previews.
+const (
+	posterFile      = "poster.jpg"
+	thumbnailSprite = "thumbnails.jpg"
+	thumbnailTrack  = "thumbnails.vtt"
+
+	thumbnailWidth   = 160
+	thumbnailColumns = 10
+	maxThumbnails    = 100
+)
+
+// thumbnailInterval spaces tiles 10s apart, closer for short videos and
+// further apart for long ones so the sprite never exceeds maxThumbnails.
+// The result is rounded up to the millisecond ffmpeg is given, since
+// rounding down could leave room for one tile too many.
+func thumbnailInterval(duration time.Duration) time.Duration {
+	interval := 10 * time.Second
+	if duration > 0 && duration < 10*interval {
+		interval = max(duration/10, time.Second)
+	}
+	if duration > maxThumbnails*interval {
+		interval = (duration + maxThumbnails - 1) / maxThumbnails
+	}
+	if r := interval % time.Millisecond; r != 0 {
+		interval += time.Millisecond - r
+	}
+	return interval
+}
+
+// thumbnailCount is the number of tiles covering duration at interval,
+// at least one and at most maxThumbnails.
+func thumbnailCount(duration, interval time.Duration) int {
+	count := int((duration + interval - 1) / interval)
+	return min(max(count, 1), maxThumbnails)
+}
+
+// generateThumbnails extracts a poster frame and a sprite sheet with its
+// WebVTT track from the source.
+func generateThumbnails(sourcePath, outputDir string, probe *probeResult) error {
+	posterAt := min(probe.Duration/10, 5*time.Second)
+	cmd := exec.Command("ffmpeg", "-y",
+		"-ss", fmt.Sprintf("%.3f", posterAt.Seconds()),
+		"-i", sourcePath,
+		"-frames:v", "1",
+		"-vf", "scale=-2:'min(720,ih)'",
+		"-q:v", "3",
+		filepath.Join(outputDir, posterFile),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Println("FFmpeg poster error:", string(output))
+		return fmt.Errorf("poster extraction failed: %v", err)
+	}
+
+	if probe.Duration <= 0 || probe.Width <= 0 {
+		return nil
+	}
+	interval := thumbnailInterval(probe.Duration)
+	count := thumbnailCount(probe.Duration, interval)
+	columns := min(count, thumbnailColumns)
+	rows := (count + columns - 1) / columns
+	tileHeight := thumbnailWidth * probe.Height / probe.Width
+	tileHeight += tileHeight % 2
+
+	cmd = exec.Command("ffmpeg", "-y",
+		"-i", sourcePath,
+		"-vf", fmt.Sprintf("fps=1/%.3f,scale=%d:%d,tile=%dx%d", interval.Seconds(), thumbnailWidth, tileHeight, columns, rows),
+		"-frames:v", "1",
+		"-q:v", "5",
+		filepath.Join(outputDir, thumbnailSprite),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Println("FFmpeg sprite error:", string(output))
+		return fmt.Errorf("sprite extraction failed: %v", err)
+	}
+
+	track := thumbnailVTT(probe.Duration, interval, count, columns, thumbnailWidth, tileHeight)
+	return os.WriteFile(filepath.Join(outputDir, thumbnailTrack), []byte(track), 0644)
+}
+
+// thumbnailVTT writes one cue per sprite tile, left to right and top to
+// bottom.
+func thumbnailVTT(duration, interval time.Duration, count, columns, width, height int) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := 0; i < count; i++ {
+		start := time.Duration(i) * interval
+		end := min(start+interval, duration)
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), thumbnailSprite,
+			i%columns*width, i/columns*height, width, height)
+	}
+	return b.String()
+}
+
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThumbnailIntervalBoundsTileCount(t *testing.T) {
+	durations := []time.Duration{
+		time.Millisecond,
+		999 * time.Millisecond,
+		5 * time.Second,
+		99*time.Second + 999*time.Millisecond,
+		1000 * time.Second,
+		1000*time.Second + 1,
+		1000*time.Second + 50*time.Millisecond + 7,
+		3*time.Hour + 17*time.Minute + 3*time.Second + 333333333,
+	}
+	// Long durations whose hundredth part falls between milliseconds.
+	for d := 1000*time.Second + 1; d < 1001*time.Second; d += 7777777 {
+		durations = append(durations, d)
+	}
+
+	for _, d := range durations {
+		interval := thumbnailInterval(d)
+		if interval <= 0 || interval%time.Millisecond != 0 {
+			t.Fatalf("thumbnailInterval(%v) = %v, want a positive whole millisecond", d, interval)
+		}
+		// What ffmpeg produces at fps=1/interval, before any clamping.
+		frames := int((d + interval - 1) / interval)
+		if frames > maxThumbnails {
+			t.Errorf("thumbnailInterval(%v) = %v gives %d frames, more than %d", d, interval, frames, maxThumbnails)
+		}
+		if count := thumbnailCount(d, interval); count < 1 || count > maxThumbnails || time.Duration(count)*interval < d {
+			t.Errorf("thumbnailCount(%v, %v) = %d does not cover the video in 1..%d tiles", d, interval, count, maxThumbnails)
+		}
+	}
+}
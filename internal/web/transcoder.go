@@ -0,0 +1,143 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const dashManifest = "manifest.mpd"
+
+// FFmpegTranscoder encodes the source into every fitting rung of Ladder as
+// one DASH presentation, optionally with HLS playlists over the same
+// segments, and extracts a poster and seek thumbnails.
+type FFmpegTranscoder struct {
+	Ladder *Ladder
+	HLS    bool
+}
+
+var _ Transcoder = (*FFmpegTranscoder)(nil)
+
+func NewFFmpegTranscoder(ladder *Ladder, hls bool) *FFmpegTranscoder {
+	return &FFmpegTranscoder{Ladder: ladder, HLS: hls}
+}
+
+func (t *FFmpegTranscoder) Transcode(sourcePath, outputDir string) (*TranscodeResult, error) {
+	probe, err := probeVideo(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	rungs := t.Ladder.forSource(probe.Height)
+	log.Printf("[TRANSCODE] %s: source %dx%d, encoding %d renditions", sourcePath, probe.Width, probe.Height, len(rungs))
+
+	manifestPath := filepath.Join(outputDir, dashManifest)
+	cmd := exec.Command("ffmpeg", dashArgs(sourcePath, manifestPath, rungs, t.Ladder.AudioBitrate, probe.HasAudio, t.HLS)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Println("FFmpeg error:", string(output))
+		return nil, fmt.Errorf("ffmpeg failed: %v", err)
+	}
+
+	// Thumbnails are a nicety; the video plays without them.
+	if err := generateThumbnails(sourcePath, outputDir, probe); err != nil {
+		log.Printf("[TRANSCODE] %s: skipping thumbnails: %v", sourcePath, err)
+	}
+
+	files, err := listOutputFiles(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return &TranscodeResult{
+		Manifest: dashManifest,
+		Files:    files,
+		Duration: probe.Duration,
+		Width:    probe.Width,
+		Height:   probe.Height,
+		Codec:    probe.Codec,
+	}, nil
+}
+
+func listOutputFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transcoder output: %v", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			files = append(files, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// FakeTranscoder writes a small synthetic DASH manifest and segments
+// without running ffmpeg, for exercising uploads in tests and development
+// environments. The output is not playable.
+type FakeTranscoder struct {
+	Segments int
+	HLS      bool
+}
+
+var _ Transcoder = (*FakeTranscoder)(nil)
+
+func NewFakeTranscoder(hls bool) *FakeTranscoder {
+	return &FakeTranscoder{Segments: 3, HLS: hls}
+}
+
+func (t *FakeTranscoder) Transcode(sourcePath, outputDir string) (*TranscodeResult, error) {
+	if _, err := os.Stat(sourcePath); err != nil {
+		return nil, fmt.Errorf("source not readable: %v", err)
+	}
+
+	files := map[string]string{
+		dashManifest: fmt.Sprintf(`<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT%dS">
+  <Period>
+    <AdaptationSet contentType="video" mimeType="video/mp4">
+      <Representation id="0" bandwidth="400000" width="426" height="240">
+        <SegmentTemplate initialization="init-$RepresentationID$.m4s" media="chunk-$RepresentationID$-$Number%%05d$.m4s" duration="4" startNumber="1"/>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>
+`, 4*t.Segments),
+		"init-0.m4s": "fake init segment\n",
+	}
+	for i := 1; i <= t.Segments; i++ {
+		files[fmt.Sprintf("chunk-0-%05d.m4s", i)] = fmt.Sprintf("fake media segment %d\n", i)
+	}
+	if t.HLS {
+		files[hlsMasterPlaylist] = "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-STREAM-INF:BANDWIDTH=400000,RESOLUTION=426x240\nmedia_0.m3u8\n"
+		playlist := "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"init-0.m4s\"\n"
+		for i := 1; i <= t.Segments; i++ {
+			playlist += fmt.Sprintf("#EXTINF:4.0,\nchunk-0-%05d.m4s\n", i)
+		}
+		files["media_0.m3u8"] = playlist + "#EXT-X-ENDLIST\n"
+	}
+	duration := time.Duration(4*t.Segments) * time.Second
+	files[posterFile] = "fake poster\n"
+	files[thumbnailSprite] = "fake sprite\n"
+	files[thumbnailTrack] = thumbnailVTT(duration, 4*time.Second, t.Segments, t.Segments, thumbnailWidth, 90)
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(outputDir, name), []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	names, err := listOutputFiles(outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return &TranscodeResult{
+		Manifest: dashManifest,
+		Files:    names,
+		Duration: duration,
+		Width:    426,
+		Height:   240,
+		Codec:    "fake",
+	}, nil
+}
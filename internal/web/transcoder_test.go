@@ -0,0 +1,77 @@
+package web
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFakeTranscoderWritesHLSPlaylists(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source.mp4")
+	if err := os.WriteFile(source, []byte("fake mp4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	result, err := NewFakeTranscoder(true).Transcode(source, out)
+	if err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	for _, name := range []string{dashManifest, hlsMasterPlaylist, "media_0.m3u8"} {
+		if !slices.Contains(result.Files, name) {
+			t.Errorf("%s missing from result files %v", name, result.Files)
+		}
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+	master, _ := os.ReadFile(filepath.Join(out, hlsMasterPlaylist))
+	if !strings.HasPrefix(string(master), "#EXTM3U") || !strings.Contains(string(master), "media_0.m3u8") {
+		t.Errorf("master playlist does not point at the media playlist:\n%s", master)
+	}
+	media, _ := os.ReadFile(filepath.Join(out, "media_0.m3u8"))
+	for _, line := range strings.Split(string(media), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") && !slices.Contains(result.Files, line) {
+			t.Errorf("media playlist lists %s, which was not written", line)
+		}
+	}
+
+	// Without HLS only the DASH presentation is written.
+	result, err = NewFakeTranscoder(false).Transcode(source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	for _, name := range result.Files {
+		if strings.HasSuffix(name, ".m3u8") {
+			t.Errorf("HLS playlist %s written with HLS off", name)
+		}
+	}
+}
+
+func TestHLSJobStoresPlaylists(t *testing.T) {
+	content := NewFSVideoContentService(t.TempDir())
+	s, metadata := newJobServer(t, content)
+	s.SetTranscoder(NewFakeTranscoder(true))
+
+	meta, job, err := s.queueUpload(strings.NewReader("fake mp4"), uploadFields{Title: "clip"})
+	if err != nil {
+		t.Fatalf("queueUpload: %v", err)
+	}
+	if job = waitForJob(t, metadata, job.Id); job.Status != JobDone {
+		t.Fatalf("job %s: %s", job.Status, job.Error)
+	}
+	for _, name := range []string{hlsMasterPlaylist, "media_0.m3u8"} {
+		r, _, err := content.Open(meta.Id, name)
+		if err != nil {
+			t.Fatalf("%s not stored: %v", name, err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if !strings.HasPrefix(string(data), "#EXTM3U") {
+			t.Errorf("stored %s = %q", name, data)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package web
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resumable uploads follow the core tus 1.0 protocol
+// (https://tus.io/protocols/resumable-upload) plus an explicit finalize
+// step:
+//
+//	POST   /api/v1/uploads                 create; Upload-Length and Upload-Metadata headers
+//	HEAD   /api/v1/uploads/{id}            Upload-Offset and Upload-Length so far
+//	GET    /api/v1/uploads/{id}            the same as JSON
+//	PATCH  /api/v1/uploads/{id}            append the body at the Upload-Offset header
+//	POST   /api/v1/uploads/{id}/finalize   hand the complete file to the transcode path
+//	DELETE /api/v1/uploads/{id}            abandon the upload
+//
+// Each session is a JSON descriptor and a .part file under
+// <spool-dir>/uploads, so a client can resume after a dropped connection
+// or a web server restart. The offset is simply the size of the .part
+// file. Sessions untouched for uploadSessionTTL are removed. Upload-Length
+// is capped at the server's maximum upload size, advertised as
+// Tus-Max-Size. The HTML form at /upload and POST /api/v1/videos send the
+// whole file in one request and are not resumable.
+const (
+	tusVersion       = "1.0.0"
+	uploadSessionTTL = 24 * time.Hour
+)
+
+type uploadSession struct {
+	Id        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+type uploadStore struct {
+	dir string
+	// stop ends the hourly expiry loop.
+	stop context.CancelFunc
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// newUploadStore expires stale sessions under dir now and then hourly
+// until close is called.
+func newUploadStore(dir string) (*uploadStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload dir: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &uploadStore{dir: dir, stop: cancel, busy: make(map[string]bool)}
+	u.expire()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				u.expire()
+			}
+		}
+	}()
+	return u, nil
+}
+
+func (u *uploadStore) close() {
+	u.stop()
+}
+
+func (u *uploadStore) sessionPath(id string) string { return filepath.Join(u.dir, id+".json") }
+func (u *uploadStore) partPath(id string) string    { return filepath.Join(u.dir, id+".part") }
+
+func (u *uploadStore) create(length int64, metadata map[string]string) (*uploadSession, error) {
+	session := &uploadSession{
+		Id:        newJobID(),
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if err := os.WriteFile(u.partPath(session.Id), nil, 0644); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(u.sessionPath(session.Id), data, 0644); err != nil {
+		os.Remove(u.partPath(session.Id))
+		return nil, err
+	}
+	return session, nil
+}
+
+// read returns the session and its current offset, or nil if there is no
+// such session.
+func (u *uploadStore) read(id string) (*uploadSession, int64, error) {
+	if !isHexID(id) {
+		return nil, 0, nil
+	}
+	data, err := os.ReadFile(u.sessionPath(id))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var session uploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, 0, err
+	}
+	info, err := os.Stat(u.partPath(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	return &session, info.Size(), nil
+}
+
+// acquire marks a session as having a request in flight, so two PATCHes
+// can never interleave writes to the same file.
+func (u *uploadStore) acquire(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.busy[id] {
+		return false
+	}
+	u.busy[id] = true
+	return true
+}
+
+func (u *uploadStore) release(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.busy, id)
+}
+
+func (u *uploadStore) remove(id string) {
+	os.Remove(u.partPath(id))
+	os.Remove(u.sessionPath(id))
+}
+
+// expire removes sessions whose .part file has not been written to for
+// uploadSessionTTL.
+func (u *uploadStore) expire() {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		log.Printf("[UPLOAD] Failed to list upload sessions: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(u.partPath(id))
+		if err == nil && time.Since(info.ModTime()) < uploadSessionTTL {
+			continue
+		}
+		if !u.acquire(id) {
+			continue
+		}
+		log.Printf("[UPLOAD] Expiring upload session %s", id)
+		u.remove(id)
+		u.release(id)
+	}
+}
+
+func isHexID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !strings.ContainsRune("0123456789abcdef", rune(id[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: comma
+// separated "key base64(value)" pairs.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+type uploadJSON struct {
+	Id       string            `json:"id"`
+	Offset   int64             `json:"offset"`
+	Length   int64             `json:"length"`
+	Metadata map[string]string `json:"metadata"`
+	Complete bool              `json:"complete"`
+}
+
+func uploadResponse(session *uploadSession, offset int64) uploadJSON {
+	return uploadJSON{
+		Id:       session.Id,
+		Offset:   offset,
+		Length:   session.Length,
+		Metadata: session.Metadata,
+		Complete: offset == session.Length,
+	}
+}
+
+func setUploadHeaders(w http.ResponseWriter, session *uploadSession, offset int64) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// handleUploads serves the resumable upload routes; parts is the path
+// below /api/v1/uploads.
+func (s *server) handleUploads(w http.ResponseWriter, r *http.Request, parts []string) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if s.uploads == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "unavailable", "resumable uploads are not enabled")
+		return
+	}
+
+	switch {
+	case len(parts) == 0:
+		switch r.Method {
+		case http.MethodPost:
+			s.createUpload(w, r)
+		case http.MethodOptions:
+			w.Header().Set("Tus-Version", tusVersion)
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(s.maxUploadSize, 10))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeMethodNotAllowed(w, "POST, OPTIONS")
+		}
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodHead, http.MethodGet:
+			s.uploadStatus(w, r, parts[0])
+		case http.MethodPatch:
+			s.patchUpload(w, r, parts[0])
+		case http.MethodDelete:
+			s.deleteUpload(w, parts[0])
+		default:
+			writeMethodNotAllowed(w, "GET, HEAD, PATCH, DELETE")
+		}
+	case len(parts) == 2 && parts[1] == "finalize":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, "POST")
+			return
+		}
+		s.finalizeUpload(w, parts[0])
+	default:
+		writeAPIError(w, http.StatusNotFound, "not_found", "no such endpoint")
+	}
+}
+
+func (s *server) createUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "Upload-Length header must be a non-negative integer")
+		return
+	}
+	if length > s.maxUploadSize {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "too_large",
+			fmt.Sprintf("Upload-Length exceeds the maximum of %d bytes", s.maxUploadSize))
+		return
+	}
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", err.Error())
+		return
+	}
+	if name := metadata["filename"]; name != "" && !strings.HasSuffix(name, ".mp4") {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "only .mp4 allowed")
+		return
+	}
+
+	session, err := s.uploads.create(length, metadata)
+	if err != nil {
+		log.Printf("[UPLOAD] Failed to create upload session: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to create upload")
+		return
+	}
+	log.Printf("[UPLOAD] Created resumable upload %s (%d bytes)", session.Id, length)
+
+	w.Header().Set("Location", apiPrefix+"uploads/"+session.Id)
+	setUploadHeaders(w, session, 0)
+	writeJSON(w, http.StatusCreated, uploadResponse(session, 0))
+}
+
+// readUpload loads a session, writing the error response itself and
+// returning nil if there is none.
+func (s *server) readUpload(w http.ResponseWriter, id string) (*uploadSession, int64) {
+	session, offset, err := s.uploads.read(id)
+	if err != nil {
+		log.Printf("[UPLOAD] Failed to read upload %s: %v", id, err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to read upload")
+		return nil, 0
+	}
+	if session == nil {
+		writeAPIError(w, http.StatusNotFound, "not_found", "upload not found")
+		return nil, 0
+	}
+	return session, offset
+}
+
+func (s *server) uploadStatus(w http.ResponseWriter, r *http.Request, id string) {
+	session, offset := s.readUpload(w, id)
+	if session == nil {
+		return
+	}
+	setUploadHeaders(w, session, offset)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	writeJSON(w, http.StatusOK, uploadResponse(session, offset))
+}
+
+// patchUpload appends the request body at Upload-Offset. Whatever arrives
+// before the connection drops is kept, and the client resumes from the
+// offset a HEAD reports.
+func (s *server) patchUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"PATCH body must be application/offset+octet-stream")
+		return
+	}
+	if !s.uploads.acquire(id) {
+		writeAPIError(w, http.StatusConflict, "conflict", "another request is writing to this upload")
+		return
+	}
+	defer s.uploads.release(id)
+
+	session, offset := s.readUpload(w, id)
+	if session == nil {
+		return
+	}
+	clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_argument", "Upload-Offset header must be an integer")
+		return
+	}
+	if clientOffset != offset {
+		setUploadHeaders(w, session, offset)
+		writeAPIError(w, http.StatusConflict, "offset_mismatch",
+			fmt.Sprintf("upload is at offset %d, not %d", offset, clientOffset))
+		return
+	}
+
+	if r.ContentLength > session.Length-offset {
+		setUploadHeaders(w, session, offset)
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "too_large", "body exceeds Upload-Length")
+		return
+	}
+
+	part, err := os.OpenFile(s.uploads.partPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("[UPLOAD] Failed to open upload %s: %v", id, err)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "failed to write upload")
+		return
+	}
+	// Nothing past the declared length is written. A chunked body has no
+	// Content-Length to check up front, so one that turns out too long is
+	// only noticed once the rest has been stored.
+	n, copyErr := io.Copy(part, io.LimitReader(r.Body, session.Length-offset))
+	syncErr := part.Sync()
+	part.Close()
+	offset += n
+
+	if copyErr != nil || syncErr != nil {
+		log.Printf("[UPLOAD] Upload %s interrupted at %d/%d: %v", id, offset, session.Length, copyErr)
+		setUploadHeaders(w, session, offset)
+		writeAPIError(w, http.StatusInternalServerError, "internal", "upload interrupted; resume from Upload-Offset")
+		return
+	}
+	if extra, _ := r.Body.Read(make([]byte, 1)); extra > 0 {
+		// The stored bytes stay, but the error does not report an offset
+		// as if the request had succeeded; a HEAD reports where it is.
+		writeAPIError(w, http.StatusRequestEntityTooLarge, "too_large", "body exceeds Upload-Length")
+		return
+	}
+	setUploadHeaders(w, session, offset)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) deleteUpload(w http.ResponseWriter, id string) {
+	if !s.uploads.acquire(id) {
+		writeAPIError(w, http.StatusConflict, "conflict", "another request is writing to this upload")
+		return
+	}
+	defer s.uploads.release(id)
+
+	if session, _ := s.readUpload(w, id); session == nil {
+		return
+	}
+	s.uploads.remove(id)
+	log.Printf("[UPLOAD] Abandoned resumable upload %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finalizeUpload moves a complete upload into the spool and queues it
+// exactly like a single-request upload. If that fails the session is kept
+// intact and finalize can be retried.
+func (s *server) finalizeUpload(w http.ResponseWriter, id string) {
+	if !s.uploads.acquire(id) {
+		writeAPIError(w, http.StatusConflict, "conflict", "another request is writing to this upload")
+		return
+	}
+	defer s.uploads.release(id)
+
+	session, offset := s.readUpload(w, id)
+	if session == nil {
+		return
+	}
+	if offset != session.Length {
+		setUploadHeaders(w, session, offset)
+		writeAPIError(w, http.StatusConflict, "incomplete",
+			fmt.Sprintf("upload has %d of %d bytes", offset, session.Length))
+		return
+	}
+
+	fields := uploadFormFields(session.Metadata["filename"], func(key string) string {
+		return session.Metadata[key]
+	})
+	meta, job, err := s.queueUploadFile(s.uploads.partPath(id), fields)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	s.uploads.remove(id)
+
+	writeAccepted(w, meta, job)
+}
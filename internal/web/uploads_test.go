@@ -0,0 +1,222 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func uploadRequest(s *server, method, path string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.handleAPI(rec, req)
+	return rec
+}
+
+// createSession starts a resumable upload of length bytes and returns
+// its URL.
+func createSession(t *testing.T, s *server, length int) string {
+	t.Helper()
+	rec := uploadRequest(s, http.MethodPost, "/api/v1/uploads", nil, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "filename Y2xpcC5tcDQ=",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", rec.Code, rec.Body)
+	}
+	return rec.Header().Get("Location")
+}
+
+func patch(s *server, url string, offset int, body io.Reader) *httptest.ResponseRecorder {
+	return uploadRequest(s, http.MethodPatch, url, body, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	})
+}
+
+func uploadOffset(t *testing.T, s *server, url string) string {
+	t.Helper()
+	rec := uploadRequest(s, http.MethodHead, url, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HEAD %s = %d", url, rec.Code)
+	}
+	return rec.Header().Get("Upload-Offset")
+}
+
+// droppedBody yields data and then fails, like a connection that drops
+// mid-request.
+type droppedBody struct{ data io.Reader }
+
+func (d *droppedBody) Read(p []byte) (int, error) {
+	n, err := d.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset by peer")
+	}
+	return n, err
+}
+
+func TestResumableUploadOffsetMismatchAndResume(t *testing.T) {
+	s, metadata := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	url := createSession(t, s, 11)
+
+	if rec := patch(s, url, 0, strings.NewReader("hello")); rec.Code != http.StatusNoContent {
+		t.Fatalf("first PATCH = %d: %s", rec.Code, rec.Body)
+	}
+
+	// A retried PATCH at a stale offset is refused and told where to resume.
+	rec := patch(s, url, 0, strings.NewReader("hello"))
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "offset_mismatch") {
+		t.Fatalf("stale PATCH = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Upload-Offset"); got != "5" {
+		t.Fatalf("stale PATCH reported offset %s, want 5", got)
+	}
+	if rec := patch(s, url, 7, strings.NewReader("ld")); rec.Code != http.StatusConflict {
+		t.Fatalf("PATCH past the end = %d, want 409", rec.Code)
+	}
+
+	// A dropped connection keeps what arrived.
+	rec = patch(s, url, 5, &droppedBody{strings.NewReader(" wo")})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("dropped PATCH = %d: %s", rec.Code, rec.Body)
+	}
+	if got := uploadOffset(t, s, url); got != "8" {
+		t.Fatalf("offset after dropped PATCH = %s, want 8", got)
+	}
+
+	// Finalizing early is refused; resuming from HEAD completes the file.
+	if rec := uploadRequest(s, http.MethodPost, url+"/finalize", nil, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("early finalize = %d, want 409", rec.Code)
+	}
+	if rec := patch(s, url, 8, strings.NewReader("rld")); rec.Code != http.StatusNoContent {
+		t.Fatalf("resumed PATCH = %d: %s", rec.Code, rec.Body)
+	}
+	rec = uploadRequest(s, http.MethodPost, url+"/finalize", nil, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("finalize = %d: %s", rec.Code, rec.Body)
+	}
+
+	var accepted struct {
+		Job jobJSON `json:"job"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&accepted); err != nil {
+		t.Fatal(err)
+	}
+	job, err := metadata.ReadJob(accepted.Job.Id)
+	if err != nil || job == nil {
+		t.Fatalf("ReadJob = %v, %v", job, err)
+	}
+	if data, err := os.ReadFile(job.SourcePath); err == nil && string(data) != "hello world" {
+		t.Fatalf("spooled source = %q, want %q", data, "hello world")
+	}
+	if rec := uploadRequest(s, http.MethodHead, url, nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("HEAD after finalize = %d, want 404", rec.Code)
+	}
+}
+
+// flakyMetadata fails Create while failing is set.
+type flakyMetadata struct {
+	*SQLiteVideoMetadataService
+	failing atomic.Bool
+}
+
+func (f *flakyMetadata) Create(meta *VideoMetadata) error {
+	if f.failing.Load() {
+		return errors.New("database is locked")
+	}
+	return f.SQLiteVideoMetadataService.Create(meta)
+}
+
+func TestFinalizeFailureKeepsUpload(t *testing.T) {
+	s, sqlite := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	metadata := &flakyMetadata{SQLiteVideoMetadataService: sqlite}
+	s.metadataService = metadata
+
+	url := createSession(t, s, 5)
+	if rec := patch(s, url, 0, strings.NewReader("hello")); rec.Code != http.StatusNoContent {
+		t.Fatalf("PATCH = %d: %s", rec.Code, rec.Body)
+	}
+
+	metadata.failing.Store(true)
+	if rec := uploadRequest(s, http.MethodPost, url+"/finalize", nil, nil); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("finalize with failing metadata = %d: %s", rec.Code, rec.Body)
+	}
+	if got := uploadOffset(t, s, url); got != "5" {
+		t.Fatalf("offset after failed finalize = %s, want 5", got)
+	}
+	spooled, _ := os.ReadDir(s.spoolDir)
+	for _, e := range spooled {
+		if strings.HasSuffix(e.Name(), ".mp4") {
+			t.Fatalf("failed finalize left %s in the spool", e.Name())
+		}
+	}
+
+	metadata.failing.Store(false)
+	if rec := uploadRequest(s, http.MethodPost, url+"/finalize", nil, nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("retried finalize = %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestUploadSizeLimit(t *testing.T) {
+	s, metadata := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	s.SetMaxUploadSize(8)
+
+	rec := uploadRequest(s, http.MethodPost, "/api/v1/uploads", nil, map[string]string{"Upload-Length": "9"})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("create over the limit = %d, want 413", rec.Code)
+	}
+	if rec := uploadRequest(s, http.MethodOptions, "/api/v1/uploads", nil, nil); rec.Header().Get("Tus-Max-Size") != "8" {
+		t.Fatalf("Tus-Max-Size = %q, want 8", rec.Header().Get("Tus-Max-Size"))
+	}
+	createSession(t, s, 8)
+
+	for size, want := range map[int]int{9: http.StatusRequestEntityTooLarge, 8: http.StatusAccepted} {
+		rec := uploadRequest(s, http.MethodPost, "/api/v1/videos", strings.NewReader(strings.Repeat("x", size)),
+			map[string]string{"Content-Type": "video/mp4"})
+		if rec.Code != want {
+			t.Errorf("raw upload of %d bytes = %d, want %d: %s", size, rec.Code, want, rec.Body)
+			continue
+		}
+		if rec.Code == http.StatusAccepted {
+			var accepted acceptedJSON
+			decodeJSON(t, rec, &accepted)
+			waitForJob(t, metadata, accepted.Job.Id)
+		}
+	}
+}
+
+func TestPatchPastUploadLength(t *testing.T) {
+	s, _ := newJobServer(t, NewFSVideoContentService(t.TempDir()))
+	url := createSession(t, s, 8)
+
+	// A declared length over the rest is refused before anything is
+	// written.
+	rec := patch(s, url, 0, strings.NewReader("123456789"))
+	if rec.Code != http.StatusRequestEntityTooLarge || rec.Header().Get("Upload-Offset") != "0" {
+		t.Fatalf("PATCH of 9 bytes = %d at offset %q, want 413 at 0", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+	if got := uploadOffset(t, s, url); got != "0" {
+		t.Fatalf("offset after refused PATCH = %s, want 0", got)
+	}
+
+	// A chunked body keeps what fits but does not report it with the 413.
+	rec = patch(s, url, 0, io.MultiReader(strings.NewReader("123456789")))
+	if rec.Code != http.StatusRequestEntityTooLarge || rec.Header().Get("Upload-Offset") != "" {
+		t.Fatalf("chunked PATCH of 9 bytes = %d at offset %q, want 413 and no offset",
+			rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+	if got := uploadOffset(t, s, url); got != "8" {
+		t.Fatalf("offset after chunked PATCH = %s, want 8", got)
+	}
+}